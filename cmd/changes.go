@@ -0,0 +1,447 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/mattn/go-isatty"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+var (
+	dryRun    bool
+	assumeYes bool
+)
+
+// plannedChange is a file write or delete collected during a dry run
+type plannedChange struct {
+	Path    string
+	Existed bool
+	Before  []byte
+	After   []byte
+	Delete  bool
+}
+
+// plannedCommand is an external command deferred by a dry run
+type plannedCommand struct {
+	Description string
+	Run         func() error
+}
+
+// changePlan collects everything a dry run would do, in order
+type changePlan struct {
+	changes  []*plannedChange
+	byPath   map[string]*plannedChange
+	dirs     []string
+	commands []plannedCommand
+}
+
+var plan = &changePlan{byPath: map[string]*plannedChange{}}
+
+// change returns the planned change of path, reading the current content
+// the first time the path is touched
+func (p *changePlan) change(path string) (*plannedChange, error) {
+	key := filepath.Clean(path)
+	if c, ok := p.byPath[key]; ok {
+		return c, nil
+	}
+	c := &plannedChange{Path: key}
+	content, err := os.ReadFile(key)
+	switch {
+	case err == nil:
+		c.Existed, c.Before = true, content
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	p.byPath[key] = c
+	p.changes = append(p.changes, c)
+	return c, nil
+}
+
+// lookup returns the planned content of path, ok is false when the path
+// is not part of the plan
+func (p *changePlan) lookup(path string) (content []byte, exists, ok bool) {
+	c, ok := p.byPath[filepath.Clean(path)]
+	if !ok {
+		return nil, false, false
+	}
+	return c.After, !c.Delete, true
+}
+
+// empty reports whether the plan changes nothing
+func (p *changePlan) empty() bool {
+	for _, c := range p.changes {
+		if c.modified() {
+			return false
+		}
+	}
+	return len(p.dirs) == 0 && len(p.commands) == 0
+}
+
+func (c *plannedChange) modified() bool {
+	if c.Delete {
+		return c.Existed
+	}
+	return !c.Existed || string(c.Before) != string(c.After)
+}
+
+// writeFile creates or overwrites a file, or plans the write in a dry run
+func writeFile(filePath, content string) error {
+	if dryRun {
+		c, err := plan.change(filePath)
+		if err != nil {
+			return err
+		}
+		c.After, c.Delete = []byte(content), false
+		return nil
+	}
+	if activeJournal != nil {
+		if err := activeJournal.recordWrite(filePath); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		return fmt.Errorf("failed to write content: %w", err)
+	}
+	return nil
+}
+
+// removeFile deletes a file, or plans the delete in a dry run
+func removeFile(filePath string) error {
+	if dryRun {
+		c, err := plan.change(filePath)
+		if err != nil {
+			return err
+		}
+		c.After, c.Delete = nil, true
+		return nil
+	}
+	if activeJournal != nil {
+		if err := activeJournal.recordFile(filePath); err != nil {
+			return err
+		}
+	}
+	return os.Remove(filePath)
+}
+
+// removeTree deletes dir and everything below it, or plans the deletes of
+// its files in a dry run
+func removeTree(dir string) error {
+	var files, dirs []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+		} else {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	for _, file := range files {
+		if err := removeFile(file); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+	if dryRun {
+		return nil
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Remove(dirs[i]); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", dirs[i], err)
+		}
+	}
+	return nil
+}
+
+// makeDir creates a directory and its parents, or plans it in a dry run
+func makeDir(dir string) error {
+	if dryRun {
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) && !slices.Contains(plan.dirs, filepath.Clean(dir)) {
+			plan.dirs = append(plan.dirs, filepath.Clean(dir))
+		}
+		return nil
+	}
+	if activeJournal != nil {
+		activeJournal.recordDir(dir)
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// readFile reads a file, seeing the planned content during a dry run
+func readFile(filePath string) ([]byte, error) {
+	if content, exists, ok := plan.lookup(filePath); ok {
+		if !exists {
+			return nil, &fs.PathError{Op: "open", Path: filePath, Err: fs.ErrNotExist}
+		}
+		return content, nil
+	}
+	return os.ReadFile(filePath)
+}
+
+// fileExists reports whether a file exists, including planned files
+func fileExists(filePath string) bool {
+	if _, exists, ok := plan.lookup(filePath); ok {
+		return exists
+	}
+	_, err := os.Stat(filePath)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+// formatGoFiles gofmts the Go files the command wrote, or plans to write
+// in a dry run. Unlike go fmt ./... it leaves the other files of the
+// project alone, and its rewrites are journaled like any other write.
+func formatGoFiles() error {
+	var files []string
+	switch {
+	case dryRun:
+		for _, c := range plan.changes {
+			if !c.Delete {
+				files = append(files, c.Path)
+			}
+		}
+	case activeJournal != nil:
+		files = slices.Clone(activeJournal.written)
+	}
+
+	for _, file := range files {
+		if filepath.Ext(file) != ".go" {
+			continue
+		}
+		content, err := readFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		formatted, err := format.Source(content)
+		if err != nil {
+			return fmt.Errorf("failed to format %s: %w", file, err)
+		}
+		if bytes.Equal(formatted, content) {
+			continue
+		}
+		if err := writeFile(file, string(formatted)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// logChange logs a change a command made, or during a dry run the change
+// it plans, since nothing is written before the plan is applied
+func logChange(done, planned string, val ...interface{}) {
+	if dryRun {
+		pkg.InfoLog(append([]interface{}{planned}, val...)...)
+		return
+	}
+	pkg.SuccessLog(append([]interface{}{done}, val...)...)
+}
+
+// successBox announces a finished command. A dry run leaves it to the plan
+// summary.
+func successBox(message string) {
+	if !dryRun {
+		pkg.SuccessBox(message)
+	}
+}
+
+// runExternal runs an external step such as protoc or go mod tidy. A dry
+// run defers it until the plan is confirmed.
+func runExternal(description string, run func() error) error {
+	if dryRun {
+		plan.commands = append(plan.commands, plannedCommand{Description: description, Run: run})
+		return nil
+	}
+	return run()
+}
+
+// finishDryRun prints the plan of a dry run and applies it when confirmed
+func finishDryRun() error {
+	if !dryRun {
+		return nil
+	}
+	if plan.empty() {
+		pkg.InfoLog("Dry run: nothing to change")
+		return nil
+	}
+
+	created, overwritten, deleted := printPlan()
+	pkg.Section("Summary")
+	pkg.InfoLog(fmt.Sprintf("%d to create, %d to overwrite, %d to delete, %d commands to run",
+		created, overwritten, deleted, len(plan.commands)))
+
+	if !confirmPlan() {
+		pkg.WarningLog("Dry run: nothing was applied")
+		return nil
+	}
+	return applyPlan()
+}
+
+// printPlan prints a unified diff of every planned change and returns the
+// number of creates, overwrites and deletes
+func printPlan() (created, overwritten, deleted int) {
+	pkg.Section("Planned changes")
+	for _, dir := range plan.dirs {
+		pkg.Green.Printf("mkdir %s\n", dir)
+	}
+
+	for _, c := range plan.changes {
+		if !c.modified() {
+			continue
+		}
+		diff := difflib.UnifiedDiff{Context: 3, FromFile: "a/" + filepath.ToSlash(c.Path), ToFile: "b/" + filepath.ToSlash(c.Path)}
+		switch {
+		case c.Delete:
+			deleted++
+			diff.A, diff.ToFile = diffLines(c.Before), "/dev/null"
+		case !c.Existed:
+			created++
+			diff.B, diff.FromFile = diffLines(c.After), "/dev/null"
+		default:
+			overwritten++
+			diff.A, diff.B = diffLines(c.Before), diffLines(c.After)
+		}
+
+		text, err := difflib.GetUnifiedDiffString(diff)
+		if err != nil {
+			pkg.WarningLog("Cannot diff", c.Path, err)
+			continue
+		}
+		if text == "" {
+			// an empty file has no lines to diff, only its headers
+			text = fmt.Sprintf("--- %s\n+++ %s\n", diff.FromFile, diff.ToFile)
+		}
+		printDiff(text)
+	}
+
+	for _, command := range plan.commands {
+		pkg.Yellow.Printf("run %s\n", command.Description)
+	}
+	return created, overwritten, deleted
+}
+
+// diffLines splits content into newline terminated diff lines. Unlike
+// difflib.SplitLines it adds no empty line after a trailing newline, nor a
+// line to an empty file.
+func diffLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+	last := len(lines) - 1
+	if lines[last] == "" {
+		return lines[:last]
+	}
+	lines[last] += "\n"
+	return lines
+}
+
+// printDiff colors the lines of a unified diff
+func printDiff(text string) {
+	for _, line := range strings.SplitAfter(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Print(line)
+		case strings.HasPrefix(line, "+"):
+			pkg.Green.Print(line)
+		case strings.HasPrefix(line, "-"):
+			pkg.Red.Print(line)
+		case strings.HasPrefix(line, "@@"):
+			pkg.Yellow.Print(line)
+		default:
+			fmt.Print(line)
+		}
+	}
+	if !strings.HasSuffix(text, "\n") {
+		fmt.Println()
+	}
+}
+
+// confirmPlan asks whether to apply the plan. Without a terminal only
+// --yes applies it.
+func confirmPlan() bool {
+	if assumeYes {
+		return true
+	}
+	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		pkg.InfoLog("Rerun with --yes to apply these changes")
+		return false
+	}
+
+	fmt.Print("Apply these changes? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// applyPlan writes the planned files, then runs the deferred commands. A
+// failing command rolls the written files back, together with the outputs
+// of a deferred protoc or buf run.
+func applyPlan() error {
+	dryRun = false
+	snapshots := []string{"go.mod", "go.sum"}
+	if manifest, err := loadManifest(); err == nil {
+		snapshots = protogenOutputs(manifest)
+	}
+	return withRollback(snapshots, writePlan)
+}
+
+func writePlan() error {
+	for _, dir := range plan.dirs {
+		if err := makeDir(dir); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	for _, c := range plan.changes {
+		if !c.modified() {
+			continue
+		}
+		if c.Delete {
+			if err := removeFile(c.Path); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", c.Path, err)
+			}
+			removeEmptyParents(c.Path)
+			continue
+		}
+		if err := makeDir(filepath.Dir(c.Path)); err != nil {
+			return fmt.Errorf("failed to create parent directory of %s: %w", c.Path, err)
+		}
+		if err := writeFile(c.Path, string(c.After)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", c.Path, err)
+		}
+	}
+	for _, command := range plan.commands {
+		pkg.InfoLog("Running", command.Description)
+		if err := command.Run(); err != nil {
+			return fmt.Errorf("%s failed: %w", command.Description, err)
+		}
+	}
+
+	pkg.SuccessLog("Changes applied")
+	return nil
+}
+
+// removeEmptyParents removes the directories a delete left empty
+func removeEmptyParents(path string) {
+	for dir := filepath.Dir(path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// testDryRun starts a dry run with an empty plan in a temporary directory
+// holding files
+func testDryRun(t *testing.T, files map[string]string) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dryRun, plan = true, &changePlan{byPath: map[string]*plannedChange{}}
+	t.Cleanup(func() {
+		dryRun, assumeYes = false, false
+		plan = &changePlan{byPath: map[string]*plannedChange{}}
+	})
+}
+
+// captureOutput returns what fn prints, without colors
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, output, noColor := os.Stdout, color.Output, color.NoColor
+	os.Stdout, color.Output, color.NoColor = w, w, true
+	defer func() { os.Stdout, color.Output, color.NoColor = stdout, output, noColor }()
+
+	done := make(chan []byte)
+	go func() {
+		content, _ := io.ReadAll(r)
+		done <- content
+	}()
+	fn()
+	w.Close()
+	return string(<-done)
+}
+
+func TestDryRunPlan(t *testing.T) {
+	testDryRun(t, map[string]string{"keep.txt": "old\n", "gone.txt": "bye\n", "same.txt": "same\n"})
+
+	ran := false
+	steps := []error{
+		makeDir("app/rpc"),
+		writeFile("app/rpc/new.go", "package rpc\n"),
+		writeFile("keep.txt", "new\n"),
+		removeFile("gone.txt"),
+		writeFile("same.txt", "same\n"),
+		writeFile("app/rpc/fmt.go", "package rpc\nvar  a=1\n"),
+		formatGoFiles(),
+		runExternal("go mod tidy", func() error { ran = true; return nil }),
+	}
+	if err := errors.Join(steps...); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := readFile("keep.txt"); err != nil || string(content) != "new\n" {
+		t.Errorf("readFile(keep.txt) = %q, %v, want the planned content", content, err)
+	}
+	if content, _ := readFile("app/rpc/fmt.go"); string(content) != "package rpc\n\nvar a = 1\n" {
+		t.Errorf("formatGoFiles() planned %q", content)
+	}
+	if _, err := readFile("gone.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("readFile(gone.txt) error = %v, want not exist", err)
+	}
+	if !fileExists("app/rpc/new.go") || fileExists("gone.txt") {
+		t.Error("fileExists() does not see the planned files")
+	}
+	if content, _ := os.ReadFile("keep.txt"); string(content) != "old\n" {
+		t.Errorf("dry run wrote keep.txt: %q", content)
+	}
+	if _, err := os.Stat("app"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("dry run created app")
+	}
+	if ran {
+		t.Error("dry run ran go mod tidy")
+	}
+	if !reflect.DeepEqual(plan.dirs, []string{"app/rpc"}) || len(plan.commands) != 1 || plan.empty() {
+		t.Errorf("plan dirs = %v, %d commands", plan.dirs, len(plan.commands))
+	}
+
+	assumeYes = true
+	captureOutput(t, func() {
+		if err := finishDryRun(); err != nil {
+			t.Fatalf("finishDryRun() error = %v", err)
+		}
+	})
+	want := map[string]string{"app/rpc/new.go": "package rpc\n", "app/rpc/fmt.go": "package rpc\n\nvar a = 1\n", "keep.txt": "new\n", "same.txt": "same\n"}
+	for path, content := range want {
+		if got, err := os.ReadFile(path); err != nil || string(got) != content {
+			t.Errorf("%s = %q, %v, want %q", path, got, err, content)
+		}
+	}
+	if _, err := os.Stat("gone.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("gone.txt was not removed")
+	}
+	if !ran || dryRun {
+		t.Errorf("after applying ran = %v, dryRun = %v", ran, dryRun)
+	}
+}
+
+func TestConfirmPlan(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin, assumeYes = stdin, false })
+
+	for _, yes := range []bool{false, true} {
+		assumeYes = yes
+		var got bool
+		captureOutput(t, func() { got = confirmPlan() })
+		if got != yes {
+			t.Errorf("confirmPlan() without a terminal and --yes=%v = %v", yes, got)
+		}
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func() error
+		want   string
+		counts [3]int
+	}{
+		{
+			name:   "new empty file",
+			change: func() error { return writeFile("empty.txt", "") },
+			want:   "--- /dev/null\n+++ b/empty.txt\n",
+			counts: [3]int{1, 0, 0},
+		},
+		{
+			name:   "new file",
+			change: func() error { return writeFile("new.txt", "a\nb\n") },
+			want:   "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+			counts: [3]int{1, 0, 0},
+		},
+		{
+			name:   "overwritten file",
+			change: func() error { return writeFile("old.txt", "a\nB\nc\n") },
+			want:   "--- a/old.txt\n+++ b/old.txt\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			counts: [3]int{0, 1, 0},
+		},
+		{
+			name:   "emptied file",
+			change: func() error { return writeFile("old.txt", "") },
+			want:   "--- a/old.txt\n+++ b/old.txt\n@@ -1,3 +0,0 @@\n-a\n-b\n-c\n",
+			counts: [3]int{0, 1, 0},
+		},
+		{
+			name:   "deleted file",
+			change: func() error { return removeFile("old.txt") },
+			want:   "--- a/old.txt\n+++ /dev/null\n@@ -1,3 +0,0 @@\n-a\n-b\n-c\n",
+			counts: [3]int{0, 0, 1},
+		},
+		{
+			name:   "unchanged file",
+			change: func() error { return writeFile("old.txt", "a\nb\nc\n") },
+		},
+		{
+			name: "directories and commands",
+			change: func() error {
+				return errors.Join(makeDir("app"), runExternal("go mod tidy", func() error { return nil }))
+			},
+			want: "mkdir app\nrun go mod tidy\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDryRun(t, map[string]string{"old.txt": "a\nb\nc\n"})
+			if err := tt.change(); err != nil {
+				t.Fatal(err)
+			}
+
+			var counts [3]int
+			got := captureOutput(t, func() { counts[0], counts[1], counts[2] = printPlan() })
+			if want := "\n== PLANNED CHANGES ==\n\n" + tt.want; got != want {
+				t.Errorf("printPlan() printed\n%q\nwant\n%q", got, want)
+			}
+			if counts != tt.counts {
+				t.Errorf("printPlan() = %v, want %v", counts, tt.counts)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+)
+
+// convertorLibrary maps the files of the conversion library to their
+// templates. They sit next to the convertor.go written by init and are
+// refreshed by module generation unless edited.
+var convertorLibrary = map[string]string{
+	"pgtype.go":    "project/convert_pgtype.go.tmpl",
+	"wellknown.go": "project/convert_wellknown.go.tmpl",
+}
+
+// convertorCallPattern matches the convertor functions an expression calls
+var convertorCallPattern = regexp.MustCompile(`\bconvertor\.(\w+)\(`)
+
+// writeConvertorLibrary writes the conversion library into the convertor
+// package and returns the functions the package declares afterwards
+func writeConvertorLibrary(manifest *Manifest, state *GeneratedState) (map[string]bool, error) {
+	names, err := writeLibrary(state, manifest.Layout.Convert, convertorLibrary)
+	if err != nil {
+		return nil, err
+	}
+	return convertorFuncs(manifest.Layout.Convert, names)
+}
+
+// writeLibrary writes the files of a generated package, mapped to their
+// templates, and returns their names. Files edited since they were
+// generated are kept.
+func writeLibrary(state *GeneratedState, dir string, files map[string]string) ([]string, error) {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := makeDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for _, name := range names {
+		content, err := renderGoTemplate(files[name], nil)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, name)
+		result, err := state.writeGenerated(path, content, files[name])
+		if err != nil {
+			return nil, err
+		}
+		switch result {
+		case writeSkipped:
+			pkg.WarningLog("Keeping", path, "(edited since it was generated)")
+		case writeCreated, writeUpdated:
+			logChange("Wrote", "Would write", path)
+		}
+	}
+	return names, nil
+}
+
+// convertorFuncs returns the functions declared by the convertor package
+// in dir. The library files are read through the plan, so a dry run sees
+// them before they exist.
+func convertorFuncs(dir string, library []string) (map[string]bool, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	for _, name := range library {
+		if path := filepath.Join(dir, name); !slices.Contains(files, path) {
+			files = append(files, path)
+		}
+	}
+
+	funcs := map[string]bool{}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		content, err := readFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		parsed, err := parser.ParseFile(fset, file, content, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		for _, decl := range parsed.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+				funcs[fn.Name.Name] = true
+			}
+		}
+	}
+	return funcs, nil
+}
+
+// declaresCalls reports whether every convertor function a conversion
+// calls is declared
+func (c *fieldConversion) declaresCalls(funcs map[string]bool) bool {
+	for _, expr := range []string{c.ToProto, c.ToModel, c.Wrap} {
+		for _, match := range convertorCallPattern.FindAllStringSubmatch(expr, -1) {
+			if !funcs[match[1]] {
+				return false
+			}
+		}
+	}
+	return true
+}
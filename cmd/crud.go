@@ -0,0 +1,677 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+var crudModule string
+
+var crudCmd = &cobra.Command{
+	Use:   "crud [table]",
+	Short: "Generate a working crud module from a schema table",
+	Long: "Reads the CREATE TABLE statement of the table from the schema directory and generates the proto entity, " +
+		"request and response messages with http bindings, Create/Get/List/Update/Delete queries in the queries " +
+		"directory, handlers calling the sqlc store and the converters between the proto entity and the table model, " +
+		"then runs sqlc generate, protogen and module register. The module is named after the table unless --module is set",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := generateCRUD(args[0]); err != nil {
+			pkg.Red.Printf("Failed to generate crud module: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// crudVerbs are the rpcs of a crud module, in service order
+var crudVerbs = []string{"Create", "Get", "List", "Update", "Delete"}
+
+// queryNamePattern matches the name and kind of a sqlc query annotation
+var queryNamePattern = regexp.MustCompile(`(?m)^--\s*name:\s*(\w+)\s+:(\w+)`)
+
+// tableBinding maps a crud module onto its schema table and the code sqlc
+// generates for it
+type tableBinding struct {
+	Model  *sqlcModel
+	Table  *SQLTable
+	Struct string
+	PK     *SQLColumn
+}
+
+// QueryCall is the sqlc query behind a handler. Prepare, Args and Result
+// are Go source: the statements run before the call, the arguments after
+// ctx and the statements filling resp from Row.
+type QueryCall struct {
+	Name string
+	// Kind is the sqlc command: one, many, exec, execrows or execresult
+	Kind    string
+	Prepare []string
+	Args    string
+	// Row holds the result, empty for exec queries returning only an error
+	Row    string
+	Assign string
+	Result []string
+	// Resource names the rows in the errors of the errs package
+	Resource string
+	// NotFound is the error message of an execrows query affecting no row
+	NotFound string
+}
+
+// crudQueries is the data rendered into module/queries.sql.tmpl
+type crudQueries struct {
+	Module        string
+	Table         string
+	PK            string
+	Names         map[string]string
+	InsertColumns string
+	InsertValues  string
+	UpdateSet     string
+}
+
+func generateCRUD(tableName string) error {
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	if !manifest.Features.SQLC {
+		return fmt.Errorf("the sqlc feature is disabled in %s", manifestFileName)
+	}
+	model, err := loadSQLCModel(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to read the schema: %w", err)
+	}
+	binding, err := newTableBinding(model, tableName)
+	if err != nil {
+		return err
+	}
+
+	module := crudModule
+	if module == "" {
+		module = strings.ToLower(binding.Table.Name)
+	}
+	if !moduleNamePattern.MatchString(module) {
+		return fmt.Errorf("invalid module name %q, pick one with --module", module)
+	}
+	protoDir := filepath.Join(manifest.Layout.Proto, module)
+	if existing, _ := filepath.Glob(filepath.Join(protoDir, "*.proto")); len(existing) > 0 {
+		return fmt.Errorf("%s already has proto files, pick another module name with --module", protoDir)
+	}
+	queriesPath := filepath.Join(manifest.Layout.Queries, module+".sql")
+	if fileExists(queriesPath) {
+		return fmt.Errorf("%s already exists", queriesPath)
+	}
+
+	queries := binding.queries(module)
+	declared, err := declaredQueries(manifest.Layout.Queries)
+	if err != nil {
+		return err
+	}
+	for _, verb := range crudVerbs {
+		if name, ok := queries.Names[verb]; ok && declared[name] != "" {
+			return fmt.Errorf("query %s is already declared in %s", name, manifest.Layout.Queries)
+		}
+	}
+	queriesContent, err := renderTemplate("module/queries.sql.tmpl", queries)
+	if err != nil {
+		return err
+	}
+
+	scaffold, err := binding.protoScaffold(manifest, module)
+	if err != nil {
+		return err
+	}
+	var verbs []string
+	for _, verb := range crudVerbs {
+		if _, ok := queries.Names[verb]; ok {
+			verbs = append(verbs, verb)
+		}
+	}
+	if err := scaffold.addRPCs(verbs); err != nil {
+		return err
+	}
+
+	snapshots := append(protogenOutputs(manifest), protoDir, queriesPath, manifest.Layout.Repo,
+		manifest.Layout.RPC, manifest.Layout.Gateway, stateFileName, "go.mod", "go.sum",
+		filepath.Join(manifest.Layout.Proto, enumsModule), manifest.Layout.Convert, manifest.Layout.Errs)
+	return withRollback(snapshots, func() error {
+		if binding.usesEnums() {
+			state, err := loadGeneratedState()
+			if err != nil {
+				return err
+			}
+			if err := writeProtoEnums(manifest, model, state); err != nil {
+				return err
+			}
+			if err := state.Save(); err != nil {
+				return fmt.Errorf("failed to save generator state: %w", err)
+			}
+		}
+		if err := scaffold.write(protoDir); err != nil {
+			return err
+		}
+		logChange("Wrote", "Would write", "proto files to", protoDir)
+		if err := createFileWithContent(queriesPath, queriesContent); err != nil {
+			return fmt.Errorf("failed to create %s: %w", queriesPath, err)
+		}
+		logChange("Wrote", "Would write", "queries to", queriesPath)
+
+		pkg.InfoLog("Generating sqlc code...")
+		if err := runSQLcGenerate(); err != nil {
+			return err
+		}
+
+		// The handlers are generated from the protobuf and sqlc output, so a
+		// dry run defers them together with the generators
+		return runExternal("module generation for "+module, func() error {
+			config, err := newModuleConfig(module, "")
+			if err != nil {
+				return err
+			}
+			config.Table = binding.Table.Name
+			config.State.Modules[module] = GeneratedModule{Table: binding.Table.Name}
+			if err := createModule(config); err != nil {
+				return err
+			}
+
+			pkg.InfoLog("Registering services...")
+			if err := registerServices(); err != nil {
+				return fmt.Errorf("failed to register services: %w", err)
+			}
+			if manifest.Features.Gateway {
+				if err := registerGatewayEndpoints(); err != nil {
+					return fmt.Errorf("failed to register gateway endpoints: %w", err)
+				}
+			}
+			successBox(fmt.Sprintf("Crud module '%s' generated from table %s", module, binding.Table.Name))
+			return nil
+		})
+	})
+}
+
+func newTableBinding(model *sqlcModel, tableName string) (*tableBinding, error) {
+	table := model.Schema.Table(tableName)
+	if table == nil {
+		var names []string
+		for _, table := range model.Schema.Tables {
+			names = append(names, table.Name)
+		}
+		return nil, fmt.Errorf("table %s not found in the schema (tables: %s)", tableName, strings.Join(names, ", "))
+	}
+	if len(table.PrimaryKey) != 1 {
+		return nil, fmt.Errorf("table %s needs a single column primary key", table.Name)
+	}
+	pk := table.Column(table.PrimaryKey[0])
+	if pk == nil {
+		return nil, fmt.Errorf("primary key column %s of table %s not found", table.PrimaryKey[0], table.Name)
+	}
+	return &tableBinding{Model: model, Table: table, Struct: model.StructName(table.Name), PK: pk}, nil
+}
+
+// usesEnums reports whether a column of the table has an enum type
+func (b *tableBinding) usesEnums() bool {
+	for _, column := range b.Table.Columns {
+		if b.Model.Schema.Enum(column.Type) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// queryName returns the name of the crud query of a verb
+func (b *tableBinding) queryName(verb string) string {
+	if verb == "List" {
+		return verb + pluralize(b.Struct)
+	}
+	return verb + b.Struct
+}
+
+// insertColumns returns the columns a create sets, leaving out the ones
+// with a default
+func (b *tableBinding) insertColumns() []*SQLColumn {
+	var columns []*SQLColumn
+	for _, column := range b.Table.Columns {
+		if !column.HasDefault() {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// updateColumns returns the columns an update sets. created_at keeps its
+// value and updated_at is set by the query.
+func (b *tableBinding) updateColumns() []*SQLColumn {
+	var columns []*SQLColumn
+	for _, column := range b.Table.Columns {
+		if column == b.PK || column.Generated || column.Name == "created_at" || column.Name == "updated_at" {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+func (b *tableBinding) queries(module string) *crudQueries {
+	queries := &crudQueries{
+		Module: module,
+		Table:  sqlQuoteIdentifier(b.Table.Name),
+		PK:     sqlQuoteIdentifier(b.PK.Name),
+		Names:  map[string]string{},
+	}
+	for _, verb := range crudVerbs {
+		queries.Names[verb] = b.queryName(verb)
+	}
+
+	var names, values []string
+	for i, column := range b.insertColumns() {
+		names = append(names, sqlQuoteIdentifier(column.Name))
+		values = append(values, fmt.Sprintf("$%d", i+1))
+	}
+	queries.InsertColumns = strings.Join(names, ", ")
+	queries.InsertValues = strings.Join(values, ", ")
+
+	var set []string
+	for i, column := range b.updateColumns() {
+		set = append(set, fmt.Sprintf("%s = $%d", sqlQuoteIdentifier(column.Name), i+2))
+	}
+	if len(set) == 0 {
+		delete(queries.Names, "Update")
+	} else {
+		if b.Table.Column("updated_at") != nil {
+			set = append(set, "updated_at = now()")
+		}
+		queries.UpdateSet = strings.Join(set, ",\n    ")
+	}
+	return queries
+}
+
+// wellKnownProtoFiles are the files declaring the well-known types columns
+// map to
+var wellKnownProtoFiles = map[string]string{
+	"google.protobuf.Timestamp": "google/protobuf/timestamp.proto",
+	"google.protobuf.Duration":  "google/protobuf/duration.proto",
+	"google.protobuf.Value":     "google/protobuf/struct.proto",
+}
+
+// protoScaffold returns the proto scaffold of the table: the entity holds
+// a field per column and the rpcs address rows by primary key
+func (b *tableBinding) protoScaffold(manifest *Manifest, module string) (*ProtoScaffold, error) {
+	scaffold := newProtoScaffold(manifest, module)
+	scaffold.Entity = b.Struct
+	scaffold.EntityField = camelToSnake(b.Struct)
+	scaffold.Service = b.Struct + "Service"
+	scaffold.IDType = b.Model.ProtoType(b.PK)
+	scaffold.EntityFields = nil
+	scaffold.EntityImports = nil
+	columns := map[string]string{}
+	for i, column := range b.Table.Columns {
+		name, err := protoFieldName(column.Name)
+		if err != nil {
+			return nil, fmt.Errorf("column %q of table %s: %w", column.Name, b.Table.Name, err)
+		}
+		if other, ok := columns[name]; ok {
+			return nil, fmt.Errorf("columns %q and %q of table %s both map to the proto field %s", other, column.Name, b.Table.Name, name)
+		}
+		columns[name] = column.Name
+		if column == b.PK {
+			scaffold.IDField = name
+		}
+
+		protoType := b.Model.ProtoType(column)
+		protoImport := wellKnownProtoFiles[strings.TrimPrefix(protoType, "repeated ")]
+		if b.Model.Schema.Enum(column.Type) != nil {
+			protoImport = path.Join(enumsModule, enumsModule+".proto")
+		}
+		if protoImport != "" && !slices.Contains(scaffold.EntityImports, protoImport) {
+			scaffold.EntityImports = append(scaffold.EntityImports, protoImport)
+		}
+		scaffold.EntityFields = append(scaffold.EntityFields, fmt.Sprintf("%s %s = %d;", protoType, name, i+1))
+	}
+	return scaffold, nil
+}
+
+// protoFieldPattern matches the lower snake case proto field names
+var protoFieldPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// protoFieldName returns the proto field of a column in lower snake case,
+// such as created_at for "CreatedAt" and item_id for "Item ID"
+func protoFieldName(column string) (string, error) {
+	words := strings.FieldsFunc(column, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = camelToSnake(word)
+	}
+	name := strings.Join(words, "_")
+	if !protoFieldPattern.MatchString(name) {
+		return "", fmt.Errorf("%q is not a valid proto field name, the column needs to start with an ascii letter and hold only ascii letters, digits and separators", name)
+	}
+	return name, nil
+}
+
+// bindTable binds the handlers and the converter of a crud module to the
+// sqlc code of its table
+func bindTable(config *ModuleConfig, set *ProtoSet) error {
+	model, err := loadSQLCModel(config.Manifest)
+	if err != nil {
+		return fmt.Errorf("failed to read the schema: %w", err)
+	}
+	binding, err := newTableBinding(model, config.Table)
+	if err != nil {
+		return fmt.Errorf("module %s: %w", config.ModuleName, err)
+	}
+
+	var entity *ProtoMessage
+	for _, file := range set.FilesInDir(config.ModuleName) {
+		for _, message := range file.Messages {
+			if message.GoName() == binding.Struct {
+				entity = message
+			}
+		}
+	}
+	if entity == nil {
+		return fmt.Errorf("no %s message found in %s", binding.Struct, config.ProtoPath)
+	}
+	entityType, err := set.GoType(entity.FullName)
+	if err != nil {
+		return err
+	}
+	config.Entity = &entityType
+	config.EntityMessage = entity
+
+	models, err := bindModel(config, set)
+	if err != nil {
+		return err
+	}
+	if config.Model == nil {
+		return fmt.Errorf("no %s model found in %s, run sqlc generate", binding.Struct, config.Manifest.Layout.Repo)
+	}
+
+	declared, err := declaredQueries(config.Manifest.Layout.Queries)
+	if err != nil {
+		return err
+	}
+	for i := range config.ServiceMethods {
+		method := &config.ServiceMethods[i]
+		if !method.IsStreaming() {
+			method.Query = binding.queryCall(set, config, models, method, entity, declared)
+		}
+	}
+	// The other rpcs bind like the ones of any module
+	return bindQueryMethods(config, set, models, declared)
+}
+
+// IsStreaming reports whether either side of the rpc streams
+func (m *ServiceMethod) IsStreaming() bool {
+	return m.ClientStreaming || m.ServerStreaming
+}
+
+// declaredQueries returns the name and kind of every query in dir
+func declaredQueries(dir string) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	queries := map[string]string{}
+	for _, file := range files {
+		content, err := readFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		for _, match := range queryNamePattern.FindAllStringSubmatch(string(content), -1) {
+			queries[match[1]] = match[2]
+		}
+	}
+	return queries, nil
+}
+
+// queryCall binds an rpc named after a crud verb to its query, or returns
+// nil when the rpc is not a crud rpc or its messages do not fit
+func (b *tableBinding) queryCall(set *ProtoSet, config *ModuleConfig, models *repoModels, method *ServiceMethod, entity *ProtoMessage, declared map[string]string) *QueryCall {
+	verb := ""
+	for _, candidate := range crudVerbs {
+		if method.RpcName == b.queryName(candidate) && declared[method.RpcName] != "" {
+			verb = candidate
+		}
+	}
+	request, response := set.Messages[method.Descriptor.InputType], set.Messages[method.Descriptor.OutputType]
+	if verb == "" || request == nil || response == nil {
+		return nil
+	}
+
+	repo := b.Model.Package
+	resource := strings.ToLower(splitWords(b.Struct))
+	call := &QueryCall{
+		Name:     method.RpcName,
+		Kind:     declared[method.RpcName],
+		Row:      "row",
+		Assign:   ":=",
+		Resource: resource,
+	}
+	imports := []GoImport{{Path: config.Manifest.ImportPath(config.Manifest.Layout.Errs)}}
+
+	entityResult := func() {
+		if field := messageField(response, entity.FullName, false); field != nil {
+			call.Result = append(call.Result, fmt.Sprintf("resp.%s = ModelToProto(&row)", field.GoName()))
+		} else {
+			call.Row = "_"
+		}
+	}
+
+	switch verb {
+	case "Create", "Update":
+		field := messageField(request, entity.FullName, false)
+		if field == nil || call.Kind != "one" {
+			return nil
+		}
+		columns := b.insertColumns()
+		if verb == "Update" {
+			columns = append([]*SQLColumn{b.PK}, b.updateColumns()...)
+		}
+		errDeclared := false
+		if len(columns) > 0 {
+			call.Prepare = append(call.Prepare,
+				fmt.Sprintf("entity := req.Get%s()\nif entity == nil {\nreturn nil, status.Error(codes.InvalidArgument, \"%s is required\")\n}", field.GoName(), field.Name),
+			)
+			var prepare []string
+			var argImports []GoImport
+			prepare, call.Args, errDeclared, argImports = b.columnArgs(config, models, method, call.Name, columns, set.Messages[field.Type])
+			call.Prepare = append(call.Prepare, prepare...)
+			imports = append(imports, argImports...)
+		}
+		entityResult()
+		if call.Row == "_" && errDeclared {
+			call.Assign = "="
+		}
+	case "Get", "Delete":
+		name, _ := protoFieldName(b.PK.Name)
+		field := request.Field(name)
+		pk := models.Structs[b.Struct].Field(b.Model.FieldName(b.PK.Name))
+		if field == nil || pk == nil {
+			return nil
+		}
+		conversion := models.conversion(pk.Type, protoFieldType(field))
+		if conversion == nil {
+			return nil
+		}
+		imports = append(imports, models.imports(conversion)...)
+		onError := fmt.Sprintf("return nil, status.Errorf(codes.InvalidArgument, \"invalid %s: %%v\", err)", field.Name)
+		if conversion.Status {
+			onError = "return nil, err"
+		}
+		expr, stmt := conversion.toModelCode("id", "req.Get"+field.GoName()+"()", onError)
+		if stmt == "" {
+			call.Args = expr
+		} else {
+			call.Prepare = append(call.Prepare, "var id "+pk.Type)
+			if imp, ok := typeImport(pk.Type); ok {
+				imports = append(imports, imp)
+			}
+			if conversion.Fallible && conversion.Wrap == "" {
+				call.Prepare = append(call.Prepare, "var err error")
+			}
+			call.Prepare = append(call.Prepare, stmt)
+			call.Args = "id"
+		}
+		if verb == "Get" {
+			if call.Kind != "one" {
+				return nil
+			}
+			entityResult()
+			if call.Row == "_" && conversion.Fallible && conversion.Wrap == "" {
+				call.Assign = "="
+			}
+		} else {
+			if call.Kind != "execrows" {
+				return nil
+			}
+			call.Row = "affected"
+			call.NotFound = resource + " not found"
+		}
+	case "List":
+		list := messageField(response, entity.FullName, true)
+		if call.Kind != "many" || list == nil || request.Field("page_size") == nil || request.Field("page_token") == nil {
+			return nil
+		}
+		call.Row = "rows"
+		call.Prepare = append(call.Prepare,
+			"pageSize := req.GetPageSize()\nswitch {\ncase pageSize <= 0:\npageSize = 50\ncase pageSize > 1000:\npageSize = 1000\n}",
+			"offset := 0\nif token := req.GetPageToken(); token != \"\" {\nvar err error\nif offset, err = strconv.Atoi(token); err != nil || offset < 0 {\nreturn nil, status.Error(codes.InvalidArgument, \"invalid page_token\")\n}\n}",
+		)
+		call.Args = fmt.Sprintf("%s.%sParams{\nLimit: pageSize,\nOffset: int32(offset),\n}", repo, call.Name)
+		call.Result = append(call.Result,
+			fmt.Sprintf("resp.%[1]s = make([]*%[2]s, 0, len(rows))\nfor i := range rows {\nresp.%[1]s = append(resp.%[1]s, ModelToProto(&rows[i]))\n}", list.GoName(), config.Entity.String()),
+		)
+		if next := response.Field("next_page_token"); next != nil {
+			call.Result = append(call.Result, fmt.Sprintf("if len(rows) == int(pageSize) {\nresp.%s = strconv.Itoa(offset + len(rows))\n}", next.GoName()))
+		}
+		imports = append(imports, GoImport{Path: "strconv"}, config.Entity.Import)
+	}
+
+	if strings.Contains(strings.Join(call.Prepare, "\n")+call.Args, repo+".") {
+		imports = append(imports, GoImport{Alias: repo, Path: config.RepoImportPath()})
+	}
+	for _, imp := range imports {
+		if !containsImport(method.Imports, imp) {
+			method.Imports = append(method.Imports, imp)
+		}
+	}
+	return call
+}
+
+// columnArgs returns the statements building the argument of a query that
+// sets the given columns from the request entity: the column itself, or
+// the params struct sqlc generates for queries with several arguments.
+// Every column is converted on its own, so the columns the query leaves
+// out, such as those with a default, are never read. errDeclared is set
+// when the statements declare err.
+func (b *tableBinding) columnArgs(config *ModuleConfig, models *repoModels, method *ServiceMethod, query string, columns []*SQLColumn, entity *ProtoMessage) (prepare []string, args string, errDeclared bool, imports []GoImport) {
+	model := models.Structs[b.Struct]
+	single := len(columns) == 1
+
+	var fields, stmts []string
+	for _, column := range columns {
+		name := b.Model.FieldName(column.Name)
+		dst := "arg." + name
+		if single {
+			dst = "arg"
+		}
+
+		var goField *repoField
+		if model != nil {
+			goField = model.Field(name)
+		}
+		var field *ProtoField
+		if entity != nil {
+			for _, candidate := range entity.Fields {
+				if modelFieldKey(candidate.Name) == modelFieldKey(name) && candidate.KeyType == "" {
+					field = candidate
+				}
+			}
+		}
+		var conversion *fieldConversion
+		if goField != nil && field != nil {
+			conversion = models.conversion(goField.Type, protoFieldType(field))
+		}
+		if conversion == nil {
+			todo := fmt.Sprintf("// TODO: %s has no field or conversion in the request entity, set it by hand", name)
+			if single {
+				prepare = append(prepare, fmt.Sprintf("var arg %s %s", goFieldType(goField), todo))
+			} else {
+				fields = append(fields, todo)
+			}
+			pkg.WarningLog("Column", column.Name, "of", query, "cannot be read from the request, complete the TODO in", filepath.Join(config.AppPath, method.FileName))
+			continue
+		}
+
+		imports = append(imports, models.imports(conversion)...)
+		onError := fmt.Sprintf("return nil, status.Errorf(codes.InvalidArgument, \"invalid %s: %%v\", err)", field.Name)
+		if conversion.Status {
+			onError = "return nil, err"
+		}
+		if conversion.Fallible && conversion.Wrap == "" {
+			errDeclared = true
+		}
+		source := "entity.Get" + field.GoName() + "()"
+		if field.Optional {
+			source = "entity." + field.GoName()
+		}
+		expr, stmt := conversion.toModelCode(dst, source, onError)
+		switch {
+		case single && expr != "":
+			prepare = append(prepare, "arg := "+expr)
+		case single:
+			prepare = append(prepare, "var arg "+goField.Type)
+			if imp, ok := typeImport(goField.Type); ok {
+				imports = append(imports, imp)
+			}
+			stmts = append(stmts, stmt)
+		case expr != "":
+			fields = append(fields, name+": "+expr+",")
+		default:
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	if !single {
+		prepare = append(prepare, fmt.Sprintf("arg := %s.%sParams{\n%s\n}", b.Model.Package, query, strings.Join(fields, "\n")))
+	}
+	if errDeclared {
+		prepare = append(prepare, "var err error")
+	}
+	return append(prepare, stmts...), "arg", errDeclared, imports
+}
+
+// goFieldType returns the type of a model field, or any when the model
+// has no such field
+func goFieldType(field *repoField) string {
+	if field == nil {
+		return "any"
+	}
+	return field.Type
+}
+
+// messageField returns the first field of message holding the given type
+func messageField(message *ProtoMessage, typeName string, repeated bool) *ProtoField {
+	for _, field := range message.Fields {
+		if field.Type == typeName && field.Repeated == repeated && field.KeyType == "" {
+			return field
+		}
+	}
+	return nil
+}
+
+func init() {
+	crudCmd.Flags().StringVar(&crudModule, "module", "", "Module name (default the table name in lower case)")
+	rootCmd.AddCommand(crudCmd)
+}
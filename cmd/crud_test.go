@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProtoFieldName(t *testing.T) {
+	tests := []struct {
+		column  string
+		want    string
+		wantErr bool
+	}{
+		{column: "created_at", want: "created_at"},
+		{column: "CreatedAt", want: "created_at"},
+		{column: "Item ID", want: "item_id"},
+		{column: "userID", want: "user_id"},
+		{column: "HTTPStatus", want: "http_status"},
+		{column: "order-total", want: "order_total"},
+		{column: " padded__name ", want: "padded_name"},
+		{column: "1st_place", wantErr: true},
+		{column: "café", wantErr: true},
+		{column: "???", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column, func(t *testing.T) {
+			got, err := protoFieldName(tt.column)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("protoFieldName(%q) error = %v, wantErr %v", tt.column, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("protoFieldName(%q) = %q, want %q", tt.column, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLCName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "created_at", want: "CreatedAt"},
+		{name: "user_id", want: "UserID"},
+		{name: "CreatedAt", want: "CreatedAt"},
+		{name: "Item ID", want: "ItemID"},
+		{name: "order-total", want: "OrderTotal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sqlcName(tt.name); got != tt.want {
+				t.Errorf("sqlcName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtoScaffoldFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		fields  []string
+		idField string
+		wantErr string
+	}{
+		{
+			name:    "quoted and mixed case columns",
+			schema:  `CREATE TABLE orders ("Order ID" uuid PRIMARY KEY, "CreatedAt" timestamptz NOT NULL, total_cents int8);`,
+			fields:  []string{"string order_id = 1;", "google.protobuf.Timestamp created_at = 2;", "int64 total_cents = 3;"},
+			idField: "order_id",
+		},
+		{
+			name:    "columns mapping to the same field",
+			schema:  `CREATE TABLE orders (id uuid PRIMARY KEY, "CreatedAt" timestamptz, created_at timestamptz);`,
+			wantErr: `columns "CreatedAt" and "created_at" of table orders both map to the proto field created_at`,
+		},
+		{
+			name:    "column without a valid field name",
+			schema:  `CREATE TABLE orders (id uuid PRIMARY KEY, "2fa" bool);`,
+			wantErr: `column "2fa" of table orders`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := &SQLSchema{}
+			if err := schema.apply(tt.schema); err != nil {
+				t.Fatal(err)
+			}
+			binding, err := newTableBinding(&sqlcModel{Schema: schema, Package: "repo", Driver: "pgx/v5"}, "orders")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			scaffold, err := binding.protoScaffold(defaultManifest("example.com/app"), "orders")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("protoScaffold() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("protoScaffold() error = %v", err)
+			}
+			if !reflect.DeepEqual(scaffold.EntityFields, tt.fields) || scaffold.IDField != tt.idField {
+				t.Errorf("protoScaffold() fields = %q, id %q, want %q, id %q", scaffold.EntityFields, scaffold.IDField, tt.fields, tt.idField)
+			}
+		})
+	}
+}
@@ -0,0 +1,612 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// ProtoSet is the parsed model of every .proto file below the proto root
+type ProtoSet struct {
+	Root     string
+	Files    []*ProtoFile
+	Messages map[string]*ProtoMessage
+	Enums    map[string]*ProtoEnum
+	manifest *Manifest
+}
+
+// ProtoFile describes a single .proto source file
+type ProtoFile struct {
+	Path      string
+	Package   string
+	GoPackage string
+	Imports   []string
+	Options   map[string]string
+	Services  []*ProtoService
+	Messages  []*ProtoMessage
+	Enums     []*ProtoEnum
+	GoImport  GoImport
+}
+
+// ProtoService describes a service and its methods
+type ProtoService struct {
+	Name    string
+	Comment string
+	Options map[string]string
+	Methods []*ProtoMethod
+	File    *ProtoFile
+}
+
+// ProtoMethod describes an rpc including its streaming kind and HTTP bindings
+type ProtoMethod struct {
+	Name            string
+	Comment         string
+	InputType       string
+	OutputType      string
+	ClientStreaming bool
+	ServerStreaming bool
+	HTTPRules       []HTTPRule
+	Options         map[string]string
+	Service         *ProtoService
+}
+
+// HTTPRule is a google.api.http binding
+type HTTPRule struct {
+	Method       string
+	Path         string
+	Body         string
+	ResponseBody string
+}
+
+// ProtoMessage describes a message type
+type ProtoMessage struct {
+	Name     string
+	FullName string
+	Comment  string
+	Fields   []*ProtoField
+	File     *ProtoFile
+}
+
+// ProtoField describes a message field. Type holds the fully qualified
+// name for message and enum types and the keyword for scalars.
+type ProtoField struct {
+	Name     string
+	Type     string
+	Number   int
+	Repeated bool
+	Optional bool
+	KeyType  string
+	Comment  string
+}
+
+// ProtoEnum describes an enum type
+type ProtoEnum struct {
+	Name     string
+	FullName string
+	Values   []ProtoEnumValue
+	File     *ProtoFile
+}
+
+// ProtoEnumValue is a single enum constant
+type ProtoEnumValue struct {
+	Name   string
+	Number int
+}
+
+// GoImport is an aliased Go import
+type GoImport struct {
+	Alias string
+	Path  string
+}
+
+// Standard reports whether the import is from the standard library
+func (i GoImport) Standard() bool {
+	first, _, _ := strings.Cut(i.Path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// GoType is a Go type generated from a proto message or enum
+type GoType struct {
+	Import GoImport
+	Name   string
+}
+
+// String returns the qualified Go type expression
+func (t GoType) String() string {
+	if t.Import.Alias == "" {
+		return t.Name
+	}
+	return t.Import.Alias + "." + t.Name
+}
+
+// wellKnownTypes maps the google.protobuf types to their Go packages
+var wellKnownTypes = map[string]string{
+	"google.protobuf.Any":         "anypb",
+	"google.protobuf.Duration":    "durationpb",
+	"google.protobuf.Empty":       "emptypb",
+	"google.protobuf.FieldMask":   "fieldmaskpb",
+	"google.protobuf.Struct":      "structpb",
+	"google.protobuf.Value":       "structpb",
+	"google.protobuf.ListValue":   "structpb",
+	"google.protobuf.Timestamp":   "timestamppb",
+	"google.protobuf.DoubleValue": "wrapperspb",
+	"google.protobuf.FloatValue":  "wrapperspb",
+	"google.protobuf.Int64Value":  "wrapperspb",
+	"google.protobuf.UInt64Value": "wrapperspb",
+	"google.protobuf.Int32Value":  "wrapperspb",
+	"google.protobuf.UInt32Value": "wrapperspb",
+	"google.protobuf.BoolValue":   "wrapperspb",
+	"google.protobuf.StringValue": "wrapperspb",
+	"google.protobuf.BytesValue":  "wrapperspb",
+	"google.api.HttpBody":         "httpbody",
+}
+
+// parseProtoSet parses every .proto file below the manifest proto root
+func parseProtoSet(manifest *Manifest) (*ProtoSet, error) {
+	set := &ProtoSet{
+		Root:     manifest.Layout.Proto,
+		Messages: make(map[string]*ProtoMessage),
+		Enums:    make(map[string]*ProtoEnum),
+		manifest: manifest,
+	}
+
+	err := filepath.WalkDir(set.Root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(filePath) != ".proto" {
+			return err
+		}
+		rel, err := filepath.Rel(set.Root, filePath)
+		if err != nil {
+			return err
+		}
+		file, err := parseProtoFile(filePath, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		set.Files = append(set.Files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range set.Files {
+		file.GoImport = set.resolveGoImport(file)
+		for _, message := range file.Messages {
+			set.Messages[message.FullName] = message
+		}
+		for _, enum := range file.Enums {
+			set.Enums[enum.FullName] = enum
+		}
+	}
+	for _, file := range set.Files {
+		set.resolveFileTypes(file)
+	}
+	return set, nil
+}
+
+// parseProtoFile parses a single .proto file into the descriptor model
+func parseProtoFile(filePath, relPath string) (*ProtoFile, error) {
+	reader, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer reader.Close()
+
+	parser := proto.NewParser(reader)
+	parser.Filename(filePath)
+	definition, err := parser.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+
+	file := &ProtoFile{
+		Path:    relPath,
+		Options: make(map[string]string),
+	}
+	for _, element := range definition.Elements {
+		switch e := element.(type) {
+		case *proto.Package:
+			file.Package = e.Name
+		case *proto.Import:
+			file.Imports = append(file.Imports, e.Filename)
+		case *proto.Option:
+			file.Options[e.Name] = e.Constant.Source
+			if e.Name == "go_package" {
+				file.GoPackage = e.Constant.Source
+			}
+		case *proto.Service:
+			file.Services = append(file.Services, newProtoService(file, e))
+		case *proto.Message:
+			collectMessages(file, e, file.Package, "")
+		case *proto.Enum:
+			file.Enums = append(file.Enums, newProtoEnum(file, e, joinProtoName(file.Package, e.Name), e.Name))
+		}
+	}
+	return file, nil
+}
+
+func newProtoService(file *ProtoFile, s *proto.Service) *ProtoService {
+	service := &ProtoService{
+		Name:    s.Name,
+		Comment: commentText(s.Comment),
+		Options: make(map[string]string),
+		File:    file,
+	}
+	for _, element := range s.Elements {
+		switch e := element.(type) {
+		case *proto.Option:
+			service.Options[e.Name] = optionSource(e)
+		case *proto.RPC:
+			service.Methods = append(service.Methods, newProtoMethod(service, e))
+		}
+	}
+	return service
+}
+
+func newProtoMethod(service *ProtoService, r *proto.RPC) *ProtoMethod {
+	method := &ProtoMethod{
+		Name:            r.Name,
+		Comment:         fieldComment(r.Comment, r.InlineComment),
+		InputType:       r.RequestType,
+		OutputType:      r.ReturnsType,
+		ClientStreaming: r.StreamsRequest,
+		ServerStreaming: r.StreamsReturns,
+		Options:         make(map[string]string),
+		Service:         service,
+	}
+	for _, element := range r.Elements {
+		option, ok := element.(*proto.Option)
+		if !ok {
+			continue
+		}
+		if option.Name == "(google.api.http)" {
+			method.HTTPRules = parseHTTPRules(option.Constant)
+			continue
+		}
+		method.Options[option.Name] = optionSource(option)
+	}
+	return method
+}
+
+// parseHTTPRules reads a google.api.http option including additional bindings
+func parseHTTPRules(literal proto.Literal) []HTTPRule {
+	var rule HTTPRule
+	var additional []HTTPRule
+	for _, entry := range literal.OrderedMap {
+		switch entry.Name {
+		case "get", "put", "post", "delete", "patch":
+			rule.Method = strings.ToUpper(entry.Name)
+			rule.Path = entry.Source
+		case "custom":
+			if kind, ok := entry.OrderedMap.Get("kind"); ok {
+				rule.Method = strings.ToUpper(kind.Source)
+			}
+			if customPath, ok := entry.OrderedMap.Get("path"); ok {
+				rule.Path = customPath.Source
+			}
+		case "body":
+			rule.Body = entry.Source
+		case "response_body":
+			rule.ResponseBody = entry.Source
+		case "additional_bindings":
+			if len(entry.Array) > 0 {
+				for _, binding := range entry.Array {
+					additional = append(additional, parseHTTPRules(*binding)...)
+				}
+			} else {
+				additional = append(additional, parseHTTPRules(*entry.Literal)...)
+			}
+		}
+	}
+	if rule.Method == "" {
+		return additional
+	}
+	return append([]HTTPRule{rule}, additional...)
+}
+
+func collectMessages(file *ProtoFile, m *proto.Message, scope, goPrefix string) {
+	if m.IsExtend {
+		return
+	}
+	fullName := joinProtoName(scope, m.Name)
+	message := &ProtoMessage{
+		Name:     goPrefix + m.Name,
+		FullName: fullName,
+		Comment:  commentText(m.Comment),
+		File:     file,
+	}
+	file.Messages = append(file.Messages, message)
+
+	var addFields func(elements []proto.Visitee)
+	addFields = func(elements []proto.Visitee) {
+		for _, element := range elements {
+			switch e := element.(type) {
+			case *proto.NormalField:
+				message.Fields = append(message.Fields, &ProtoField{
+					Name:     e.Name,
+					Type:     e.Type,
+					Number:   e.Sequence,
+					Repeated: e.Repeated,
+					Optional: e.Optional,
+					Comment:  fieldComment(e.Comment, e.InlineComment),
+				})
+			case *proto.MapField:
+				message.Fields = append(message.Fields, &ProtoField{
+					Name:    e.Name,
+					Type:    e.Type,
+					Number:  e.Sequence,
+					KeyType: e.KeyType,
+					Comment: fieldComment(e.Comment, e.InlineComment),
+				})
+			case *proto.Oneof:
+				for _, oneofElement := range e.Elements {
+					if field, ok := oneofElement.(*proto.OneOfField); ok {
+						message.Fields = append(message.Fields, &ProtoField{
+							Name:    field.Name,
+							Type:    field.Type,
+							Number:  field.Sequence,
+							Comment: fieldComment(field.Comment, field.InlineComment),
+						})
+					}
+				}
+			case *proto.Message:
+				collectMessages(file, e, fullName, goPrefix+m.Name+".")
+			case *proto.Enum:
+				file.Enums = append(file.Enums, newProtoEnum(file, e, joinProtoName(fullName, e.Name), goPrefix+m.Name+"."+e.Name))
+			}
+		}
+	}
+	addFields(m.Elements)
+}
+
+func newProtoEnum(file *ProtoFile, e *proto.Enum, fullName, goName string) *ProtoEnum {
+	enum := &ProtoEnum{
+		Name:     goName,
+		FullName: fullName,
+		File:     file,
+	}
+	for _, element := range e.Elements {
+		if value, ok := element.(*proto.EnumField); ok {
+			enum.Values = append(enum.Values, ProtoEnumValue{Name: value.Name, Number: value.Integer})
+		}
+	}
+	return enum
+}
+
+// resolveFileTypes replaces relative type references by fully qualified names
+func (s *ProtoSet) resolveFileTypes(file *ProtoFile) {
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			method.InputType = s.resolveTypeName(file.Package, method.InputType)
+			method.OutputType = s.resolveTypeName(file.Package, method.OutputType)
+		}
+	}
+	for _, message := range file.Messages {
+		for _, field := range message.Fields {
+			if !isScalarProtoType(field.Type) {
+				field.Type = s.resolveTypeName(message.FullName, field.Type)
+			}
+		}
+	}
+}
+
+// resolveTypeName follows the protobuf scoping rules: the name is searched
+// in the innermost scope first, then in each enclosing scope
+func (s *ProtoSet) resolveTypeName(scope, name string) string {
+	if strings.HasPrefix(name, ".") {
+		return strings.TrimPrefix(name, ".")
+	}
+	for {
+		candidate := joinProtoName(scope, name)
+		if s.Messages[candidate] != nil || s.Enums[candidate] != nil || wellKnownTypes[candidate] != "" {
+			return candidate
+		}
+		if scope == "" {
+			return name
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// resolveGoImport returns the Go package a file is generated into. Project
+// files land in protogen/<dir> whatever their go_package says, since buf
+// managed mode and the protoc M flags both override it. Vendored third party
+// files keep their own go_package.
+func (s *ProtoSet) resolveGoImport(file *ProtoFile) GoImport {
+	importPath, packageName := file.GoPackage, ""
+	if i := strings.Index(importPath, ";"); i >= 0 {
+		importPath, packageName = importPath[:i], importPath[i+1:]
+	}
+
+	if importPath == "" || !s.manifest.IsVendoredProto(file.Path) {
+		importPath, packageName = s.manifest.ProtoGoImportPath(file.Path), ""
+	}
+	if packageName == "" {
+		packageName = sanitizeGoPackageName(path.Base(importPath))
+	}
+	return GoImport{Alias: packageName + "pb", Path: importPath}
+}
+
+// GoType returns the Go type generated for a fully qualified proto type
+func (s *ProtoSet) GoType(fullName string) (GoType, error) {
+	if pkgName, ok := wellKnownTypes[fullName]; ok {
+		name := fullName[strings.LastIndex(fullName, ".")+1:]
+		importPath := "google.golang.org/protobuf/types/known/" + pkgName
+		if pkgName == "httpbody" {
+			importPath = "google.golang.org/genproto/googleapis/api/httpbody"
+		}
+		return GoType{Import: GoImport{Alias: pkgName, Path: importPath}, Name: name}, nil
+	}
+	if message, ok := s.Messages[fullName]; ok {
+		return GoType{Import: message.File.GoImport, Name: goCamelCaseName(message.Name)}, nil
+	}
+	if enum, ok := s.Enums[fullName]; ok {
+		return GoType{Import: enum.File.GoImport, Name: goCamelCaseName(enum.Name)}, nil
+	}
+	return GoType{}, fmt.Errorf("unknown proto type %s", fullName)
+}
+
+// FilesInDir returns the files located directly in a proto root sub directory
+func (s *ProtoSet) FilesInDir(dir string) []*ProtoFile {
+	var files []*ProtoFile
+	for _, file := range s.Files {
+		if path.Dir(file.Path) == filepath.ToSlash(dir) {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// ServicesInDir returns the services defined in a proto root sub directory
+func (s *ProtoSet) ServicesInDir(dir string) []*ProtoService {
+	var services []*ProtoService
+	for _, file := range s.FilesInDir(dir) {
+		services = append(services, file.Services...)
+	}
+	return services
+}
+
+// GoName returns the Go identifier protoc-gen-go-grpc uses for the service
+func (s *ProtoService) GoName() string {
+	return goCamelCase(s.Name)
+}
+
+// GoName returns the Go identifier protoc-gen-go uses for the message
+func (m *ProtoMessage) GoName() string {
+	return goCamelCaseName(m.Name)
+}
+
+// Field returns the field with the given proto name
+func (m *ProtoMessage) Field(name string) *ProtoField {
+	for _, field := range m.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// GoName returns the Go struct field name protoc-gen-go uses for the field
+func (f *ProtoField) GoName() string {
+	return goCamelCase(f.Name)
+}
+
+// IsStreaming reports whether either side of the method streams
+func (m *ProtoMethod) IsStreaming() bool {
+	return m.ClientStreaming || m.ServerStreaming
+}
+
+func joinProtoName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func commentText(comment *proto.Comment) string {
+	if comment == nil {
+		return ""
+	}
+	lines := make([]string, 0, len(comment.Lines))
+	for _, line := range comment.Lines {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// fieldComment returns the comment above an rpc or field, or the comment
+// trailing it on the same line when there is none above
+func fieldComment(leading, trailing *proto.Comment) string {
+	if text := commentText(leading); text != "" {
+		return text
+	}
+	return commentText(trailing)
+}
+
+func optionSource(option *proto.Option) string {
+	if len(option.Constant.OrderedMap) == 0 && len(option.Constant.Array) == 0 {
+		return option.Constant.SourceRepresentation()
+	}
+	var parts []string
+	for _, entry := range option.Constant.OrderedMap {
+		parts = append(parts, entry.Name+": "+entry.SourceRepresentation())
+	}
+	return "{" + strings.Join(parts, "; ") + "}"
+}
+
+var scalarProtoTypes = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true,
+	"uint64": true, "sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true, "bool": true, "string": true, "bytes": true,
+}
+
+func isScalarProtoType(name string) bool {
+	return scalarProtoTypes[name]
+}
+
+// goCamelCaseName converts a possibly nested name like Outer.Inner into
+// the Go identifier Outer_Inner
+func goCamelCaseName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = goCamelCase(part)
+	}
+	return strings.Join(parts, "_")
+}
+
+// goCamelCase mirrors the identifier conversion of protoc-gen-go
+func goCamelCase(s string) string {
+	var b []byte
+	isLower := func(c byte) bool { return 'a' <= c && c <= 'z' }
+	isDigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isLower(s[i+1]):
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isLower(s[i+1]):
+		case isDigit(c):
+			b = append(b, c)
+		default:
+			if isLower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isLower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+func sanitizeGoPackageName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		return "pb"
+	}
+	if _, err := strconv.Atoi(name[:1]); err == nil {
+		return "_" + name
+	}
+	return name
+}
@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testProtoSet writes the proto files below a temporary proto root and
+// parses them
+func testProtoSet(t *testing.T, files map[string]string) *ProtoSet {
+	t.Helper()
+	manifest := defaultManifest("example.com/app")
+	manifest.Layout.Proto = t.TempDir()
+	for name, content := range files {
+		filePath := filepath.Join(manifest.Layout.Proto, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set, err := parseProtoSet(manifest)
+	if err != nil {
+		t.Fatalf("parseProtoSet() error = %v", err)
+	}
+	return set
+}
+
+var testProtoFiles = map[string]string{
+	"common/money.proto": `syntax = "proto3";
+package app.common;
+option go_package = "example.com/elsewhere/common;commonv1";
+
+message Money {
+  string currency = 1;
+  int64 units = 2;
+}
+
+enum Currency {
+  CURRENCY_UNSPECIFIED = 0;
+}
+`,
+	"courses/courses.proto": `syntax = "proto3";
+package app.courses;
+
+import "common/money.proto";
+import "google/protobuf/timestamp.proto";
+
+// Course is a published course
+// with sections
+message Course {
+  // Section groups lessons
+  message Section {
+    enum Kind {
+      KIND_UNSPECIFIED = 0;
+    }
+    Kind kind = 1;
+    Course owner = 2;
+  }
+  repeated Section sections = 1;
+  app.common.Money price = 2;
+  common.Money discount = 3;
+  .app.common.Currency currency = 4;
+  map<string, Section> by_name = 5;
+  google.protobuf.Timestamp created_at = 6;
+  optional string title = 7; // shown in listings
+  oneof cover {
+    string url = 8;
+    bytes image = 9;
+  }
+  Unknown missing = 10;
+}
+
+message GetCourseRequest { string id = 1; }
+`,
+}
+
+func TestParseProtoSetTypes(t *testing.T) {
+	set := testProtoSet(t, testProtoFiles)
+	course := set.Messages["app.courses.Course"]
+	if course == nil {
+		t.Fatalf("app.courses.Course not parsed, messages: %v", reflect.ValueOf(set.Messages).MapKeys())
+	}
+
+	tests := []struct {
+		field string
+		want  ProtoField
+	}{
+		{"sections", ProtoField{Name: "sections", Type: "app.courses.Course.Section", Number: 1, Repeated: true}},
+		{"price", ProtoField{Name: "price", Type: "app.common.Money", Number: 2}},
+		{"discount", ProtoField{Name: "discount", Type: "app.common.Money", Number: 3}},
+		{"currency", ProtoField{Name: "currency", Type: "app.common.Currency", Number: 4}},
+		{"by_name", ProtoField{Name: "by_name", Type: "app.courses.Course.Section", Number: 5, KeyType: "string"}},
+		{"created_at", ProtoField{Name: "created_at", Type: "google.protobuf.Timestamp", Number: 6}},
+		{"title", ProtoField{Name: "title", Type: "string", Number: 7, Optional: true, Comment: "shown in listings"}},
+		{"url", ProtoField{Name: "url", Type: "string", Number: 8}},
+		{"image", ProtoField{Name: "image", Type: "bytes", Number: 9}},
+		{"missing", ProtoField{Name: "missing", Type: "Unknown", Number: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			field := course.Field(tt.field)
+			if field == nil {
+				t.Fatalf("field %s not found", tt.field)
+			}
+			if *field != tt.want {
+				t.Errorf("field = %+v, want %+v", *field, tt.want)
+			}
+		})
+	}
+
+	section := set.Messages["app.courses.Course.Section"]
+	if section == nil {
+		t.Fatal("nested message app.courses.Course.Section not parsed")
+	}
+	if got := section.Field("kind").Type; got != "app.courses.Course.Section.Kind" {
+		t.Errorf("nested enum field type = %s", got)
+	}
+	if got := section.Field("owner").Type; got != "app.courses.Course" {
+		t.Errorf("enclosing message field type = %s", got)
+	}
+	if section.Comment != "Section groups lessons" || course.Comment != "Course is a published course\nwith sections" {
+		t.Errorf("message comments = %q, %q", course.Comment, section.Comment)
+	}
+}
+
+func TestProtoSetGoType(t *testing.T) {
+	set := testProtoSet(t, testProtoFiles)
+	tests := []struct {
+		fullName string
+		want     string
+		path     string
+		wantErr  bool
+	}{
+		{fullName: "app.courses.Course", want: "coursespb.Course", path: "example.com/app/protogen/courses"},
+		{fullName: "app.courses.Course.Section", want: "coursespb.Course_Section", path: "example.com/app/protogen/courses"},
+		{fullName: "app.courses.Course.Section.Kind", want: "coursespb.Course_Section_Kind", path: "example.com/app/protogen/courses"},
+		{fullName: "app.common.Money", want: "commonpb.Money", path: "example.com/app/protogen/common"},
+		{fullName: "google.protobuf.Timestamp", want: "timestamppb.Timestamp", path: "google.golang.org/protobuf/types/known/timestamppb"},
+		{fullName: "google.api.HttpBody", want: "httpbody.HttpBody", path: "google.golang.org/genproto/googleapis/api/httpbody"},
+		{fullName: "app.courses.Missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fullName, func(t *testing.T) {
+			got, err := set.GoType(tt.fullName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GoType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.String() != tt.want || got.Import.Path != tt.path {
+				t.Errorf("GoType() = %s from %s, want %s from %s", got, got.Import.Path, tt.want, tt.path)
+			}
+		})
+	}
+}
+
+func TestProtoSetVendoredGoImport(t *testing.T) {
+	files := map[string]string{
+		"google/api/annotations.proto": "syntax = \"proto3\";\npackage google.api;\noption go_package = \"google.golang.org/genproto/googleapis/api/annotations;annotations\";\n",
+		"billing/invoice.proto":        "syntax = \"proto3\";\npackage app.billing;\noption go_package = \"github.com/acme/billing;billingv1\";\n",
+		"my-dir/thing.proto":           "syntax = \"proto3\";\npackage app.thing;\n",
+	}
+	set := testProtoSet(t, files)
+	want := map[string]GoImport{
+		"google/api/annotations.proto": {Alias: "annotationspb", Path: "google.golang.org/genproto/googleapis/api/annotations"},
+		"billing/invoice.proto":        {Alias: "billingpb", Path: "example.com/app/protogen/billing"},
+		"my-dir/thing.proto":           {Alias: "my_dirpb", Path: "example.com/app/protogen/my-dir"},
+	}
+	for _, file := range set.Files {
+		if file.GoImport != want[file.Path] {
+			t.Errorf("%s GoImport = %+v, want %+v", file.Path, file.GoImport, want[file.Path])
+		}
+	}
+}
+
+func TestParseHTTPRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		option string
+		want   []HTTPRule
+	}{
+		{
+			name:   "get",
+			option: `option (google.api.http) = { get: "/v1/courses/{id}" };`,
+			want:   []HTTPRule{{Method: "GET", Path: "/v1/courses/{id}"}},
+		},
+		{
+			name:   "post with body",
+			option: `option (google.api.http) = { post: "/v1/courses" body: "*" response_body: "course" };`,
+			want:   []HTTPRule{{Method: "POST", Path: "/v1/courses", Body: "*", ResponseBody: "course"}},
+		},
+		{
+			name:   "custom",
+			option: `option (google.api.http) = { custom: { kind: "head" path: "/v1/courses" } };`,
+			want:   []HTTPRule{{Method: "HEAD", Path: "/v1/courses"}},
+		},
+		{
+			name: "additional bindings",
+			option: `option (google.api.http) = {
+      patch: "/v1/courses/{id}"
+      body: "course"
+      additional_bindings { put: "/v1/courses/{id}" body: "*" }
+      additional_bindings { post: "/v1/courses/{id}:update" body: "*" }
+    };`,
+			want: []HTTPRule{
+				{Method: "PATCH", Path: "/v1/courses/{id}", Body: "course"},
+				{Method: "PUT", Path: "/v1/courses/{id}", Body: "*"},
+				{Method: "POST", Path: "/v1/courses/{id}:update", Body: "*"},
+			},
+		},
+		{
+			name: "additional bindings list",
+			option: `option (google.api.http) = {
+      get: "/v1/courses/{id}"
+      additional_bindings: [{ get: "/v1/c/{id}" }, { get: "/v2/courses/{id}" }]
+    };`,
+			want: []HTTPRule{
+				{Method: "GET", Path: "/v1/courses/{id}"},
+				{Method: "GET", Path: "/v1/c/{id}"},
+				{Method: "GET", Path: "/v2/courses/{id}"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := testProtoSet(t, map[string]string{"courses/courses.proto": `syntax = "proto3";
+package app.courses;
+message Req {}
+service CourseService {
+  rpc Call(Req) returns (Req) {
+    ` + tt.option + `
+    option deprecated = true;
+  }
+}
+`})
+			method := set.Files[0].Services[0].Methods[0]
+			if !reflect.DeepEqual(method.HTTPRules, tt.want) {
+				t.Errorf("HTTPRules = %+v, want %+v", method.HTTPRules, tt.want)
+			}
+			if method.Options["deprecated"] != "true" {
+				t.Errorf("Options = %v, want deprecated kept", method.Options)
+			}
+		})
+	}
+}
+
+func TestProtoMethodComments(t *testing.T) {
+	set := testProtoSet(t, map[string]string{"courses/courses.proto": `syntax = "proto3";
+package app.courses;
+
+import "google/protobuf/empty.proto";
+
+message Req {}
+
+// CourseService manages courses
+service CourseService {
+  // GetCourse returns a course
+  //   by id
+  rpc GetCourse(Req) returns (Req);
+  rpc ListCourses(Req) returns (stream Req); // grpcframe:query SearchCourses
+  /* Upload streams files */
+  rpc Upload(stream Req) returns (google.protobuf.Empty);
+  rpc Chat(stream Req) returns (stream app.courses.Req) {}
+}
+`})
+	service := set.Files[0].Services[0]
+	if service.Comment != "CourseService manages courses" {
+		t.Errorf("service comment = %q", service.Comment)
+	}
+	tests := []struct {
+		name                       string
+		comment                    string
+		input, output              string
+		clientStream, serverStream bool
+	}{
+		{"GetCourse", "GetCourse returns a course\nby id", "app.courses.Req", "app.courses.Req", false, false},
+		{"ListCourses", "grpcframe:query SearchCourses", "app.courses.Req", "app.courses.Req", false, true},
+		{"Upload", "Upload streams files", "app.courses.Req", "google.protobuf.Empty", true, false},
+		{"Chat", "", "app.courses.Req", "app.courses.Req", true, true},
+	}
+	if len(service.Methods) != len(tests) {
+		t.Fatalf("got %d methods, want %d", len(service.Methods), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := service.Methods[i]
+			if method.Name != tt.name || method.Comment != tt.comment {
+				t.Errorf("method %s comment = %q, want %s %q", method.Name, method.Comment, tt.name, tt.comment)
+			}
+			if method.InputType != tt.input || method.OutputType != tt.output {
+				t.Errorf("types = %s -> %s, want %s -> %s", method.InputType, method.OutputType, tt.input, tt.output)
+			}
+			if method.ClientStreaming != tt.clientStream || method.ServerStreaming != tt.serverStream || method.IsStreaming() != (tt.clientStream || tt.serverStream) {
+				t.Errorf("streaming = %v/%v", method.ClientStreaming, method.ServerStreaming)
+			}
+		})
+	}
+}
+
+func TestParseProtoFileError(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "broken.proto")
+	if err := os.WriteFile(filePath, []byte("syntax = \"proto3\";\nmessage {"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := parseProtoFile(filePath, "broken.proto")
+	if err == nil || !strings.Contains(err.Error(), filePath) {
+		t.Errorf("parseProtoFile() error = %v, want it to name %s", err, filePath)
+	}
+}
+
+func TestGoCamelCase(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"course_id", "CourseId"},
+		{"CourseService", "CourseService"},
+		{"http_url_2x", "HttpUrl_2X"},
+		{"_private", "XPrivate"},
+		{"a__b", "A_B"},
+		{"field9name", "Field9Name"},
+		{"google.protobuf", "GoogleProtobuf"},
+		{"Outer.Inner", "Outer_Inner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := goCamelCase(tt.in); got != tt.want {
+				t.Errorf("goCamelCase(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+	if got := goCamelCaseName("Course.section_item"); got != "Course_SectionItem" {
+		t.Errorf("goCamelCaseName() = %q", got)
+	}
+}
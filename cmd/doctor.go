@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the toolchain and project health",
+	Long: "Checks that the binaries grpcframe runs are installed in a compatible version, that go.mod matches " +
+		manifestFileName + ", that the layout directories exist and that .env holds the database settings. " +
+		"Every problem is printed with a hint on how to fix it",
+	Run: func(cmd *cobra.Command, args []string) {
+		if problems := runDoctor(); problems > 0 {
+			pkg.Red.Printf("%d problems found\n", problems)
+			os.Exit(1)
+		}
+		pkg.SuccessLog("Everything looks good")
+	},
+}
+
+// doctorCheck is the outcome of a single health check
+type doctorCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+	Fix    string
+}
+
+// toolRequirement is a binary grpcframe runs and the oldest version it supports
+type toolRequirement struct {
+	Binary     string
+	MinVersion string
+	Reason     string
+}
+
+// versionPattern finds the first dotted version number in --version output
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// minToolVersions are the oldest supported versions of the external tools
+var minToolVersions = map[string]string{
+	"protoc":                  "3.12.0", // --experimental_allow_proto3_optional
+	"buf":                     "1.32.0", // buf.yaml and buf.gen.yaml v2
+	"protoc-gen-go":           "1.28.0",
+	"protoc-gen-go-grpc":      "1.3.0",
+	"protoc-gen-grpc-gateway": "2.0.0", // the gateway imports grpc-gateway/v2
+	"protoc-gen-openapiv2":    "2.0.0",
+	"sqlc":                    "1.20.0",
+}
+
+// runDoctor prints every check and returns the number of problems
+func runDoctor() int {
+	var checks []doctorCheck
+
+	manifest, err := loadManifest()
+	if err != nil {
+		checks = append(checks, doctorCheck{
+			Name:   "project manifest",
+			Detail: err.Error(),
+			Fix:    "run grpcframe doctor from the project root, or fix " + manifestFileName,
+		})
+		return printDoctorChecks(checks)
+	}
+
+	preferProjectTools()
+	checks = append(checks, checkGoToolchain())
+	for _, requirement := range toolRequirements(manifest) {
+		checks = append(checks, checkTool(requirement))
+	}
+	checks = append(checks, checkGoModule(manifest))
+	checks = append(checks, checkLayout(manifest)...)
+	if manifest.Features.Migrations {
+		checks = append(checks, checkEnvFile())
+	}
+	return printDoctorChecks(checks)
+}
+
+// toolRequirements returns the binaries the enabled features run
+func toolRequirements(manifest *Manifest) []toolRequirement {
+	engine := manifest.Protogen.Engine
+	requirements := []toolRequirement{{Binary: engine, MinVersion: minToolVersions[engine], Reason: "protogen engine"}}
+	for _, plugin := range manifest.Protogen.Plugins {
+		binary := "protoc-gen-" + plugin.Name
+		if plugin.Path != "" {
+			binary = plugin.Path
+		}
+		requirements = append(requirements, toolRequirement{
+			Binary:     binary,
+			MinVersion: minToolVersions["protoc-gen-"+plugin.Name],
+			Reason:     "protogen plugin " + plugin.Name,
+		})
+	}
+	if manifest.Features.SQLC {
+		requirements = append(requirements, toolRequirement{Binary: "sqlc", MinVersion: minToolVersions["sqlc"], Reason: "sqlc feature"})
+	}
+	return requirements
+}
+
+func checkTool(requirement toolRequirement) doctorCheck {
+	check := doctorCheck{Name: requirement.Binary}
+	hint := pluginInstallHints[requirement.Binary]
+	if requirement.Binary == "sqlc" {
+		hint = "go install github.com/sqlc-dev/sqlc/cmd/sqlc@latest"
+	}
+	if tools, err := pinnedTools(); err == nil {
+		for _, tool := range tools {
+			if path.Base(tool) == requirement.Binary {
+				hint = "grpcframe tools install (builds the version pinned in " + toolsFile + ")"
+			}
+		}
+	}
+
+	if _, err := exec.LookPath(requirement.Binary); err != nil {
+		check.Detail = fmt.Sprintf("not found in PATH (needed by the %s)", requirement.Reason)
+		check.Fix = hint
+		return check
+	}
+
+	output, err := exec.Command(requirement.Binary, "--version").CombinedOutput()
+	if err != nil {
+		// Not every plugin has a version flag, presence is enough then
+		check.OK, check.Detail = true, "installed, version unknown"
+		return check
+	}
+	version := parseVersion(string(output))
+	if version == "" {
+		check.OK, check.Detail = true, "installed, version unknown"
+		return check
+	}
+	if requirement.MinVersion != "" && compareVersions(version, requirement.MinVersion) < 0 {
+		check.Detail = fmt.Sprintf("version %s is older than the supported %s", version, requirement.MinVersion)
+		check.Fix = hint
+		return check
+	}
+	check.OK, check.Detail = true, version
+	return check
+}
+
+// checkGoToolchain compares the installed Go with the go directive of go.mod
+func checkGoToolchain() doctorCheck {
+	check := doctorCheck{Name: "go"}
+	output, err := exec.Command("go", "version").Output()
+	if err != nil {
+		check.Detail = "not found in PATH"
+		check.Fix = "install Go from https://go.dev/dl/"
+		return check
+	}
+	// go version go1.24.5 linux/amd64
+	installed := parseVersion(string(output))
+
+	required := goModDirective("go.mod")
+	if required != "" && compareVersions(installed, required) < 0 {
+		check.Detail = fmt.Sprintf("version %s is older than go %s required by go.mod", installed, required)
+		check.Fix = "install Go " + required + " or newer from https://go.dev/dl/"
+		return check
+	}
+	check.OK, check.Detail = true, installed
+	return check
+}
+
+// checkGoModule compares the module path of go.mod with the manifest
+func checkGoModule(manifest *Manifest) doctorCheck {
+	check := doctorCheck{Name: "go.mod"}
+	moduleName, err := getTargetModuleName()
+	if err != nil {
+		check.Detail = err.Error()
+		check.Fix = "go mod init " + manifest.Module
+		return check
+	}
+	if moduleName != manifest.Module {
+		check.Detail = fmt.Sprintf("module %s does not match %s in %s", moduleName, manifest.Module, manifestFileName)
+		check.Fix = fmt.Sprintf("set module: %s in %s, or run go mod edit -module %s", moduleName, manifestFileName, manifest.Module)
+		return check
+	}
+	if _, err := os.Stat(manifestFileName); os.IsNotExist(err) {
+		check.OK, check.Detail = true, moduleName+" (no "+manifestFileName+", using the default layout)"
+		return check
+	}
+	check.OK, check.Detail = true, moduleName
+	return check
+}
+
+// checkLayout verifies that the directories of the enabled features exist
+func checkLayout(manifest *Manifest) []doctorCheck {
+	layout := manifest.Layout
+	dirs := []string{layout.Proto, layout.Protogen, layout.RPC}
+	if manifest.Features.Gateway {
+		dirs = append(dirs, layout.Gateway)
+	}
+	if manifest.Features.SQLC {
+		dirs = append(dirs, layout.Repo, layout.Schema, layout.Queries)
+	}
+	if manifest.Features.Migrations {
+		dirs = append(dirs, layout.Migrations)
+	}
+	if manifest.Features.Swagger {
+		dirs = append(dirs, layout.Swagger)
+	}
+
+	var checks []doctorCheck
+	for _, dir := range dirs {
+		check := doctorCheck{Name: "directory " + dir}
+		info, err := os.Stat(dir)
+		switch {
+		case os.IsNotExist(err):
+			check.Detail = "missing"
+			check.Fix = "mkdir -p " + dir + ", or point the layout in " + manifestFileName + " at the right directory"
+		case err != nil:
+			check.Detail = err.Error()
+		case !info.IsDir():
+			check.Detail = "is a file, not a directory"
+			check.Fix = "move the file away, or point the layout in " + manifestFileName + " at the right directory"
+		default:
+			check.OK = true
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkEnvFile verifies that .env holds the keys loadDBConfig requires
+func checkEnvFile() doctorCheck {
+	check := doctorCheck{Name: ".env"}
+	env, err := readEnvFile(".env")
+	if err != nil {
+		check.Detail = err.Error()
+		check.Fix = "create .env with " + strings.Join(dbEnvKeys, ", ") + " (and optionally DB_PASSWORD, DB_SSL_MODE)"
+		return check
+	}
+
+	var missing []string
+	for _, key := range dbEnvKeys {
+		if env[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		check.Detail = "missing or empty " + strings.Join(missing, ", ")
+		check.Fix = "add " + strings.Join(missing, "=..., ") + "=... to .env"
+		return check
+	}
+	check.OK, check.Detail = true, "database settings present"
+	return check
+}
+
+func printDoctorChecks(checks []doctorCheck) int {
+	problems := 0
+	for _, check := range checks {
+		if check.OK {
+			pkg.Green.Printf("✓ %-32s", check.Name)
+			fmt.Println(check.Detail)
+			continue
+		}
+		problems++
+		pkg.Red.Printf("✗ %-32s", check.Name)
+		fmt.Println(check.Detail)
+		if check.Fix != "" {
+			pkg.Yellow.Printf("  fix: %s\n", check.Fix)
+		}
+	}
+	return problems
+}
+
+// goModDirective returns the go version required by a go.mod file
+func goModDirective(goModPath string) string {
+	content, err := os.ReadFile(goModPath)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "go ") {
+			return parseVersion(strings.TrimPrefix(line, "go "))
+		}
+	}
+	return ""
+}
+
+// parseVersion extracts the first x.y[.z] version from text
+func parseVersion(text string) string {
+	return versionPattern.FindString(text)
+}
+
+// compareVersions compares two dotted versions numerically
+func compareVersions(a, b string) int {
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < 3; i++ {
+		var x, y int
+		if i < len(partsA) {
+			x, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			y, _ = strconv.Atoi(partsB[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/spf13/cobra"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 var gatewayRegisterCmd = &cobra.Command{
@@ -24,14 +23,22 @@ var gatewayRegisterCmd = &cobra.Command{
 type GatewayRegistration struct {
 	ModuleName   string
 	ServiceName  string
-	PbImportPath string
-	PbPackage    string
+	PbImport     GoImport
 	RegisterFunc string
+	Streaming    bool
 }
 
 func registerGatewayEndpoints() error {
-	// Discover all modules in app/rpc directory
-	modules, err := discoverGatewayModules()
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	if !manifest.Features.Gateway {
+		return fmt.Errorf("the gateway feature is disabled in %s", manifestFileName)
+	}
+
+	// Discover all modules in the rpc directory
+	modules, err := discoverGatewayModules(manifest)
 	if err != nil {
 		return fmt.Errorf("failed to discover modules: %w", err)
 	}
@@ -42,13 +49,13 @@ func registerGatewayEndpoints() error {
 	}
 
 	// Generate updated gateway.go content
-	gatewayContent, err := generateGatewayContent(modules)
+	gatewayContent, err := generateGatewayContent(manifest, modules)
 	if err != nil {
 		return fmt.Errorf("failed to generate gateway content: %w", err)
 	}
 
 	// Write updated gateway.go
-	gatewayPath := filepath.Join("app", "gateway", "gateway.go")
+	gatewayPath := filepath.Join(manifest.Layout.Gateway, "gateway.go")
 	if err := writeFile(gatewayPath, gatewayContent); err != nil {
 		return fmt.Errorf("failed to write gateway file: %w", err)
 	}
@@ -69,342 +76,196 @@ func registerGatewayEndpoints() error {
 	return nil
 }
 
-func discoverGatewayModules() ([]GatewayRegistration, error) {
-	var registrations []GatewayRegistration
-
-	// Get target module name from go.mod
-	targetModule, err := getTargetModuleName()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get target module name: %w", err)
-	}
-
-	rpcPath := filepath.Join("app", "rpc")
-	if _, err := os.Stat(rpcPath); os.IsNotExist(err) {
-		return registrations, nil
-	}
-
-	// Read all directories in app/rpc (excluding server.go)
-	entries, err := os.ReadDir(rpcPath)
+func discoverGatewayModules(manifest *Manifest) ([]GatewayRegistration, error) {
+	services, err := discoverModuleServices(manifest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read rpc directory: %w", err)
+		return nil, err
 	}
+	return gatewayRegistrations(services), nil
+}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue // Skip files like server.go
+// gatewayRegistrations returns the gateway handlers of the services that
+// have http bindings
+func gatewayRegistrations(services []moduleService) []GatewayRegistration {
+	registrations := make([]GatewayRegistration, 0, len(services))
+	for _, service := range services {
+		// protoc-gen-grpc-gateway only emits a handler file for services
+		// with at least one http binding
+		bound, streaming := gatewayBindings(service.Descriptor)
+		if bound == 0 {
+			pkg.InfoLog("Skipping", service.Descriptor.Name, "(no google.api.http bindings)")
+			continue
 		}
 
-		moduleName := entry.Name()
+		pbImport := service.Descriptor.File.GoImport
+		registrations = append(registrations, GatewayRegistration{
+			ModuleName:   service.ModuleName,
+			ServiceName:  service.Descriptor.Name,
+			PbImport:     pbImport,
+			RegisterFunc: fmt.Sprintf("%s.Register%sHandlerFromEndpoint", pbImport.Alias, service.Descriptor.GoName()),
+			Streaming:    streaming,
+		})
+	}
+	return registrations
+}
 
-		// Check if service.go exists
-		servicePath := filepath.Join(rpcPath, moduleName, "service.go")
-		if _, err := os.Stat(servicePath); os.IsNotExist(err) {
+// gatewayBindings counts the methods of a service exposed over http and
+// reports whether any of them streams. Streaming methods are proxied by
+// grpc-gateway as newline-delimited JSON.
+func gatewayBindings(service *ProtoService) (int, bool) {
+	bound, streaming := 0, false
+	for _, method := range service.Methods {
+		if len(method.HTTPRules) == 0 {
 			continue
 		}
-
-		// Extract gateway registration information
-		gatewayInfo, err := extractGatewayInfo(servicePath, moduleName, targetModule)
-		if err != nil {
-			pkg.Red.Printf("Warning: failed to extract gateway info for %s: %v\n", moduleName, err)
-			continue
+		bound++
+		if method.IsStreaming() {
+			streaming = true
 		}
-
-		registrations = append(registrations, gatewayInfo)
 	}
-
-	return registrations, nil
+	return bound, streaming
 }
 
-func extractGatewayInfo(servicePath, moduleName, targetModule string) (GatewayRegistration, error) {
-	packageName := strings.ToLower(moduleName)
-	serviceName := toPascalCase(moduleName)
-	pbPackage := fmt.Sprintf("%spb", packageName)
-
-	return GatewayRegistration{
-		ModuleName:   moduleName,
-		ServiceName:  serviceName,
-		PbImportPath: fmt.Sprintf("%s/protogen/%s", targetModule, packageName),
-		PbPackage:    pbPackage,
-		RegisterFunc: fmt.Sprintf("%s.Register%sServiceHandlerFromEndpoint", pbPackage, serviceName),
-	}, nil
+func generateGatewayContent(manifest *Manifest, registrations []GatewayRegistration) (string, error) {
+	return renderGoTemplate("project/gateway.go.tmpl", &GatewayTemplateData{
+		Manifest:      manifest,
+		Registrations: registrations,
+	})
 }
 
-func generateGatewayContent(registrations []GatewayRegistration) (string, error) {
-	_, err := getTargetModuleName()
-	if err != nil {
-		return "", err
-	}
-
-	var content strings.Builder
-
-	// Package declaration and imports
-	content.WriteString(`package gateway
-
-import (
-    "context"
-    "errors"
-    "fmt"
-    "net/http"
-    "os"
-    "os/signal"
-    "syscall"
-    "time"
-
-    "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
-    "google.golang.org/grpc"
-    "google.golang.org/grpc/credentials/insecure"
-`)
-
-	// Add proto imports
-	for _, reg := range registrations {
-		content.WriteString(fmt.Sprintf("    %s \"%s\"\n", reg.PbPackage, reg.PbImportPath))
+// Imports returns the unique handler and protobuf imports of the registrations
+func (d *ServerTemplateData) Imports() []GoImport {
+	var imports []GoImport
+	for _, reg := range d.Registrations {
+		for _, imp := range []GoImport{reg.HandlerImport, reg.PbImport} {
+			if !containsImport(imports, imp) {
+				imports = append(imports, imp)
+			}
+		}
 	}
-
-	content.WriteString(`    "github.com/rakyll/statik/fs"
-    "github.com/rs/cors"
-    "github.com/sirupsen/logrus"
-)
-
-type Gateway struct {
-    logger     *logrus.Logger
-    grpcAddr   string
-    httpAddr   string
-    swaggerDir string
-}
-
-func NewGateway(logger *logrus.Logger, grpcAddr, httpAddr string) *Gateway {
-    return &Gateway{
-        logger:     logger,
-        grpcAddr:   grpcAddr,
-        httpAddr:   httpAddr,
-        swaggerDir: "../doc/swagger",
-    }
+	return imports
 }
 
-func (g *Gateway) Start() error {
-    ctx, cancel := context.WithCancel(context.Background())
-    defer cancel()
-
-    gwMux := runtime.NewServeMux(
-        runtime.WithErrorHandler(g.errorHandler),
-        runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{}),
-        runtime.WithIncomingHeaderMatcher(g.headerMatcher),
-    )
-
-    opts := []grpc.DialOption{
-        grpc.WithTransportCredentials(insecure.NewCredentials()),
-        grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(25 * 1024 * 1024)), // 25MB
-    }
-
-`)
-
-	// Add service registrations
-	for _, reg := range registrations {
-		content.WriteString(fmt.Sprintf(`    if err := %s(ctx, gwMux, g.grpcAddr, opts); err != nil {
-        return fmt.Errorf("failed to register %s service gateway: %%w", err)
-    }
-
-`, reg.RegisterFunc, strings.ToLower(reg.ServiceName)))
+// HasStreaming reports whether any registered service streams over http
+func (d *GatewayTemplateData) HasStreaming() bool {
+	for _, reg := range d.Registrations {
+		if reg.Streaming {
+			return true
+		}
 	}
-
-	// Add the rest of the Start method
-	content.WriteString(`    statikFS, err := fs.New()
-    if err != nil {
-        return fmt.Errorf("statik filesystem error: %w", err)
-    }
-
-    // Create main mux router
-    mux := http.NewServeMux()
-    mux.Handle("/", gwMux)
-    mux.Handle("/swagger/", http.StripPrefix("/swagger/", http.FileServer(statikFS)))
-    mux.HandleFunc("/healthz", g.healthCheck)
-
-    // Configure CORS
-    corsHandler := cors.New(cors.Options{
-        AllowedOrigins:   []string{"*"},
-        AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
-        AllowedHeaders:   []string{"*"},
-        AllowCredentials: true,
-    }).Handler(mux)
-
-    // Configure HTTP server
-    server := &http.Server{
-        Addr:         g.httpAddr,
-        Handler:      corsHandler,
-        ReadTimeout:  15 * time.Second,
-        WriteTimeout: 30 * time.Second,
-        IdleTimeout:  60 * time.Second,
-    }
-
-    // Graceful shutdown
-    go func() {
-        sigint := make(chan os.Signal, 1)
-        signal.Notify(sigint, syscall.SIGINT, syscall.SIGTERM)
-        <-sigint
-
-        g.logger.Info("Shutting down HTTP gateway...")
-        if err := server.Shutdown(ctx); err != nil {
-            g.logger.WithError(err).Error("HTTP gateway shutdown error")
-        }
-    }()
-
-    g.logger.Infof("Starting HTTP gateway on %s (gRPC backend: %s)", g.httpAddr, g.grpcAddr)
-    if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) && err != nil {
-        return fmt.Errorf("HTTP gateway start error: %w", err)
-    }
-
-    return nil
-}
-
-func (g *Gateway) errorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
-    g.logger.WithError(err).Error("gateway error")
-    runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
-}
-
-func (g *Gateway) headerMatcher(key string) (string, bool) {
-    switch key {
-    case "X-Request-ID", "X-Correlation-ID":
-        return key, true
-    default:
-        return runtime.DefaultHeaderMatcher(key)
-    }
-}
-
-func (g *Gateway) healthCheck(w http.ResponseWriter, r *http.Request) {
-    w.WriteHeader(http.StatusOK)
-    _, err := w.Write([]byte("OK"))
-    if err != nil {
-        return
-    }
+	return false
 }
-`)
 
-	return content.String(), nil
+// Imports returns the unique protobuf imports of the registrations
+func (d *GatewayTemplateData) Imports() []GoImport {
+	var imports []GoImport
+	for _, reg := range d.Registrations {
+		if !containsImport(imports, reg.PbImport) {
+			imports = append(imports, reg.PbImport)
+		}
+	}
+	return imports
 }
 
 // Also update the server registration to use the correct path
-func generateServerContent(registrations []ServiceRegistration) (string, error) {
-	targetModule, err := getTargetModuleName()
+func generateServerContent(manifest *Manifest, registrations []ServiceRegistration) (string, error) {
+	data := &ServerTemplateData{Manifest: manifest, Registrations: registrations}
+	content, err := renderGoTemplate("project/server.go.tmpl", data)
 	if err != nil {
 		return "", err
 	}
 
-	var content strings.Builder
-
-	content.WriteString(`package rpc
-
-import (
-    db "` + targetModule + `/internal/repo"
-`)
-
-	for _, reg := range registrations {
-		content.WriteString(fmt.Sprintf("    %ssv \"%s/app/rpc/%s\"\n",
-			strings.ToLower(reg.ServiceName), targetModule, reg.ModuleName))
-		content.WriteString(fmt.Sprintf("    %s \"%s\"\n",
-			reg.PbPackage, reg.PbImportPath))
+	// Constructor arguments are Server fields, so they are wired against
+	// the rendered struct
+	wired := false
+	g, err := parseGoSource("server.go", []byte(content))
+	if err != nil {
+		return "", err
 	}
-
-	content.WriteString(`    "github.com/sirupsen/logrus"
-    "google.golang.org/grpc"
-    "net"
-)
-
-// Server implements the gRPC services
-type Server struct {
-`)
-
-	for _, reg := range registrations {
-		content.WriteString(fmt.Sprintf("    %s.Unimplemented%sServiceServer\n",
-			reg.PbPackage, reg.ServiceName))
+	receiver := ""
+	if run := findServerMethod(g.file, "Run"); run != nil && len(run.Recv.List[0].Names) > 0 {
+		receiver = run.Recv.List[0].Names[0].Name
 	}
-
-	content.WriteString(`    store  *db.Store
-    logger *logrus.Logger
-}
-
-func NewServer(
-    db *db.Store,
-    logger *logrus.Logger,
-) *Server {
-    return &Server{
-        store:  db,
-        logger: logger,
-    }
-}
-
-// Run starts the gRPC server
-func (s *Server) Run() error {
-    listener, err := net.Listen("tcp", ":9001")
-    if err != nil {
-        panic(err.Error())
-    }
-    grpcServer := grpc.NewServer()
-
-`)
-
-	for _, reg := range registrations {
-		content.WriteString(fmt.Sprintf("    %s := %ssv.New%sService()\n",
-			reg.ServiceVar, strings.ToLower(reg.ServiceName), reg.ServiceName))
+	for i := range data.Registrations {
+		reg := &data.Registrations[i]
+		if len(reg.Params) == 0 {
+			continue
+		}
+		if reg.Args, err = wireArgs(g, findServerStruct(g.file), manifest.ImportPath(manifest.Layout.RPC), receiver, *reg); err != nil {
+			return "", err
+		}
+		wired = true
 	}
-
-	content.WriteString("\n    // Register services with gRPC server\n")
-
-	for _, reg := range registrations {
-		content.WriteString(fmt.Sprintf("    %s(grpcServer, %s)\n",
-			reg.RegisterFunc, reg.ServiceVar))
+	if !wired {
+		return content, nil
 	}
-
-	content.WriteString(`
-    s.logger.Println("Starting server on port 9001")
-    err = grpcServer.Serve(listener)
-    if err != nil {
-        s.logger.Fatal(err.Error())
-        return err
-    }
-    return nil
-}`)
-
-	return content.String(), nil
+	return renderGoTemplate("project/server.go.tmpl", data)
 }
 
 // Update the register services function to use correct path
 func registerServices() error {
-	// Discover all modules in app/rpc directory
-	modules, err := discoverModules()
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+
+	// Discover all modules in the rpc directory
+	modules, err := discoverModules(manifest)
 	if err != nil {
 		return fmt.Errorf("failed to discover modules: %w", err)
 	}
 
-	if len(modules) == 0 {
-		pkg.InfoLog("No modules found to register")
+	serverPath := filepath.Join(manifest.Layout.RPC, "server.go")
+	src, err := readFile(serverPath)
+	if os.IsNotExist(err) {
+		// No server yet, render it from the template
+		serverContent, err := generateServerContent(manifest, modules)
+		if err != nil {
+			return fmt.Errorf("failed to generate server content: %w", err)
+		}
+		if err := writeFile(serverPath, serverContent); err != nil {
+			return fmt.Errorf("failed to write server file: %w", err)
+		}
+		pkg.InfoLog(fmt.Sprintf("Successfully registered %d services", len(modules)))
 		return nil
 	}
+	if err != nil {
+		return fmt.Errorf("failed to read server file: %w", err)
+	}
 
-	// Generate updated server.go content
-	serverContent, err := generateServerContent(modules)
+	var prune func(string) bool
+	if registerPrune {
+		prune = func(string) bool { return true }
+	}
+	updated, result, err := updateServerFile(serverPath, src, manifest, modules, prune)
 	if err != nil {
-		return fmt.Errorf("failed to generate server content: %w", err)
+		return err
+	}
+	for _, name := range result.Added {
+		logChange("Registered", "Would register", name)
+	}
+	for _, name := range result.Pruned {
+		logChange("Removed", "Would remove", "stale registration", name)
+	}
+	for _, name := range result.Rewired {
+		logChange("Updated", "Would update", "the arguments of", name)
+	}
+	for _, name := range result.Stale {
+		pkg.WarningLog("Stale registration", name, "(its module or service no longer exists)")
+	}
+	if len(result.Stale) > 0 {
+		pkg.InfoLog("Run with --prune to remove stale registrations")
 	}
 
-	// Write updated server.go - note the correct path
-	serverPath := filepath.Join("app", "rpc", "server.go")
-	if err := writeFile(serverPath, serverContent); err != nil {
+	if string(updated) == string(src) {
+		pkg.InfoLog("server.go is up to date")
+		return nil
+	}
+	if err := writeFile(serverPath, string(updated)); err != nil {
 		return fmt.Errorf("failed to write server file: %w", err)
 	}
 
-	// Format the file
-	// TODO: Replace exec.Command with proper implementation
-	// Original line: // TODO: Replace exec.Command with proper implementation
-	// TODO: Replace exec.Command with proper implementation
-	// Original line: // Original line: 	if err := exec.Command("gofmt", "-w", serverPath).Run(); err != nil {
-
-	// Run go mod tidy
-	// TODO: Replace exec.Command with proper implementation
-	// Original line: // TODO: Replace exec.Command with proper implementation
-	// TODO: Replace exec.Command with proper implementation
-	// Original line: // Original line: 	if err := exec.Command("go", "mod", "tidy").Run(); err != nil {
-
-	pkg.InfoLog(fmt.Sprintf("Successfully registered %d services", len(modules)))
+	pkg.InfoLog(fmt.Sprintf("Successfully registered %d services", len(result.Added)))
 	return nil
 }
 
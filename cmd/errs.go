@@ -0,0 +1,13 @@
+package cmd
+
+// errsLibrary maps the files of the errs package to their templates
+var errsLibrary = map[string]string{
+	"errs.go": "project/errs.go.tmpl",
+}
+
+// writeErrsPackage writes the errs package the bound handlers and the
+// server interceptor call
+func writeErrsPackage(manifest *Manifest, state *GeneratedState) error {
+	_, err := writeLibrary(state, manifest.Layout.Errs, errsLibrary)
+	return err
+}
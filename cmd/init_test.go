@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProjectFilesFollowManifest(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("GRPCFRAME_TEMPLATES", t.TempDir())
+	manifest := defaultManifest("example.com/app")
+	manifest.Layout.Errs = "internal/errs"
+	manifest.Layout.Convert = "internal/convert"
+	manifest.Layout.Env = "internal/env"
+	manifest.Database.Package = "repo"
+	config := &ProjectConfig{ModuleName: manifest.Module, Manifest: manifest}
+
+	files := getFileTemplates(config)
+	for _, path := range []string{"internal/errs/errs.go", "internal/convert/convertor.go", "internal/env/envs.go"} {
+		if _, ok := files[filepath.FromSlash(path)]; !ok {
+			t.Errorf("init does not write %s", path)
+		}
+	}
+
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{
+			file: filepath.Join(manifest.Layout.RPC, "server.go"),
+			want: []string{
+				`repo "example.com/app/internal/repo"`,
+				`"example.com/app/internal/errs"`,
+				"store  *repo.Store",
+				"store *repo.Store,",
+			},
+		},
+		{
+			file: "cmd/main.go",
+			want: []string{
+				`repo "example.com/app/internal/repo"`,
+				`"example.com/app/internal/env"`,
+				"dbStore := repo.NewStore(dbConn)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			file := files[tt.file]
+			content, err := renderGoTemplate(file.Template, file.Data)
+			if err != nil {
+				t.Fatalf("renderGoTemplate(%s) error = %v", file.Template, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(content, want) {
+					t.Errorf("%s does not contain %s:\n%s", tt.file, want, content)
+				}
+			}
+			if strings.Contains(content, "pkg/") || strings.Contains(content, "db.") {
+				t.Errorf("%s still refers to the default layout:\n%s", tt.file, content)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+)
+
+// keepPartial disables the rollback of failed commands
+var keepPartial bool
+
+// journalEntry is the state of a path before a command first changed it
+type journalEntry struct {
+	Path    string
+	Existed bool
+	Content []byte
+	Mode    fs.FileMode
+	// CreatedDir marks a directory the command created
+	CreatedDir bool
+	// Snapshot lists the files of a snapshotted directory, files outside
+	// the list were added by the command
+	Snapshot map[string]bool
+}
+
+// journal records the filesystem changes of a command so they can be
+// undone when a later step fails
+type journal struct {
+	entries []journalEntry
+	seen    map[string]bool
+	// written lists the files the command wrote, in order
+	written []string
+}
+
+// activeJournal is set while a command runs under withRollback
+var activeJournal *journal
+
+// withRollback runs fn while journaling every write, delete and mkdir. The
+// given files and directories are snapshotted up front because external
+// commands such as protoc and go mod tidy change them behind our back.
+// When fn fails the previous state is restored, unless --keep-partial is set.
+func withRollback(snapshots []string, fn func() error) error {
+	if dryRun || activeJournal != nil {
+		return fn()
+	}
+
+	j := &journal{seen: map[string]bool{}}
+	for _, path := range snapshots {
+		if err := j.snapshot(path); err != nil {
+			return err
+		}
+	}
+
+	activeJournal = j
+	err := fn()
+	activeJournal = nil
+	if err == nil {
+		return nil
+	}
+
+	if keepPartial {
+		pkg.WarningLog("Keeping partial changes (--keep-partial)")
+		return err
+	}
+	pkg.WarningLog(fmt.Sprintf("Rolling back %d changes...", len(j.entries)))
+	if rollbackErr := j.rollback(); rollbackErr != nil {
+		return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+	}
+	pkg.InfoLog("Rolled back to the previous state")
+	return err
+}
+
+// snapshot records a file, or every file below a directory
+func (j *journal) snapshot(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if filepath.Ext(path) == "" {
+			j.recordDir(path)
+			return nil
+		}
+		return j.recordFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to snapshot %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return j.recordFile(path)
+	}
+
+	files := map[string]bool{}
+	err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		files[file] = true
+		return j.recordFile(file)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to snapshot %s: %w", path, err)
+	}
+	j.entries = append(j.entries, journalEntry{Path: filepath.Clean(path), Existed: true, Snapshot: files})
+	return nil
+}
+
+// recordFile stores the content of a file before its first change
+func (j *journal) recordFile(path string) error {
+	path = filepath.Clean(path)
+	if j.seen[path] {
+		return nil
+	}
+
+	entry := journalEntry{Path: path}
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to journal %s: %w", path, err)
+		}
+		entry.Existed, entry.Content, entry.Mode = true, content, info.Mode().Perm()
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("failed to journal %s: %w", path, err)
+	}
+	j.seen[path] = true
+	j.entries = append(j.entries, entry)
+	return nil
+}
+
+// recordWrite journals a file before the command writes it
+func (j *journal) recordWrite(path string) error {
+	if err := j.recordFile(path); err != nil {
+		return err
+	}
+	if path = filepath.Clean(path); !slices.Contains(j.written, path) {
+		j.written = append(j.written, path)
+	}
+	return nil
+}
+
+// recordDir records the topmost directory a MkdirAll of dir would create
+func (j *journal) recordDir(dir string) {
+	created := ""
+	for current := filepath.Clean(dir); ; current = filepath.Dir(current) {
+		if _, err := os.Stat(current); err == nil {
+			break
+		}
+		created = current
+		if parent := filepath.Dir(current); parent == current {
+			break
+		}
+	}
+	if created == "" || j.seen[created] {
+		return
+	}
+	j.seen[created] = true
+	j.entries = append(j.entries, journalEntry{Path: created, CreatedDir: true})
+}
+
+// rollback undoes the journaled changes, newest first
+func (j *journal) rollback() error {
+	var errs []error
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		entry := j.entries[i]
+		switch {
+		case entry.CreatedDir:
+			errs = append(errs, os.RemoveAll(entry.Path))
+		case entry.Snapshot != nil:
+			errs = append(errs, removeAddedFiles(entry.Path, entry.Snapshot))
+		case entry.Existed:
+			if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			errs = append(errs, os.WriteFile(entry.Path, entry.Content, entry.Mode))
+		default:
+			if err := os.Remove(entry.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// removeAddedFiles deletes the files below dir that are not in keep
+func removeAddedFiles(dir string, keep map[string]bool) error {
+	var added []string
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !keep[file] {
+			added = append(added, file)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, file := range added {
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
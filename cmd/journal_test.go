@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readTree returns the files below the working directory with their
+// content, and its directories with a trailing slash
+func readTree(t *testing.T) map[string]string {
+	t.Helper()
+	tree := map[string]string{}
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == "." {
+			return err
+		}
+		if d.IsDir() {
+			tree[path+"/"] = ""
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		tree[path] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestWithRollback(t *testing.T) {
+	before := map[string]string{
+		"del.txt":       "bye\n",
+		"hand.go":       "package x\nvar  a=1\n",
+		"mod.txt":       "old\n",
+		"out/":          "",
+		"out/old.pb.go": "old\n",
+	}
+	after := map[string]string{
+		"gen.go":            "package x\n\nvar b = 2\n",
+		"hand.go":           "package x\nvar  a=1\n",
+		"missing/":          "",
+		"missing/sub/":      "",
+		"missing/sub/x.go":  "external\n",
+		"mod.txt":           "new\n",
+		"new/":              "",
+		"new/deep/":         "",
+		"new/deep/file.txt": "x\n",
+		"out/":              "",
+		"out/new.pb.go":     "external\n",
+		"out/old.pb.go":     "external\n",
+	}
+	failure := errors.New("protoc failed")
+	// change writes through the journaled helpers and, like protoc, behind
+	// their back into the snapshotted paths
+	change := func(fail bool) func() error {
+		return func() error {
+			steps := []error{
+				makeDir("new/deep"),
+				writeFile("new/deep/file.txt", "x\n"),
+				writeFile("mod.txt", "new\n"),
+				removeFile("del.txt"),
+				writeFile("gen.go", "package x\nvar  b=2\n"),
+				formatGoFiles(),
+				os.MkdirAll("missing/sub", 0755),
+				os.WriteFile("missing/sub/x.go", []byte("external\n"), 0644),
+				os.WriteFile("out/new.pb.go", []byte("external\n"), 0644),
+				os.WriteFile("out/old.pb.go", []byte("external\n"), 0644),
+			}
+			if err := errors.Join(steps...); err != nil {
+				return err
+			}
+			if fail {
+				return failure
+			}
+			return nil
+		}
+	}
+
+	tests := []struct {
+		name        string
+		fail        bool
+		keepPartial bool
+		want        map[string]string
+	}{
+		{name: "success keeps the changes", want: after},
+		{name: "failure restores the previous state", fail: true, want: before},
+		{name: "failure with --keep-partial", fail: true, keepPartial: true, want: after},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			for path, content := range before {
+				if filepath.Ext(path) == "" {
+					continue
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			keepPartial = tt.keepPartial
+			t.Cleanup(func() { keepPartial = false })
+
+			var err error
+			captureOutput(t, func() { err = withRollback([]string{"out", "missing"}, change(tt.fail)) })
+			if tt.fail != errors.Is(err, failure) {
+				t.Fatalf("withRollback() error = %v", err)
+			}
+			if activeJournal != nil {
+				t.Error("withRollback() left the journal active")
+			}
+			if got := readTree(t); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tree =\n%v\nwant\n%v", got, tt.want)
+			}
+			if info, err := os.Stat("mod.txt"); err == nil && info.Mode().Perm() != 0600 {
+				t.Errorf("mod.txt mode = %v, want 0600", info.Mode().Perm())
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// manifestFileName is the project manifest written by init at the project root
+	manifestFileName = "grpcframe.yaml"
+	// manifestVersion is the manifest schema version this binary understands
+	manifestVersion = 1
+)
+
+// Manifest describes the layout and settings of a grpcframe project
+type Manifest struct {
+	Version  int              `yaml:"version"`
+	Module   string           `yaml:"module"`
+	Layout   ManifestLayout   `yaml:"layout"`
+	Ports    ManifestPorts    `yaml:"ports"`
+	Database ManifestDatabase `yaml:"database"`
+	Features ManifestFeatures `yaml:"features"`
+	Protogen ManifestProtogen `yaml:"protogen"`
+	API      ManifestAPI      `yaml:"api"`
+}
+
+// ManifestLayout holds project relative directories used by the generators
+type ManifestLayout struct {
+	Proto      string `yaml:"proto"`
+	Protogen   string `yaml:"protogen"`
+	RPC        string `yaml:"rpc"`
+	Gateway    string `yaml:"gateway"`
+	Repo       string `yaml:"repo"`
+	Migrations string `yaml:"migrations"`
+	Schema     string `yaml:"schema"`
+	Queries    string `yaml:"queries"`
+	Swagger    string `yaml:"swagger"`
+	Doc        string `yaml:"doc"`
+	// Errs, Convert and Env are the packages grpcframe writes for the
+	// generated code: database error mapping, type conversions and
+	// environment lookups
+	Errs    string `yaml:"errs"`
+	Convert string `yaml:"convert"`
+	Env     string `yaml:"env"`
+}
+
+// ManifestPorts holds the default listen ports of the generated servers
+type ManifestPorts struct {
+	GRPC    int `yaml:"grpc"`
+	Gateway int `yaml:"gateway"`
+}
+
+// ManifestDatabase holds the database driver and the sqlc package name
+type ManifestDatabase struct {
+	Driver  string `yaml:"driver"`
+	Package string `yaml:"package"`
+}
+
+// ManifestFeatures toggles optional parts of the project
+type ManifestFeatures struct {
+	Gateway    bool `yaml:"gateway"`
+	Swagger    bool `yaml:"swagger"`
+	SQLC       bool `yaml:"sqlc"`
+	Migrations bool `yaml:"migrations"`
+}
+
+// ManifestProtogen configures protobuf code generation
+type ManifestProtogen struct {
+	// Engine is either protoc or buf
+	Engine  string           `yaml:"engine"`
+	Plugins []ProtogenPlugin `yaml:"plugins"`
+	// Vendored lists third party directories below the proto directory.
+	// They are compiled as imports only and keep their own go_package.
+	Vendored []string `yaml:"vendored"`
+}
+
+// ManifestAPI holds the naming conventions of scaffolded proto APIs
+type ManifestAPI struct {
+	// Package prefixes the proto package of every module, a module course
+	// of package lms gets package lms.course
+	Package string `yaml:"package"`
+	// Version is the version segment of http routes such as /lms/v1/course
+	Version string `yaml:"version"`
+}
+
+// ProtogenPlugin is a protoc plugin run by protogen. Path overrides the
+// protoc-gen-<name> binary looked up in PATH.
+type ProtogenPlugin struct {
+	Name string   `yaml:"name"`
+	Out  string   `yaml:"out"`
+	Opt  []string `yaml:"opt,omitempty"`
+	Path string   `yaml:"path,omitempty"`
+}
+
+// defaultManifest returns the manifest used for new projects and for
+// projects created before grpcframe.yaml existed
+func defaultManifest(moduleName string) *Manifest {
+	manifest := &Manifest{
+		Version: manifestVersion,
+		Module:  moduleName,
+		Layout: ManifestLayout{
+			Proto:      "proto",
+			Protogen:   "protogen",
+			RPC:        "app/rpc",
+			Gateway:    "app/gateway",
+			Repo:       "internal/repo",
+			Migrations: "database/migrations",
+			Schema:     "database/schema",
+			Queries:    "database/queries",
+			Swagger:    "doc/swagger",
+			Doc:        "doc",
+			Errs:       "pkg/errs",
+			Convert:    "pkg/utils/convert",
+			Env:        "pkg/utils/env",
+		},
+		Ports: ManifestPorts{
+			GRPC:    9001,
+			Gateway: 8082,
+		},
+		Database: ManifestDatabase{
+			Driver:  "postgres",
+			Package: "db",
+		},
+		Features: ManifestFeatures{
+			Gateway:    true,
+			Swagger:    true,
+			SQLC:       true,
+			Migrations: true,
+		},
+	}
+	manifest.Protogen = ManifestProtogen{
+		Engine:   "buf",
+		Plugins:  manifest.defaultProtogenPlugins(),
+		Vendored: []string{"google", "protoc-gen-openapiv2"},
+	}
+	manifest.API = ManifestAPI{
+		Package: defaultAPIPackage(moduleName),
+		Version: "v1",
+	}
+	return manifest
+}
+
+// defaultAPIPackage derives a proto package prefix from the last element
+// of the Go module path
+func defaultAPIPackage(moduleName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, path.Base(moduleName))
+	if name == "" || name[0] < 'a' {
+		return "api"
+	}
+	return name
+}
+
+// defaultProtogenPlugins returns the plugins matching the layout and features
+func (m *Manifest) defaultProtogenPlugins() []ProtogenPlugin {
+	plugins := []ProtogenPlugin{
+		{Name: "go", Out: m.Layout.Protogen, Opt: []string{"paths=source_relative"}},
+		{Name: "go-grpc", Out: m.Layout.Protogen, Opt: []string{"paths=source_relative"}},
+	}
+	if m.Features.Gateway {
+		plugins = append(plugins, ProtogenPlugin{
+			Name: "grpc-gateway",
+			Out:  m.Layout.Protogen,
+			Opt:  []string{"paths=source_relative", "generate_unbound_methods=true"},
+		})
+	}
+	if m.Features.Swagger {
+		plugins = append(plugins, ProtogenPlugin{
+			Name: "openapiv2",
+			Out:  m.Layout.Swagger,
+			Opt:  []string{"allow_merge=true", "merge_file_name=api"},
+		})
+	}
+	return plugins
+}
+
+// loadManifest reads grpcframe.yaml from the current directory. Projects
+// without a manifest fall back to the default layout and the go.mod module.
+func loadManifest() (*Manifest, error) {
+	content, err := os.ReadFile(manifestFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		moduleName, err := getTargetModuleName()
+		if err != nil {
+			return nil, fmt.Errorf("%s not found and %w", manifestFileName, err)
+		}
+		pkg.DebugLog(manifestFileName, "not found, using default layout")
+		manifest := defaultManifest(moduleName)
+		manifest.Protogen.Engine = pinnedProtogenEngine()
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", manifestFileName, err)
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestFileName, err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported %s version %d (expected %d)", manifestFileName, manifest.Version, manifestVersion)
+	}
+	if engine := manifest.Protogen.Engine; engine != "" && engine != "protoc" && engine != "buf" {
+		return nil, fmt.Errorf("unsupported protogen engine %q in %s (expected protoc or buf)", engine, manifestFileName)
+	}
+	if manifest.Module == "" {
+		if manifest.Module, err = getTargetModuleName(); err != nil {
+			return nil, fmt.Errorf("module is not set in %s and %w", manifestFileName, err)
+		}
+	}
+	manifest.applyDefaults()
+	return &manifest, nil
+}
+
+// writeManifest stores the manifest in dir
+func writeManifest(dir string, manifest *Manifest) error {
+	content, err := yaml.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	header := "# grpcframe project manifest. Every grpcframe command resolves paths and settings from this file.\n"
+	return createFileWithContent(filepath.Join(dir, manifestFileName), header+string(content))
+}
+
+// applyDefaults fills empty settings of a partial manifest. Feature toggles
+// are left as written because false is a meaningful value.
+func (m *Manifest) applyDefaults() {
+	def := defaultManifest(m.Module)
+	setDefault := func(value *string, fallback string) {
+		if *value == "" {
+			*value = fallback
+		}
+	}
+	setDefault(&m.Layout.Proto, def.Layout.Proto)
+	setDefault(&m.Layout.Protogen, def.Layout.Protogen)
+	setDefault(&m.Layout.RPC, def.Layout.RPC)
+	setDefault(&m.Layout.Gateway, def.Layout.Gateway)
+	setDefault(&m.Layout.Repo, def.Layout.Repo)
+	setDefault(&m.Layout.Migrations, def.Layout.Migrations)
+	setDefault(&m.Layout.Schema, def.Layout.Schema)
+	setDefault(&m.Layout.Queries, def.Layout.Queries)
+	setDefault(&m.Layout.Swagger, def.Layout.Swagger)
+	setDefault(&m.Layout.Doc, def.Layout.Doc)
+	setDefault(&m.Layout.Errs, def.Layout.Errs)
+	setDefault(&m.Layout.Convert, def.Layout.Convert)
+	setDefault(&m.Layout.Env, def.Layout.Env)
+	setDefault(&m.Database.Driver, def.Database.Driver)
+	setDefault(&m.Database.Package, def.Database.Package)
+	if m.Ports.GRPC == 0 {
+		m.Ports.GRPC = def.Ports.GRPC
+	}
+	if m.Ports.Gateway == 0 {
+		m.Ports.Gateway = def.Ports.Gateway
+	}
+	setDefault(&m.Protogen.Engine, pinnedProtogenEngine())
+	if len(m.Protogen.Plugins) == 0 {
+		m.Protogen.Plugins = m.defaultProtogenPlugins()
+	}
+	if m.Protogen.Vendored == nil {
+		m.Protogen.Vendored = def.Protogen.Vendored
+	}
+	setDefault(&m.API.Package, def.API.Package)
+	setDefault(&m.API.Version, def.API.Version)
+}
+
+// pinnedProtogenEngine returns the engine of projects that do not set one:
+// buf when tools.go pins it, so generation runs the pinned version, and
+// protoc otherwise. New projects pin buf and set it explicitly.
+func pinnedProtogenEngine() string {
+	tools, err := pinnedTools()
+	if err != nil {
+		return "protoc"
+	}
+	for _, tool := range tools {
+		if path.Base(tool) == "buf" {
+			return "buf"
+		}
+	}
+	return "protoc"
+}
+
+// ProtoPackage returns the proto package of a module, such as lms.course
+func (m *Manifest) ProtoPackage(module string) string {
+	return m.API.Package + "." + module
+}
+
+// HTTPRoute returns the base http route of a module, such as /lms/v1/course
+func (m *Manifest) HTTPRoute(module string) string {
+	return "/" + strings.ReplaceAll(m.API.Package, ".", "/") + "/" + m.API.Version + "/" + module
+}
+
+// IsVendoredProto reports whether a proto path relative to the proto
+// directory belongs to a vendored third party directory
+func (m *Manifest) IsVendoredProto(protoPath string) bool {
+	for _, dir := range m.Protogen.Vendored {
+		if strings.HasPrefix(protoPath, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ProtoGoImportPath returns the Go package of a project proto file. The
+// generators derive it from the directory of the file, the same way buf
+// managed mode applies go_package_prefix.
+func (m *Manifest) ProtoGoImportPath(protoPath string) string {
+	return m.ImportPath(filepath.Join(m.Layout.Protogen, filepath.FromSlash(path.Dir(protoPath))))
+}
+
+// Directories returns every directory of the project layout
+func (m *Manifest) Directories() []string {
+	return []string{
+		m.Layout.Proto,
+		m.Layout.Protogen,
+		m.Layout.RPC,
+		m.Layout.Gateway,
+		m.Layout.Repo,
+		m.Layout.Migrations,
+		m.Layout.Schema,
+		m.Layout.Queries,
+		m.Layout.Swagger,
+		m.Layout.Doc,
+		m.Layout.Errs,
+		m.Layout.Convert,
+		m.Layout.Env,
+	}
+}
+
+// ImportPath returns the Go import path of a project relative directory
+func (m *Manifest) ImportPath(dir string) string {
+	return path.Join(m.Module, filepath.ToSlash(dir))
+}
+
+// GRPCAddress returns the default gRPC listen address
+func (m *Manifest) GRPCAddress() string {
+	return fmt.Sprintf(":%d", m.Ports.GRPC)
+}
+
+// GatewayAddress returns the default HTTP gateway listen address
+func (m *Manifest) GatewayAddress() string {
+	return fmt.Sprintf(":%d", m.Ports.Gateway)
+}
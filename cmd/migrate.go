@@ -59,10 +59,38 @@ type DBConfig struct {
 	SSLMode  string
 }
 
+// dbEnvKeys are the .env keys loadDBConfig requires
+var dbEnvKeys = []string{"DB_HOST", "DB_PORT", "DB_USER", "DB_NAME"}
+
 func loadDBConfig() (*DBConfig, error) {
-	envPath := ".env"
+	env, err := readEnvFile(".env")
+	if err != nil {
+		return nil, err
+	}
+
+	config := &DBConfig{
+		Host:     env["DB_HOST"],
+		Port:     env["DB_PORT"],
+		User:     env["DB_USER"],
+		Password: env["DB_PASSWORD"],
+		Name:     env["DB_NAME"],
+		SSLMode:  "disable", // default
+	}
+	if sslMode := env["DB_SSL_MODE"]; sslMode != "" {
+		config.SSLMode = sslMode
+	}
+
+	if config.Host == "" || config.Port == "" || config.User == "" || config.Name == "" {
+		return nil, fmt.Errorf("missing required database configuration in .env")
+	}
+
+	return config, nil
+}
+
+// readEnvFile parses KEY=VALUE lines of an env file, skipping comments
+func readEnvFile(envPath string) (map[string]string, error) {
 	if _, err := os.Stat(envPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf(".env file not found")
+		return nil, fmt.Errorf("%s file not found", envPath)
 	}
 
 	file, err := os.Open(envPath)
@@ -71,10 +99,7 @@ func loadDBConfig() (*DBConfig, error) {
 	}
 	defer file.Close()
 
-	config := &DBConfig{
-		SSLMode: "disable", // default
-	}
-
+	env := map[string]string{}
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -83,41 +108,41 @@ func loadDBConfig() (*DBConfig, error) {
 		}
 
 		parts := strings.SplitN(line, "=", 2)
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		switch key {
-		case "DB_HOST":
-			config.Host = value
-		case "DB_PORT":
-			config.Port = value
-		case "DB_USER":
-			config.User = value
-		case "DB_PASSWORD":
-			config.Password = value
-		case "DB_NAME":
-			config.Name = value
-		case "DB_SSL_MODE":
-			config.SSLMode = value
-		}
+		env[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
 	}
+	return env, scanner.Err()
+}
 
-	if config.Host == "" || config.Port == "" || config.User == "" || config.Name == "" {
-		return nil, fmt.Errorf("missing required database configuration in .env")
+// loadMigrationsDir returns the manifest and the migrations directory of
+// the project, checking that migrations are enabled for a supported driver
+func loadMigrationsDir() (*Manifest, string, error) {
+	manifest, err := loadManifest()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	if !manifest.Features.Migrations {
+		return nil, "", fmt.Errorf("the migrations feature is disabled in %s", manifestFileName)
+	}
+	if manifest.Database.Driver != "postgres" {
+		return nil, "", fmt.Errorf("unsupported database driver %q in %s", manifest.Database.Driver, manifestFileName)
 	}
 
-	return config, nil
+	migrationsDir := filepath.Clean(manifest.Layout.Migrations)
+	if _, err := os.Stat(migrationsDir); os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("migrations directory not found at %s", migrationsDir)
+	}
+	return manifest, migrationsDir, nil
 }
 
 func createMigrateInstance() (*migrate.Migrate, error) {
-	config, err := loadDBConfig()
+	manifest, migrationsDir, err := loadMigrationsDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load DB config: %w", err)
+		return nil, err
 	}
 
-	migrationsDir := filepath.Join("database", "migrations")
-	if _, err := os.Stat(migrationsDir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("migrations directory not found at %s", migrationsDir)
+	config, err := loadDBConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load DB config: %w", err)
 	}
 
 	// First verify the database connection
@@ -138,7 +163,7 @@ func createMigrateInstance() (*migrate.Migrate, error) {
 	defer conn.Close(ctx)
 
 	// Create migrate instance
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open(manifest.Database.Driver, connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
@@ -150,7 +175,7 @@ func createMigrateInstance() (*migrate.Migrate, error) {
 
 	m, err := migrate.NewWithDatabaseInstance(
 		fmt.Sprintf("file://%s", migrationsDir),
-		"postgres", driver)
+		manifest.Database.Driver, driver)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create migration instance: %w", err)
 	}
@@ -159,6 +184,9 @@ func createMigrateInstance() (*migrate.Migrate, error) {
 }
 
 func runMigrations(direction string, steps string) error {
+	if dryRun {
+		return runExternal("migrate "+direction+" "+steps, func() error { return runMigrations(direction, steps) })
+	}
 	m, err := createMigrateInstance()
 	if err != nil {
 		return err
@@ -210,6 +238,9 @@ var migrateForceCmd = &cobra.Command{
 }
 
 func forceMigration(version string) error {
+	if dryRun {
+		return runExternal("migrate force "+version, func() error { return forceMigration(version) })
+	}
 	m, err := createMigrateInstance()
 	if err != nil {
 		return err
@@ -0,0 +1,558 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateCreateSeq       bool
+	migrateCreateTimestamp bool
+	migrateCreateFromDiff  bool
+)
+
+var migrateCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a pair of up and down migration files",
+	Long: "Writes <version>_<name>.up.sql and <version>_<name>.down.sql to the migrations directory. The version " +
+		"follows the numbering of the existing files, sequential by default. With --from-diff both files are " +
+		"prefilled with the statements that turn the schema built by the existing up migrations into the schema " +
+		"directory of " + manifestFileName,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := createMigration(args[0]); err != nil {
+			pkg.ErrorLog("Failed to create migration:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// migrationFile is a file of the migrations directory named the way
+// golang-migrate parses it
+type migrationFile struct {
+	Path       string
+	Version    uint
+	Prefix     string
+	Identifier string
+	Direction  source.Direction
+}
+
+// versionTimestampFormat is the version layout of timestamped migrations
+const versionTimestampFormat = "20060102150405"
+
+// seqVersionDigits is the width of sequential versions in a new directory
+const seqVersionDigits = 6
+
+// migrationNameInvalid matches the runs of characters replaced in names
+var migrationNameInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+
+// listMigrationFiles parses the file names of the migrations directory the
+// way the golang-migrate file source does. Names it cannot parse are
+// returned separately.
+func listMigrationFiles(dir string) ([]*migrationFile, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	var files []*migrationFile
+	var unparsed []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		migration, err := source.DefaultParse(entry.Name())
+		if err != nil {
+			unparsed = append(unparsed, entry.Name())
+			continue
+		}
+		files = append(files, &migrationFile{
+			Path:       filepath.Join(dir, entry.Name()),
+			Version:    migration.Version,
+			Prefix:     entry.Name()[:strings.Index(entry.Name(), "_")],
+			Identifier: migration.Identifier,
+			Direction:  migration.Direction,
+		})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Version != files[j].Version {
+			return files[i].Version < files[j].Version
+		}
+		return files[i].Direction == source.Up && files[j].Direction != source.Up
+	})
+	return files, unparsed, nil
+}
+
+func createMigration(name string) error {
+	if migrateCreateSeq && migrateCreateTimestamp {
+		return fmt.Errorf("--seq and --timestamp cannot be combined")
+	}
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	if !manifest.Features.Migrations {
+		return fmt.Errorf("the migrations feature is disabled in %s", manifestFileName)
+	}
+
+	identifier := strings.Trim(migrationNameInvalid.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if identifier == "" {
+		return fmt.Errorf("invalid migration name %q", name)
+	}
+
+	migrationsDir := filepath.Clean(manifest.Layout.Migrations)
+	files, _, err := listMigrationFiles(migrationsDir)
+	if err != nil {
+		return err
+	}
+	version, err := nextMigrationVersion(files, time.Now())
+	if err != nil {
+		return err
+	}
+
+	up, down := "", ""
+	if migrateCreateFromDiff {
+		if up, down, err = migrationFromDiff(manifest, files); err != nil {
+			return err
+		}
+	}
+
+	base := filepath.Join(migrationsDir, version+"_"+identifier)
+	return withRollback(nil, func() error {
+		for _, file := range []struct{ path, content string }{
+			{base + ".up.sql", up},
+			{base + ".down.sql", down},
+		} {
+			if fileExists(file.path) {
+				return fmt.Errorf("%s already exists", file.path)
+			}
+			if err := createFileWithContent(file.path, file.content); err != nil {
+				return err
+			}
+		}
+		logChange("Created", "Would create", "migration", version+"_"+identifier)
+		return nil
+	})
+}
+
+// nextMigrationVersion returns the version of a new migration. Without
+// --seq or --timestamp the style of the existing files is kept: versions of
+// 14 digits are timestamps, anything else is sequential.
+func nextMigrationVersion(files []*migrationFile, now time.Time) (string, error) {
+	var latest *migrationFile
+	for _, file := range files {
+		if latest == nil || file.Version >= latest.Version {
+			latest = file
+		}
+	}
+
+	timestamp := migrateCreateTimestamp
+	if !migrateCreateSeq && !migrateCreateTimestamp && latest != nil {
+		timestamp = len(latest.Prefix) == len(versionTimestampFormat)
+	}
+
+	if timestamp {
+		version := now.UTC().Format(versionTimestampFormat)
+		if n, _ := strconv.ParseUint(version, 10, 64); latest != nil && uint(n) <= latest.Version {
+			return "", fmt.Errorf("timestamp version %s is not after the latest version %d", version, latest.Version)
+		}
+		return version, nil
+	}
+
+	digits, next := seqVersionDigits, uint(1)
+	if latest != nil {
+		if len(latest.Prefix) == len(versionTimestampFormat) {
+			return "", fmt.Errorf("the latest migration %s is timestamped, use --timestamp", filepath.Base(latest.Path))
+		}
+		digits, next = len(latest.Prefix), latest.Version+1
+	}
+	return fmt.Sprintf("%0*d", digits, next), nil
+}
+
+// migrationFromDiff compares the schema built by replaying the up
+// migrations with the schema directory and returns the statements of the
+// up and down migration
+func migrationFromDiff(manifest *Manifest, files []*migrationFile) (string, string, error) {
+	target, err := loadSQLSchema(manifest.Layout.Schema)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load the schema: %w", err)
+	}
+
+	current := &SQLSchema{}
+	for _, file := range files {
+		if file.Direction != source.Up {
+			continue
+		}
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		if err := current.apply(string(content)); err != nil {
+			return "", "", fmt.Errorf("%s: %w", file.Path, err)
+		}
+	}
+
+	up, down := diffSQLSchemas(current, target)
+	if len(up) == 0 {
+		return "", "", fmt.Errorf("%s matches the migrations, there is nothing to diff", manifest.Layout.Schema)
+	}
+	return joinMigration(up), joinMigration(down), nil
+}
+
+// diffSQLSchemas returns the statements that turn from into to, and the
+// statements that undo them in reverse order. Constraints and indexes
+// postgres named itself cannot be dropped by name and get a TODO instead.
+func diffSQLSchemas(from, to *SQLSchema) (up, down []string) {
+	var undo []string
+	change := func(do, undoStatement string) {
+		up = append(up, do)
+		undo = append(undo, undoStatement)
+	}
+
+	for _, enum := range to.Enums {
+		previous := from.Enum(enum.Name)
+		if previous == nil {
+			change(createEnumSQL(enum), "DROP TYPE IF EXISTS "+sqlQuoteIdentifier(enum.Name)+";")
+			continue
+		}
+		for _, value := range enum.Values {
+			if !slices.Contains(previous.Values, value) {
+				change(fmt.Sprintf("ALTER TYPE %s ADD VALUE IF NOT EXISTS %s;", sqlQuoteIdentifier(enum.Name), sqlQuoteString(value)),
+					fmt.Sprintf("-- TODO: postgres cannot drop the value %s of %s", sqlQuoteString(value), enum.Name))
+			}
+		}
+	}
+
+	// indexes go before the columns they cover, and come back after them
+	for _, index := range from.Indexes {
+		if findIndex(to.Indexes, index) == nil && to.Table(index.Table) != nil {
+			change(dropIndexSQL(index), index.Definition+";")
+		}
+	}
+
+	for _, table := range to.Tables {
+		previous := from.Table(table.Name)
+		if previous == nil {
+			change(createTableSQL(table), "DROP TABLE IF EXISTS "+sqlQuoteIdentifier(table.Name)+";")
+			continue
+		}
+		name := sqlQuoteIdentifier(table.Name)
+
+		// constraints are dropped before the columns change and added after
+		for _, foreignKey := range previous.ForeignKeys {
+			if findForeignKey(table.ForeignKeys, foreignKey) == nil && !droppedWith(foreignKey, table) {
+				change(dropConstraintSQL(table.Name, foreignKeyName(table.Name, foreignKey), foreignKeySQL(foreignKey)),
+					fmt.Sprintf("ALTER TABLE %s ADD %s;", name, foreignKeySQL(foreignKey)))
+			}
+		}
+		for _, constraint := range previous.Constraints {
+			if findConstraint(table.Constraints, constraint) == nil {
+				change(dropConstraintSQL(table.Name, constraint.Name, constraint.Definition),
+					fmt.Sprintf("ALTER TABLE %s ADD %s;", name, constraintSQL(constraint)))
+			}
+		}
+
+		for _, column := range table.Columns {
+			old := previous.Column(column.Name)
+			if old == nil {
+				change(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", name, columnSQL(column, column.PrimaryKey, columnForeignKey(table, column))),
+					fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s;", name, sqlQuoteIdentifier(column.Name)))
+				continue
+			}
+			alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", name, sqlQuoteIdentifier(column.Name))
+			if old.Type != column.Type || old.Array != column.Array || sqlTypeModifier(old) != sqlTypeModifier(column) {
+				change(alter+"TYPE "+column.RawType+";", alter+"TYPE "+old.RawType+";")
+			}
+			if old.NotNull != column.NotNull {
+				change(alter+nullSQL(column.NotNull)+";", alter+nullSQL(old.NotNull)+";")
+			}
+			if old.Default != column.Default {
+				change(alter+defaultSQL(column.Default)+";", alter+defaultSQL(old.Default)+";")
+			}
+		}
+		for _, old := range previous.Columns {
+			if table.Column(old.Name) == nil {
+				change(fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s;", name, sqlQuoteIdentifier(old.Name)),
+					fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", name, columnSQL(old, old.PrimaryKey, columnForeignKey(previous, old))))
+			}
+		}
+
+		for _, foreignKey := range table.ForeignKeys {
+			if findForeignKey(previous.ForeignKeys, foreignKey) == nil && !droppedWith(foreignKey, previous) {
+				change(fmt.Sprintf("ALTER TABLE %s ADD %s;", name, foreignKeySQL(foreignKey)),
+					dropConstraintSQL(table.Name, foreignKeyName(table.Name, foreignKey), foreignKeySQL(foreignKey)))
+			}
+		}
+		for _, constraint := range table.Constraints {
+			if findConstraint(previous.Constraints, constraint) == nil {
+				change(fmt.Sprintf("ALTER TABLE %s ADD %s;", name, constraintSQL(constraint)),
+					dropConstraintSQL(table.Name, constraint.Name, constraint.Definition))
+			}
+		}
+	}
+
+	for _, index := range to.Indexes {
+		if findIndex(from.Indexes, index) == nil {
+			change(index.Definition+";", dropIndexSQL(index))
+		}
+	}
+
+	for i := len(from.Tables) - 1; i >= 0; i-- {
+		table := from.Tables[i]
+		if to.Table(table.Name) != nil {
+			continue
+		}
+		recreate := []string{createTableSQL(table)}
+		for _, index := range from.Indexes {
+			if index.Table == table.Name {
+				recreate = append(recreate, index.Definition+";")
+			}
+		}
+		change("DROP TABLE IF EXISTS "+sqlQuoteIdentifier(table.Name)+";", strings.Join(recreate, "\n\n"))
+	}
+	for _, enum := range from.Enums {
+		if to.Enum(enum.Name) == nil {
+			change("DROP TYPE IF EXISTS "+sqlQuoteIdentifier(enum.Name)+";", createEnumSQL(enum))
+		}
+	}
+
+	for i := len(undo) - 1; i >= 0; i-- {
+		down = append(down, undo[i])
+	}
+	return up, down
+}
+
+// columnForeignKey returns the single column foreign key of a column,
+// which is written inline with the column
+func columnForeignKey(table *SQLTable, column *SQLColumn) *SQLForeignKey {
+	for _, foreignKey := range table.ForeignKeys {
+		if len(foreignKey.Columns) == 1 && foreignKey.Columns[0] == column.Name {
+			return foreignKey
+		}
+	}
+	return nil
+}
+
+// droppedWith reports whether a foreign key is written inline with a
+// column the other table version lacks, so it comes and goes with it
+func droppedWith(foreignKey *SQLForeignKey, other *SQLTable) bool {
+	return len(foreignKey.Columns) == 1 && other.Column(foreignKey.Columns[0]) == nil
+}
+
+func findForeignKey(foreignKeys []*SQLForeignKey, foreignKey *SQLForeignKey) *SQLForeignKey {
+	for _, candidate := range foreignKeys {
+		if referencesSQL(candidate) == referencesSQL(foreignKey) && slices.Equal(candidate.Columns, foreignKey.Columns) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// findConstraint matches constraints by name, or by definition when
+// postgres names them
+func findConstraint(constraints []*SQLConstraint, constraint *SQLConstraint) *SQLConstraint {
+	for _, candidate := range constraints {
+		if candidate.Name == constraint.Name && equalSQL(candidate.Definition, constraint.Definition) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// findIndex matches indexes by name, or by definition when postgres names
+// them
+func findIndex(indexes []*SQLIndex, index *SQLIndex) *SQLIndex {
+	for _, candidate := range indexes {
+		switch {
+		case index.Name != "" && candidate.Name == index.Name:
+			return candidate
+		case index.Name == "" && candidate.Name == "" && equalSQL(candidate.Definition, index.Definition):
+			return candidate
+		}
+	}
+	return nil
+}
+
+// foreignKeyName returns the name of a foreign key, or the name postgres
+// gives an unnamed one
+func foreignKeyName(table string, foreignKey *SQLForeignKey) string {
+	if foreignKey.Name != "" {
+		return foreignKey.Name
+	}
+	return table + "_" + strings.Join(foreignKey.Columns, "_") + "_fkey"
+}
+
+// dropConstraintSQL drops a constraint by name. Unnamed constraints get a
+// TODO since only postgres knows their name.
+func dropConstraintSQL(table, name, definition string) string {
+	if name == "" {
+		return fmt.Sprintf("-- TODO: drop %s of %s by the name postgres gave it", normalizeSQL(definition), table)
+	}
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;", sqlQuoteIdentifier(table), sqlQuoteIdentifier(name))
+}
+
+func dropIndexSQL(index *SQLIndex) string {
+	if index.Name == "" {
+		return fmt.Sprintf("-- TODO: drop the index %s by the name postgres gave it", normalizeSQL(index.Definition))
+	}
+	return "DROP INDEX IF EXISTS " + sqlQuoteIdentifier(index.Name) + ";"
+}
+
+func createEnumSQL(enum *SQLEnum) string {
+	values := make([]string, len(enum.Values))
+	for i, value := range enum.Values {
+		values[i] = "  " + sqlQuoteString(value)
+	}
+	return fmt.Sprintf("CREATE TYPE %s AS ENUM (\n%s\n);", sqlQuoteIdentifier(enum.Name), strings.Join(values, ",\n"))
+}
+
+func createTableSQL(table *SQLTable) string {
+	inlineKey := len(table.PrimaryKey) == 1 && table.Column(table.PrimaryKey[0]) != nil && table.Column(table.PrimaryKey[0]).PrimaryKey
+	var definitions []string
+	for _, column := range table.Columns {
+		definitions = append(definitions, columnSQL(column, inlineKey && column.PrimaryKey, nil))
+	}
+	if len(table.PrimaryKey) > 0 && !inlineKey {
+		definitions = append(definitions, "PRIMARY KEY ("+sqlQuoteIdentifiers(table.PrimaryKey)+")")
+	}
+	for _, foreignKey := range table.ForeignKeys {
+		definitions = append(definitions, foreignKeySQL(foreignKey))
+	}
+	for _, constraint := range table.Constraints {
+		definitions = append(definitions, constraintSQL(constraint))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n  %s\n);", sqlQuoteIdentifier(table.Name), strings.Join(definitions, ",\n  "))
+}
+
+// columnSQL renders a column definition with its primary key and an inline
+// foreign key. The expression of a generated column is not part of the
+// model and is left as a TODO.
+func columnSQL(column *SQLColumn, primaryKey bool, foreignKey *SQLForeignKey) string {
+	definition := sqlQuoteIdentifier(column.Name) + " " + column.RawType
+	if primaryKey {
+		definition += " PRIMARY KEY"
+	} else if column.NotNull {
+		definition += " NOT NULL"
+	}
+	if column.Unique {
+		definition += " UNIQUE"
+	}
+	if column.Default != "" {
+		definition += " DEFAULT " + column.Default
+	}
+	if foreignKey != nil {
+		if foreignKey.Name != "" {
+			definition += " CONSTRAINT " + sqlQuoteIdentifier(foreignKey.Name)
+		}
+		definition += " " + referencesSQL(foreignKey)
+	}
+	if column.Generated {
+		definition += " /* TODO: GENERATED clause */"
+	}
+	return definition
+}
+
+// foreignKeySQL renders a foreign key as a table constraint
+func foreignKeySQL(foreignKey *SQLForeignKey) string {
+	definition := ""
+	if foreignKey.Name != "" {
+		definition = "CONSTRAINT " + sqlQuoteIdentifier(foreignKey.Name) + " "
+	}
+	return definition + "FOREIGN KEY (" + sqlQuoteIdentifiers(foreignKey.Columns) + ") " + referencesSQL(foreignKey)
+}
+
+// referencesSQL renders the REFERENCES clause of a foreign key
+func referencesSQL(foreignKey *SQLForeignKey) string {
+	definition := "REFERENCES " + sqlQuoteIdentifier(foreignKey.RefTable)
+	if len(foreignKey.RefColumns) > 0 {
+		definition += " (" + sqlQuoteIdentifiers(foreignKey.RefColumns) + ")"
+	}
+	if foreignKey.OnDelete != "" {
+		definition += " ON DELETE " + foreignKey.OnDelete
+	}
+	if foreignKey.OnUpdate != "" {
+		definition += " ON UPDATE " + foreignKey.OnUpdate
+	}
+	return definition
+}
+
+func constraintSQL(constraint *SQLConstraint) string {
+	if constraint.Name == "" {
+		return constraint.Definition
+	}
+	return "CONSTRAINT " + sqlQuoteIdentifier(constraint.Name) + " " + constraint.Definition
+}
+
+// sqlTypeModifier returns the modifier of a column type, such as (255) of
+// varchar(255), so spellings of the same type compare equal
+func sqlTypeModifier(column *SQLColumn) string {
+	if i := strings.Index(column.RawType, "("); i >= 0 {
+		return strings.ReplaceAll(column.RawType[i:], " ", "")
+	}
+	return ""
+}
+
+// normalizeSQL collapses the whitespace of a definition
+func normalizeSQL(definition string) string {
+	return strings.Join(strings.Fields(definition), " ")
+}
+
+// equalSQL compares two definitions ignoring whitespace, so CHECK(a > 0)
+// and CHECK (a > 0) match
+func equalSQL(a, b string) bool {
+	return strings.ReplaceAll(normalizeSQL(a), " (", "(") == strings.ReplaceAll(normalizeSQL(b), " (", "(")
+}
+
+func nullSQL(notNull bool) string {
+	if notNull {
+		return "SET NOT NULL"
+	}
+	return "DROP NOT NULL"
+}
+
+func defaultSQL(value string) string {
+	if value == "" {
+		return "DROP DEFAULT"
+	}
+	return "SET DEFAULT " + value
+}
+
+// joinMigration separates the statements of a migration file by blank lines
+func joinMigration(statements []string) string {
+	return strings.Join(statements, "\n\n") + "\n"
+}
+
+func sqlQuoteIdentifiers(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = sqlQuoteIdentifier(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func sqlQuoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func init() {
+	migrateCreateCmd.Flags().BoolVar(&migrateCreateSeq, "seq", false, "Use the next sequential version (default unless the existing files are timestamped)")
+	migrateCreateCmd.Flags().BoolVar(&migrateCreateTimestamp, "timestamp", false, "Use the current UTC time as version")
+	migrateCreateCmd.Flags().BoolVar(&migrateCreateFromDiff, "from-diff", false, "Prefill the files with the difference between the migrations and the schema directory")
+	migrateCmd.AddCommand(migrateCreateCmd)
+}
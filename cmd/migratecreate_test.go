@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffSQLSchemas(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		up, down []string
+	}{
+		{
+			name: "identical",
+			from: "CREATE TABLE users (id uuid PRIMARY KEY, email text NOT NULL);",
+			to:   "CREATE TABLE users (\n  id   UUID PRIMARY KEY,\n  email TEXT NOT NULL\n);",
+		},
+		{
+			name: "new enum and table",
+			to: "CREATE TYPE status AS ENUM ('draft', 'live');\n" +
+				"CREATE TABLE posts (id int8 PRIMARY KEY, status status NOT NULL DEFAULT 'draft');",
+			up: []string{
+				"CREATE TYPE status AS ENUM (\n  'draft',\n  'live'\n);",
+				"CREATE TABLE posts (\n  id int8 PRIMARY KEY,\n  status status NOT NULL DEFAULT 'draft'\n);",
+			},
+			down: []string{"DROP TABLE IF EXISTS posts;", "DROP TYPE IF EXISTS status;"},
+		},
+		{
+			name: "enum value added",
+			from: "CREATE TYPE status AS ENUM ('draft');",
+			to:   "CREATE TYPE status AS ENUM ('draft', 'live');",
+			up:   []string{"ALTER TYPE status ADD VALUE IF NOT EXISTS 'live';"},
+			down: []string{"-- TODO: postgres cannot drop the value 'live' of status"},
+		},
+		{
+			name: "column changes",
+			from: "CREATE TABLE posts (id int8 PRIMARY KEY, title varchar(100), views int4 DEFAULT 0, legacy text);",
+			to: "CREATE TABLE users (id int8 PRIMARY KEY);\n" +
+				"CREATE TABLE posts (id int8 PRIMARY KEY, title varchar(200) NOT NULL, views int4, author_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE);",
+			up: []string{
+				"CREATE TABLE users (\n  id int8 PRIMARY KEY\n);",
+				"ALTER TABLE posts ALTER COLUMN title TYPE varchar(200);",
+				"ALTER TABLE posts ALTER COLUMN title SET NOT NULL;",
+				"ALTER TABLE posts ALTER COLUMN views DROP DEFAULT;",
+				"ALTER TABLE posts ADD COLUMN author_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE;",
+				"ALTER TABLE posts DROP COLUMN IF EXISTS legacy;",
+			},
+			down: []string{
+				"ALTER TABLE posts ADD COLUMN legacy text;",
+				"ALTER TABLE posts DROP COLUMN IF EXISTS author_id;",
+				"ALTER TABLE posts ALTER COLUMN views SET DEFAULT 0;",
+				"ALTER TABLE posts ALTER COLUMN title DROP NOT NULL;",
+				"ALTER TABLE posts ALTER COLUMN title TYPE varchar(100);",
+				"DROP TABLE IF EXISTS users;",
+			},
+		},
+		{
+			name: "foreign keys on existing columns",
+			from: "CREATE TABLE items (order_id int8, line int4, CONSTRAINT items_old_fkey FOREIGN KEY (line) REFERENCES lines (id));",
+			to:   "CREATE TABLE items (order_id int8, line int4, FOREIGN KEY (order_id, line) REFERENCES order_lines (order_id, line));",
+			up: []string{
+				"ALTER TABLE items DROP CONSTRAINT IF EXISTS items_old_fkey;",
+				"ALTER TABLE items ADD FOREIGN KEY (order_id, line) REFERENCES order_lines (order_id, line);",
+			},
+			down: []string{
+				"ALTER TABLE items DROP CONSTRAINT IF EXISTS items_order_id_line_fkey;",
+				"ALTER TABLE items ADD CONSTRAINT items_old_fkey FOREIGN KEY (line) REFERENCES lines (id);",
+			},
+		},
+		{
+			name: "check and unique constraints",
+			from: "CREATE TABLE products (sku text, price numeric CHECK (price >= 0));",
+			to:   "CREATE TABLE products (sku text, price numeric, CHECK(price > 0), CONSTRAINT products_sku_key UNIQUE (sku, price));",
+			up: []string{
+				"-- TODO: drop CHECK (price >= 0) of products by the name postgres gave it",
+				"ALTER TABLE products ADD CHECK (price > 0);",
+				"ALTER TABLE products ADD CONSTRAINT products_sku_key UNIQUE (sku, price);",
+			},
+			down: []string{
+				"ALTER TABLE products DROP CONSTRAINT IF EXISTS products_sku_key;",
+				"-- TODO: drop CHECK (price > 0) of products by the name postgres gave it",
+				"ALTER TABLE products ADD CHECK (price >= 0);",
+			},
+		},
+		{
+			name: "constraint spacing",
+			from: "CREATE TABLE products (price numeric, CHECK(price > 0));",
+			to:   "CREATE TABLE products (price numeric CHECK (price > 0));",
+		},
+		{
+			name: "indexes",
+			from: "CREATE TABLE posts (id int8, title text);\nCREATE INDEX posts_title_idx ON posts (title);",
+			to:   "CREATE TABLE posts (id int8, title text);\nCREATE UNIQUE INDEX posts_id_idx ON posts (id);",
+			up: []string{
+				"DROP INDEX IF EXISTS posts_title_idx;",
+				"CREATE UNIQUE INDEX posts_id_idx ON posts(id);",
+			},
+			down: []string{
+				"DROP INDEX IF EXISTS posts_id_idx;",
+				"CREATE INDEX posts_title_idx ON posts(title);",
+			},
+		},
+		{
+			name: "dropped table and enum",
+			from: "CREATE TYPE mood AS ENUM ('ok');\nCREATE TABLE \"Logs\" (id int8, mood mood);\nCREATE INDEX ON \"Logs\" (id);",
+			to:   "CREATE TABLE users (id int8);",
+			up: []string{
+				"CREATE TABLE users (\n  id int8\n);",
+				`DROP TABLE IF EXISTS "Logs";`,
+				"DROP TYPE IF EXISTS mood;",
+			},
+			down: []string{
+				"CREATE TYPE mood AS ENUM (\n  'ok'\n);",
+				"CREATE TABLE \"Logs\" (\n  id int8,\n  mood mood\n);\n\nCREATE INDEX ON \"Logs\"(id);",
+				"DROP TABLE IF EXISTS users;",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := &SQLSchema{}, &SQLSchema{}
+			if err := from.apply(tt.from); err != nil {
+				t.Fatalf("from: %v", err)
+			}
+			if err := to.apply(tt.to); err != nil {
+				t.Fatalf("to: %v", err)
+			}
+			up, down := diffSQLSchemas(from, to)
+			if !reflect.DeepEqual(up, tt.up) {
+				t.Errorf("up =\n%s\nwant\n%s", strings.Join(up, "\n"), strings.Join(tt.up, "\n"))
+			}
+			if !reflect.DeepEqual(down, tt.down) {
+				t.Errorf("down =\n%s\nwant\n%s", strings.Join(down, "\n"), strings.Join(tt.down, "\n"))
+			}
+		})
+	}
+}
+
+func TestNextMigrationVersion(t *testing.T) {
+	now := time.Date(2024, 3, 5, 14, 30, 0, 0, time.FixedZone("CET", 3600))
+	seq := func(prefix string, version uint) *migrationFile {
+		return &migrationFile{Path: "migrations/" + prefix + "_x.up.sql", Prefix: prefix, Version: version}
+	}
+	tests := []struct {
+		name           string
+		files          []*migrationFile
+		seq, timestamp bool
+		want           string
+		wantErr        bool
+	}{
+		{name: "empty directory", want: "000001"},
+		{name: "empty directory with --timestamp", timestamp: true, want: "20240305133000"},
+		{name: "sequential", files: []*migrationFile{seq("000002", 2), seq("000001", 1)}, want: "000003"},
+		{name: "keeps the width", files: []*migrationFile{seq("0009", 9)}, want: "0010"},
+		{name: "unpadded", files: []*migrationFile{seq("7", 7)}, want: "8"},
+		{name: "timestamped", files: []*migrationFile{seq("20240101000000", 20240101000000)}, want: "20240305133000"},
+		{name: "timestamp not after the latest", files: []*migrationFile{seq("20240305133000", 20240305133000)}, wantErr: true},
+		{name: "--seq after timestamps", files: []*migrationFile{seq("20240101000000", 20240101000000)}, seq: true, wantErr: true},
+		{name: "--timestamp after sequential", files: []*migrationFile{seq("000002", 2)}, timestamp: true, want: "20240305133000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrateCreateSeq, migrateCreateTimestamp = tt.seq, tt.timestamp
+			t.Cleanup(func() { migrateCreateSeq, migrateCreateTimestamp = false, false })
+
+			got, err := nextMigrationVersion(tt.files, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nextMigrationVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("nextMigrationVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
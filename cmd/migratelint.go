@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateLintFormat string
+	migrateLintStrict bool
+)
+
+var migrateLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the migrations directory for structural problems and risky SQL",
+	Long: "Parses the migrations directory the way migrate up does and reports duplicated versions, gaps in " +
+		"sequential versions, missing up or down files and file names golang-migrate ignores. The up migrations " +
+		"are checked for DROP COLUMN without IF EXISTS, NOT NULL columns added without a default, CREATE INDEX " +
+		"without CONCURRENTLY and column type changes on tables the migration did not create. Errors make the " +
+		"command fail, warnings only with --strict",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		failed, err := lintMigrations(migrateLintFormat, migrateLintStrict)
+		if err != nil {
+			pkg.ErrorLog("Lint failed:", err)
+			os.Exit(1)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// Severities of lint findings
+const (
+	lintError   = "error"
+	lintWarning = "warning"
+)
+
+// lintFinding is a problem found in the migrations directory
+type lintFinding struct {
+	File     string `json:"file"`
+	Version  uint   `json:"version,omitempty"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// lintReport is the JSON output of migrate lint
+type lintReport struct {
+	Directory string         `json:"directory"`
+	Errors    int            `json:"errors"`
+	Warnings  int            `json:"warnings"`
+	Findings  []*lintFinding `json:"findings"`
+}
+
+// lintMigrations lints the migrations directory and prints the findings.
+// It reports whether the findings should fail the command.
+func lintMigrations(format string, strict bool) (bool, error) {
+	if format != "text" && format != "json" {
+		return false, fmt.Errorf("unknown format %q, expected text or json", format)
+	}
+	_, migrationsDir, err := loadMigrationsDir()
+	if err != nil {
+		return false, err
+	}
+	files, unparsed, err := listMigrationFiles(migrationsDir)
+	if err != nil {
+		return false, err
+	}
+
+	findings := lintMigrationLayout(files, unparsed)
+	for _, file := range files {
+		if file.Direction != source.Up {
+			continue
+		}
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		for _, finding := range lintMigrationSQL(string(content)) {
+			finding.File, finding.Version = filepath.Base(file.Path), file.Version
+			findings = append(findings, finding)
+		}
+	}
+
+	report := &lintReport{Directory: migrationsDir, Findings: findings}
+	for _, finding := range findings {
+		if finding.Severity == lintError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+
+	if format == "json" {
+		if report.Findings == nil {
+			report.Findings = []*lintFinding{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return false, fmt.Errorf("failed to encode the report: %w", err)
+		}
+	} else {
+		printLintReport(report)
+	}
+	return report.Errors > 0 || strict && report.Warnings > 0, nil
+}
+
+func printLintReport(report *lintReport) {
+	for _, finding := range report.Findings {
+		line := fmt.Sprintf("%s: %s (%s)", finding.File, finding.Message, finding.Rule)
+		if finding.Severity == lintError {
+			pkg.ErrorLog(line)
+		} else {
+			pkg.WarningLog(line)
+		}
+	}
+	if len(report.Findings) == 0 {
+		pkg.SuccessLog(fmt.Sprintf("No problems found in %s", report.Directory))
+		return
+	}
+	pkg.InfoLog(fmt.Sprintf("%d errors, %d warnings in %s", report.Errors, report.Warnings, report.Directory))
+}
+
+// lintMigrationLayout reports unparsed file names, duplicated versions,
+// gaps between sequential versions and versions missing an up or a down
+// file
+func lintMigrationLayout(files []*migrationFile, unparsed []string) []*lintFinding {
+	var findings []*lintFinding
+	for _, name := range unparsed {
+		if filepath.Ext(name) == ".sql" {
+			findings = append(findings, &lintFinding{File: name, Severity: lintWarning, Rule: "unparsed-file",
+				Message: "the name does not match <version>_<name>.up.sql or .down.sql, migrate ignores the file"})
+		}
+	}
+
+	byVersion := map[uint]map[source.Direction][]*migrationFile{}
+	var versions []uint
+	timestamped := false
+	for _, file := range files {
+		if byVersion[file.Version] == nil {
+			byVersion[file.Version] = map[source.Direction][]*migrationFile{}
+			versions = append(versions, file.Version)
+		}
+		byVersion[file.Version][file.Direction] = append(byVersion[file.Version][file.Direction], file)
+		timestamped = timestamped || len(file.Prefix) == len(versionTimestampFormat)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	for i, version := range versions {
+		directions := byVersion[version]
+		first := directions[source.Up]
+		if len(first) == 0 {
+			first = directions[source.Down]
+		}
+		for _, direction := range []source.Direction{source.Up, source.Down} {
+			if len(directions[direction]) > 1 {
+				var names []string
+				for _, file := range directions[direction] {
+					names = append(names, filepath.Base(file.Path))
+				}
+				findings = append(findings, &lintFinding{File: names[0], Version: version, Severity: lintError, Rule: "duplicate-version",
+					Message: fmt.Sprintf("version %d has several %s files: %s", version, direction, strings.Join(names, ", "))})
+			}
+		}
+
+		switch {
+		case len(directions[source.Down]) == 0:
+			findings = append(findings, &lintFinding{File: filepath.Base(first[0].Path), Version: version,
+				Severity: lintError, Rule: "missing-down", Message: fmt.Sprintf("version %d has no down migration", version)})
+		case len(directions[source.Up]) == 0:
+			findings = append(findings, &lintFinding{File: filepath.Base(first[0].Path), Version: version,
+				Severity: lintError, Rule: "missing-up", Message: fmt.Sprintf("version %d has no up migration", version)})
+		}
+
+		if timestamped {
+			continue
+		}
+		previous := uint(0)
+		if i > 0 {
+			previous = versions[i-1]
+		}
+		if version > previous+1 {
+			missing := fmt.Sprintf("version %d is", previous+1)
+			if version-previous > 2 {
+				missing = fmt.Sprintf("versions %d to %d are", previous+1, version-1)
+			}
+			findings = append(findings, &lintFinding{File: filepath.Base(first[0].Path), Version: version,
+				Severity: lintError, Rule: "version-gap", Message: fmt.Sprintf("%s missing before %d", missing, version)})
+		}
+	}
+	return findings
+}
+
+// lintMigrationSQL flags the statements of an up migration that lock or
+// rewrite tables with data, or fail depending on their content. Tables
+// the migration creates itself are empty and not flagged.
+func lintMigrationSQL(src string) []*lintFinding {
+	statements := splitSQLStatements(src)
+	created := map[string]bool{}
+	for _, statement := range statements {
+		tokens := tokenizeSQL(statement)
+		if matchKeywords(tokens, "CREATE") && createsTable(tokens) {
+			if table, err := parseCreateTable(tokens); err == nil {
+				created[table.Name] = true
+			}
+		}
+	}
+
+	var findings []*lintFinding
+	warn := func(rule, message string) {
+		findings = append(findings, &lintFinding{Severity: lintWarning, Rule: rule, Message: message})
+	}
+	for _, statement := range statements {
+		tokens := tokenizeSQL(statement)
+		switch {
+		case matchKeywords(tokens, "CREATE", "INDEX"), matchKeywords(tokens, "CREATE", "UNIQUE", "INDEX"):
+			index := parseCreateIndex(tokens)
+			concurrent := slices.ContainsFunc(tokens, func(token sqlToken) bool { return token.is("CONCURRENTLY") })
+			if index.Table != "" && !concurrent && !created[index.Table] {
+				warn("non-concurrent-index", fmt.Sprintf("CREATE INDEX on %s blocks writes while it builds, use CREATE INDEX CONCURRENTLY in a migration of its own", index.Table))
+			}
+		case matchKeywords(tokens, "ALTER", "TABLE"):
+			tokens = tokens[2:]
+			if matchKeywords(tokens, "IF", "EXISTS") {
+				tokens = tokens[2:]
+			}
+			if matchKeywords(tokens, "ONLY") {
+				tokens = tokens[1:]
+			}
+			if len(tokens) == 0 {
+				continue
+			}
+			table := sqlIdentifier(tokens[0].Text)
+			if created[table] {
+				continue
+			}
+			for _, action := range splitSQLTokens(tokens[1:]) {
+				if rule, message := lintAlterAction(table, action); rule != "" {
+					warn(rule, message)
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// lintAlterAction checks an action of an ALTER TABLE statement
+func lintAlterAction(table string, action []sqlToken) (string, string) {
+	switch {
+	case matchKeywords(action, "DROP"):
+		action = action[1:]
+		if matchKeywords(action, "COLUMN") {
+			action = action[1:]
+		}
+		if len(action) == 0 || isTableConstraint(action) || matchKeywords(action, "IF", "EXISTS") {
+			return "", ""
+		}
+		return "drop-column", fmt.Sprintf("DROP COLUMN %s.%s has no IF EXISTS guard and fails when the column is already gone", table, sqlIdentifier(action[0].Text))
+	case matchKeywords(action, "ADD"):
+		action = action[1:]
+		if matchKeywords(action, "COLUMN") {
+			action = action[1:]
+		}
+		if matchKeywords(action, "IF", "NOT", "EXISTS") {
+			action = action[3:]
+		}
+		if len(action) == 0 || isTableConstraint(action) {
+			return "", ""
+		}
+		column, _, _, err := parseColumn(action)
+		if err != nil || !column.NotNull || column.Default != "" || column.Generated || strings.HasPrefix(column.Type, "serial") {
+			return "", ""
+		}
+		return "not-null-without-default", fmt.Sprintf("ADD COLUMN %s.%s is NOT NULL without a DEFAULT and fails when the table has rows", table, column.Name)
+	case matchKeywords(action, "ALTER"):
+		action = action[1:]
+		if matchKeywords(action, "COLUMN") {
+			action = action[1:]
+		}
+		if len(action) < 2 {
+			return "", ""
+		}
+		rest := action[1:]
+		if matchKeywords(rest, "SET", "DATA") {
+			rest = rest[2:]
+		}
+		if !matchKeywords(rest, "TYPE") {
+			return "", ""
+		}
+		rest = rest[1:]
+		for i, token := range rest {
+			if token.is("USING") || token.is("COLLATE") {
+				rest = rest[:i]
+				break
+			}
+		}
+		return "type-change", fmt.Sprintf("changing the type of %s.%s to %s rewrites the table under an exclusive lock and can fail on existing values",
+			table, sqlIdentifier(action[0].Text), joinSQLTokens(rest))
+	}
+	return "", ""
+}
+
+func init() {
+	migrateLintCmd.Flags().StringVar(&migrateLintFormat, "format", "text", "Output format, text or json")
+	migrateLintCmd.Flags().BoolVar(&migrateLintStrict, "strict", false, "Also fail when only warnings are found")
+	migrateCmd.AddCommand(migrateLintCmd)
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+func TestLintMigrationLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		unparsed []string
+		want     []string
+	}{
+		{
+			name:  "complete",
+			files: []string{"000001_a.up.sql", "000001_a.down.sql", "000002_b.up.sql", "000002_b.down.sql"},
+		},
+		{
+			name:     "unparsed sql files only",
+			files:    []string{"1_a.up.sql", "1_a.down.sql"},
+			unparsed: []string{"README.md", "2_b.sql", "seed.up.sql"},
+			want: []string{
+				"2_b.sql unparsed-file warning",
+				"seed.up.sql unparsed-file warning",
+			},
+		},
+		{
+			name:  "missing directions",
+			files: []string{"1_a.up.sql", "2_b.down.sql"},
+			want: []string{
+				"1_a.up.sql missing-down error version 1 has no down migration",
+				"2_b.down.sql missing-up error version 2 has no up migration",
+			},
+		},
+		{
+			name:  "duplicate versions",
+			files: []string{"1_a.up.sql", "1_a.down.sql", "1_b.up.sql"},
+			want: []string{
+				"1_a.up.sql duplicate-version error version 1 has several up files: 1_a.up.sql, 1_b.up.sql",
+			},
+		},
+		{
+			name:  "gaps",
+			files: []string{"2_b.up.sql", "2_b.down.sql", "3_c.up.sql", "3_c.down.sql", "7_g.up.sql", "7_g.down.sql"},
+			want: []string{
+				"2_b.up.sql version-gap error version 1 is missing before 2",
+				"7_g.up.sql version-gap error versions 4 to 6 are missing before 7",
+			},
+		},
+		{
+			name: "timestamps have no gaps",
+			files: []string{
+				"20240101000000_a.up.sql", "20240101000000_a.down.sql",
+				"20240301120000_b.up.sql", "20240301120000_b.down.sql",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var files []*migrationFile
+			for _, name := range tt.files {
+				files = append(files, testMigrationFile(t, name))
+			}
+			var got []string
+			for _, finding := range lintMigrationLayout(files, tt.unparsed) {
+				line := fmt.Sprintf("%s %s %s", finding.File, finding.Rule, finding.Severity)
+				if finding.Severity == lintError {
+					line += " " + finding.Message
+				}
+				got = append(got, line)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("lintMigrationLayout() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// testMigrationFile builds the migrationFile of a name such as
+// 000001_init.up.sql
+func testMigrationFile(t *testing.T, name string) *migrationFile {
+	t.Helper()
+	prefix, rest, _ := strings.Cut(name, "_")
+	version, err := strconv.ParseUint(prefix, 10, 64)
+	if err != nil {
+		t.Fatalf("invalid migration name %s", name)
+	}
+	direction := source.Up
+	if strings.HasSuffix(rest, ".down.sql") {
+		direction = source.Down
+	}
+	return &migrationFile{
+		Path:       "migrations/" + name,
+		Version:    uint(version),
+		Prefix:     prefix,
+		Identifier: strings.TrimSuffix(strings.TrimSuffix(rest, ".up.sql"), ".down.sql"),
+		Direction:  direction,
+	}
+}
+
+func TestLintAlterAction(t *testing.T) {
+	tests := []struct {
+		action  string
+		rule    string
+		message string
+	}{
+		{action: "DROP COLUMN legacy", rule: "drop-column",
+			message: "DROP COLUMN users.legacy has no IF EXISTS guard and fails when the column is already gone"},
+		{action: `DROP "Legacy" CASCADE`, rule: "drop-column",
+			message: "DROP COLUMN users.Legacy has no IF EXISTS guard and fails when the column is already gone"},
+		{action: "DROP COLUMN IF EXISTS legacy"},
+		{action: "DROP CONSTRAINT users_email_key"},
+		{action: "ADD COLUMN age int4 NOT NULL", rule: "not-null-without-default",
+			message: "ADD COLUMN users.age is NOT NULL without a DEFAULT and fails when the table has rows"},
+		{action: "ADD IF NOT EXISTS age int4 NOT NULL", rule: "not-null-without-default",
+			message: "ADD COLUMN users.age is NOT NULL without a DEFAULT and fails when the table has rows"},
+		{action: "ADD COLUMN age int4 NOT NULL DEFAULT 0"},
+		{action: "ADD COLUMN age int4"},
+		{action: "ADD COLUMN id bigserial NOT NULL"},
+		{action: "ADD COLUMN total int4 NOT NULL GENERATED ALWAYS AS (a + b) STORED"},
+		{action: "ADD CONSTRAINT users_age_check CHECK (age > 0)"},
+		{action: "ALTER COLUMN age TYPE int8", rule: "type-change",
+			message: "changing the type of users.age to int8 rewrites the table under an exclusive lock and can fail on existing values"},
+		{action: "ALTER age SET DATA TYPE numeric(10, 2) USING age::numeric", rule: "type-change",
+			message: "changing the type of users.age to numeric(10, 2) rewrites the table under an exclusive lock and can fail on existing values"},
+		{action: "ALTER COLUMN age SET NOT NULL"},
+		{action: "RENAME COLUMN age TO years"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			actions := splitSQLTokens(tokenizeSQL(tt.action))
+			if len(actions) != 1 {
+				t.Fatalf("expected one action, got %d", len(actions))
+			}
+			rule, message := lintAlterAction("users", actions[0])
+			if rule != tt.rule || message != tt.message {
+				t.Errorf("lintAlterAction() = %q, %q, want %q, %q", rule, message, tt.rule, tt.message)
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"unicode"
 
@@ -22,11 +21,14 @@ var moduleCmd = &cobra.Command{
 var moduleAddCmd = &cobra.Command{
 	Use:   "add [module-name] [target-module]",
 	Short: "Add a new gRPC module",
-	Long:  "Creates a new gRPC module with handlers based on proto files",
-	Args:  cobra.ExactArgs(2),
+	Long:  "Creates a new gRPC module with handlers based on proto files. The target module defaults to the module in grpcframe.yaml",
+	Args:  cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
 		moduleName := args[0]
-		targetModule := args[1]
+		targetModule := ""
+		if len(args) > 1 {
+			targetModule = args[1]
+		}
 		if err := addModule(moduleName, targetModule); err != nil {
 			pkg.Red.Printf("Failed to add module: %v\n", err)
 			os.Exit(1)
@@ -41,27 +43,99 @@ type ModuleConfig struct {
 	AppPath        string
 	ProtogenPath   string
 	ProtoFiles     []string
+	Services       []*ModuleService
 	ServiceMethods []ServiceMethod
+	PbImport       GoImport
+	Entity         *GoType
+	EntityMessage  *ProtoMessage
+	Manifest       *Manifest
+	State          *GeneratedState
+	// Table is the schema table a crud module is bound to. Its services
+	// call the sqlc store.
+	Table string
+	// Store is set when the services take the sqlc Querier
+	Store bool
+	// Model maps Entity to the sqlc model of the same name, nil when the
+	// model does not exist
+	Model *ModelMapping
+}
+
+// ModuleService is a proto service implemented by the module
+type ModuleService struct {
+	Name       string
+	GoName     string
+	StructName string
+	FileName   string
+	Comment    string
+	PbImport   GoImport
+	Methods    []ServiceMethod
 }
 
+// ServiceMethod is an rpc of a module service. RequestType, ResponseType
+// and StreamType are qualified Go type expressions.
 type ServiceMethod struct {
-	Name         string
-	RpcName      string
-	RequestType  string
-	ResponseType string
-	FileName     string
+	Name            string
+	RpcName         string
+	RequestType     string
+	ResponseType    string
+	StreamType      string
+	FileName        string
+	ServiceStruct   string
+	ServiceGoName   string
+	ClientStreaming bool
+	ServerStreaming bool
+	HTTPRules       []HTTPRule
+	Comment         string
+	Imports         []GoImport
+	Descriptor      *ProtoMethod
+	// Query is the sqlc query the handler calls, nil for stubs
+	Query *QueryCall
 }
 
-func addModule(moduleName, targetModule string) error {
-	pkg.InfoLog("Starting to create new module", moduleName, "under target module", targetModule)
+// newModuleConfig resolves the module paths from the project manifest
+func newModuleConfig(moduleName, targetModule string) (*ModuleConfig, error) {
+	manifest, err := loadManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	if targetModule == "" {
+		targetModule = manifest.Module
+	}
+	manifest.Module = targetModule
 
-	config := &ModuleConfig{
+	state, err := loadGeneratedState()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ModuleConfig{
 		ModuleName:   moduleName,
 		TargetModule: targetModule,
-		ProtoPath:    filepath.Join("proto", moduleName),
-		AppPath:      filepath.Join("app", "rpc", moduleName),
-		ProtogenPath: "protogen",
+		ProtoPath:    filepath.Join(manifest.Layout.Proto, moduleName),
+		AppPath:      filepath.Join(manifest.Layout.RPC, moduleName),
+		ProtogenPath: manifest.Layout.Protogen,
+		Manifest:     manifest,
+		State:        state,
+		Table:        state.Modules[moduleName].Table,
+		Store:        manifest.Features.SQLC,
+	}, nil
+}
+
+func addModule(moduleName, targetModule string) error {
+	config, err := newModuleConfig(moduleName, targetModule)
+	if err != nil {
+		return err
 	}
+	pkg.InfoLog("Starting to create new module", moduleName, "under target module", config.TargetModule)
+
+	return withRollback(protogenOutputs(config.Manifest), func() error {
+		return createModule(config)
+	})
+}
+
+// createModule runs the generation steps of module add
+func createModule(config *ModuleConfig) error {
+	moduleName := config.ModuleName
 
 	pkg.InfoLog("Validating proto directory...")
 	if err := validateProtoDirectory(config.ProtoPath); err != nil {
@@ -70,7 +144,7 @@ func addModule(moduleName, targetModule string) error {
 	pkg.SuccessLog("Proto directory validated")
 
 	pkg.InfoLog("Generating protobuf files...")
-	if err := runProtogen(); err != nil {
+	if err := runProtogen(config.Manifest); err != nil {
 		return fmt.Errorf("protogen failed: %w", err)
 	}
 
@@ -100,22 +174,26 @@ func addModule(moduleName, targetModule string) error {
 	if err := generateHandlerFiles(config); err != nil {
 		return fmt.Errorf("failed to generate handler files: %w", err)
 	}
-	pkg.SuccessLog("Generated", len(config.ServiceMethods), "handler files")
+	logChange("Generated", "Would generate", len(config.ServiceMethods), "handler files")
 
 	pkg.InfoLog("Generating converter file...")
 	if err := generateConverterFile(config); err != nil {
 		return fmt.Errorf("failed to generate converter file: %w", err)
 	}
 
+	if err := config.State.Save(); err != nil {
+		return fmt.Errorf("failed to save generator state: %w", err)
+	}
+
 	pkg.InfoLog("Formatting generated code...")
-	if err := exec.Command("go", "fmt", "./...").Run(); err != nil {
-		return fmt.Errorf("failed to go fmt: %w", err)
+	if err := formatGoFiles(); err != nil {
+		return fmt.Errorf("failed to format generated code: %w", err)
 	}
 	pkg.InfoLog("Updating module dependencies...")
-	if err := exec.Command("go", "mod", "tidy").Run(); err != nil {
+	if err := runExternal("go mod tidy", exec.Command("go", "mod", "tidy").Run); err != nil {
 		return fmt.Errorf("failed to go mod tidy: %v", err.Error())
 	}
-	pkg.SuccessBox(fmt.Sprintf("Module '%s' created successfully!", moduleName))
+	successBox(fmt.Sprintf("Module '%s' created successfully!", moduleName))
 	pkg.InfoLog("Next steps:")
 	pkg.InfoLog("1. Implement business logic in the generated handlers")
 	pkg.InfoLog("2. Register service in the gRPC server")
@@ -143,44 +221,157 @@ func validateProtoDirectory(protoPath string) error {
 }
 
 func extractServiceMethods(config *ModuleConfig) error {
-	pkg.InfoLog("Searching for generated gRPC files...")
+	pkg.InfoLog("Parsing proto descriptors...")
+
+	set, err := parseProtoSet(config.Manifest)
+	if err != nil {
+		return fmt.Errorf("failed to parse proto files: %w", err)
+	}
+	if err := loadModuleServices(config, set); err != nil {
+		return err
+	}
+	if config.Table != "" {
+		err = bindTable(config, set)
+	} else {
+		err = bindQueries(config, set)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(config.ServiceMethods) == 0 {
+		return fmt.Errorf("no service methods found for module %s", config.ModuleName)
+	}
+
+	pkg.SuccessLog(fmt.Sprintf("Total service methods discovered: %d", len(config.ServiceMethods)))
+	return nil
+}
 
-	patterns := []string{
-		filepath.Join(config.ProtogenPath, config.ModuleName, "*_grpc.pb.go"),
+// loadModuleServices fills the module services, methods and entity type
+// from the proto descriptors of the module directory
+func loadModuleServices(config *ModuleConfig, set *ProtoSet) error {
+	files := set.FilesInDir(config.ModuleName)
+	if len(files) == 0 {
+		return fmt.Errorf("no proto files found for module %s in %s", config.ModuleName, config.ProtoPath)
+	}
+	config.PbImport = files[0].GoImport
+
+	services := set.ServicesInDir(config.ModuleName)
+	for _, descriptor := range services {
+		service := &ModuleService{
+			Name:       descriptor.Name,
+			GoName:     descriptor.GoName(),
+			StructName: serviceStructName(descriptor.Name),
+			FileName:   "service.go",
+			Comment:    descriptor.Comment,
+			PbImport:   descriptor.File.GoImport,
+		}
+		if len(services) > 1 {
+			service.FileName = fmt.Sprintf("service_%s.go", serviceFilePrefix(service))
+		}
+		pkg.InfoLog(fmt.Sprintf("Found service %s with %d methods", descriptor.Name, len(descriptor.Methods)))
+
+		for _, methodDescriptor := range descriptor.Methods {
+			method, err := newServiceMethod(set, service, methodDescriptor)
+			if err != nil {
+				return fmt.Errorf("service %s: %w", descriptor.Name, err)
+			}
+			if len(services) > 1 {
+				method.FileName = fmt.Sprintf("rpc_%s_%s.go", serviceFilePrefix(service), camelToSnake(method.Name))
+			}
+			service.Methods = append(service.Methods, method)
+			config.ServiceMethods = append(config.ServiceMethods, method)
+		}
+		config.Services = append(config.Services, service)
 	}
 
-	var grpcFiles []string
-	for _, pattern := range patterns {
-		files, err := filepath.Glob(pattern)
+	if entity := findEntity(files, config.ModuleName); entity != nil {
+		goType, err := set.GoType(entity.FullName)
 		if err != nil {
-			continue
+			return err
 		}
-		grpcFiles = append(grpcFiles, files...)
+		config.Entity = &goType
+		config.EntityMessage = entity
+	}
+	return nil
+}
+
+// newServiceMethod builds the generator model of a single rpc
+func newServiceMethod(set *ProtoSet, service *ModuleService, descriptor *ProtoMethod) (ServiceMethod, error) {
+	request, err := set.GoType(descriptor.InputType)
+	if err != nil {
+		return ServiceMethod{}, fmt.Errorf("method %s: %w", descriptor.Name, err)
+	}
+	response, err := set.GoType(descriptor.OutputType)
+	if err != nil {
+		return ServiceMethod{}, fmt.Errorf("method %s: %w", descriptor.Name, err)
 	}
 
-	if len(grpcFiles) == 0 {
-		return fmt.Errorf("no gRPC files found for module %s in %s", config.ModuleName, config.ProtogenPath)
+	imports := []GoImport{service.PbImport}
+	for _, goType := range []GoType{request, response} {
+		if !containsImport(imports, goType.Import) {
+			imports = append(imports, goType.Import)
+		}
 	}
 
-	pkg.InfoLog(fmt.Sprintf("Found %d gRPC definition files", len(grpcFiles)))
+	return ServiceMethod{
+		Name:            goCamelCase(descriptor.Name),
+		RpcName:         descriptor.Name,
+		RequestType:     request.String(),
+		ResponseType:    response.String(),
+		StreamType:      fmt.Sprintf("%s.%s_%sServer", service.PbImport.Alias, service.GoName, goCamelCase(descriptor.Name)),
+		FileName:        fmt.Sprintf("rpc_%s.go", camelToSnake(descriptor.Name)),
+		ServiceStruct:   service.StructName,
+		ServiceGoName:   service.GoName,
+		ClientStreaming: descriptor.ClientStreaming,
+		ServerStreaming: descriptor.ServerStreaming,
+		HTTPRules:       descriptor.HTTPRules,
+		Comment:         descriptor.Comment,
+		Imports:         imports,
+		Descriptor:      descriptor,
+	}, nil
+}
 
-	for i, file := range grpcFiles {
-		pkg.InfoLog(fmt.Sprintf("Parsing file %d/%d: %s", i+1, len(grpcFiles), filepath.Base(file)))
-		methods, err := parseGrpcFile(file, config.ModuleName)
-		if err != nil {
-			pkg.WarningLog(fmt.Sprintf("Skipping file %s: %v", file, err))
-			continue
+// findEntity looks for the message named after the module, which the
+// converter maps to the database model
+func findEntity(files []*ProtoFile, moduleName string) *ProtoMessage {
+	candidates := []string{toPascalCase(moduleName), strings.TrimSuffix(toPascalCase(moduleName), "s")}
+	for _, candidate := range candidates {
+		for _, file := range files {
+			for _, message := range file.Messages {
+				if message.GoName() == candidate {
+					return message
+				}
+			}
 		}
-		config.ServiceMethods = append(config.ServiceMethods, methods...)
-		pkg.InfoLog(fmt.Sprintf("Found %d methods in this file", len(methods)))
 	}
+	return nil
+}
 
-	if len(config.ServiceMethods) == 0 {
-		return fmt.Errorf("no service methods found for module %s", config.ModuleName)
+// serviceStructName returns the handler struct name of a proto service
+func serviceStructName(serviceName string) string {
+	name := goCamelCase(serviceName)
+	if strings.Contains(name, "_") {
+		name = toPascalCase(name)
+	}
+	if !strings.HasSuffix(name, "Service") {
+		name += "Service"
 	}
+	return name
+}
 
-	pkg.SuccessLog(fmt.Sprintf("Total service methods discovered: %d", len(config.ServiceMethods)))
-	return nil
+// serviceFilePrefix returns the file name prefix used when a module holds several services
+func serviceFilePrefix(service *ModuleService) string {
+	return camelToSnake(strings.TrimSuffix(service.StructName, "Service"))
+}
+
+func containsImport(imports []GoImport, imp GoImport) bool {
+	for _, existing := range imports {
+		if existing.Path == imp.Path {
+			return true
+		}
+	}
+	return false
 }
 
 func generateHandlerFiles(config *ModuleConfig) error {
@@ -188,20 +379,21 @@ func generateHandlerFiles(config *ModuleConfig) error {
 
 	for i, method := range config.ServiceMethods {
 		pkg.InfoLog(fmt.Sprintf("Creating handler %d/%d: %s", i+1, len(config.ServiceMethods), method.Name))
-		handlerContent := generateHandlerContent(config, method)
+		handlerContent, err := generateHandlerContent(config, method)
+		if err != nil {
+			return err
+		}
 		handlerPath := filepath.Join(config.AppPath, method.FileName)
-		if err := writeFile(handlerPath, handlerContent); err != nil {
+		if _, err := config.writeGeneratedFile(handlerPath, handlerContent, "module/handler.go.tmpl"); err != nil {
 			return fmt.Errorf("failed to write handler file %s: %w", handlerPath, err)
 		}
 	}
 	return nil
 }
-func runProtogen() error {
+func runProtogen(manifest *Manifest) error {
 	pkg.InfoLog("Running protogen...")
-	cmd := exec.Command("make", "protoc")
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("make proto failed: %w", err)
+	if _, err := generateProto(manifest, false); err != nil {
+		return err
 	}
 	pkg.InfoLog("Protogen completed successfully")
 	return nil
@@ -219,244 +411,114 @@ func discoverProtoFiles(config *ModuleConfig) error {
 	return nil
 }
 
-// func extractServiceMethods(config *ModuleConfig) error {
-//
-//		patterns := []string{
-//			filepath.Join(config.ProtogenPath, config.ModuleName, "*_grpc.pb.go"),
-//		}
-//
-//		var grpcFiles []string
-//		for _, pattern := range patterns {
-//			files, err := filepath.Glob(pattern)
-//			if err != nil {
-//				continue
-//			}
-//			grpcFiles = append(grpcFiles, files...)
-//		}
-//
-//		if len(grpcFiles) == 0 {
-//			return fmt.Errorf("no gRPC files found for module %s in %s", config.ModuleName, config.ProtogenPath)
-//		}
-//
-//		for _, file := range grpcFiles {
-//			methods, err := parseGrpcFile(file, config.ModuleName)
-//			if err != nil {
-//				pkg.Red.Printf("Warning: failed to parse file %s: %v\n", file, err)
-//				continue
-//			}
-//			config.ServiceMethods = append(config.ServiceMethods, methods...)
-//		}
-//
-//		if len(config.ServiceMethods) == 0 {
-//			return fmt.Errorf("no service methods found for module %s", config.ModuleName)
-//		}
-//
-//		pkg.Info(fmt.Sprintf("Found %d service methods for module %s", len(config.ServiceMethods), config.ModuleName))
-//		return nil
-//	}
-func parseGrpcFile(filePath, moduleName string) ([]ServiceMethod, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
-	}
-
-	var methods []ServiceMethod
-	lines := strings.Split(string(content), "\n")
-	serviceInterfacePattern := regexp.MustCompile(`type\s+(\w+)Server\s+interface\s*{`)
-	methodPattern := regexp.MustCompile(`^\s*(\w+)\s*\(\s*.*context\.Context\s*,\s*.*\*(\w+)\s*\)\s*\(\s*\*(\w+)\s*,\s*error\s*\)`)
-
-	var inServiceInterface bool
-
-	for _, line := range lines {
-		if match := serviceInterfacePattern.FindStringSubmatch(line); match != nil {
-			inServiceInterface = true
-			continue
-		}
-
-		if inServiceInterface && strings.Contains(line, "}") {
-			inServiceInterface = false
-			continue
-		}
-
-		if inServiceInterface {
-			if match := methodPattern.FindStringSubmatch(line); match != nil {
-				methodName := match[1]
-				requestType := match[2]
-				responseType := match[3]
-				fileName := fmt.Sprintf("rpc_%s.go", camelToSnake(methodName))
-
-				methods = append(methods, ServiceMethod{
-					Name:         methodName,
-					RpcName:      methodName,
-					RequestType:  requestType,
-					ResponseType: responseType,
-					FileName:     fileName,
-				})
-			}
-		}
-	}
-
-	return methods, nil
-}
 func createModuleDirectory(appPath string) error {
-	if err := os.MkdirAll(appPath, 0755); err != nil {
+	if err := makeDir(appPath); err != nil {
 		return fmt.Errorf("failed to create module directory: %w", err)
 	}
 	return nil
 }
 
 func generateServiceFile(config *ModuleConfig) error {
-	serviceContent := generateServiceContent(config)
-	servicePath := filepath.Join(config.AppPath, "service.go")
-	if err := writeFile(servicePath, serviceContent); err != nil {
-		return fmt.Errorf("failed to write service file: %w", err)
+	for _, service := range config.Services {
+		serviceContent, err := generateServiceContent(config, service)
+		if err != nil {
+			return err
+		}
+		servicePath := filepath.Join(config.AppPath, service.FileName)
+		if _, err := config.writeGeneratedFile(servicePath, serviceContent, "module/service.go.tmpl"); err != nil {
+			return fmt.Errorf("failed to write service file: %w", err)
+		}
 	}
 	return nil
 }
 
-func generateServiceContent(config *ModuleConfig) string {
-	packageName := strings.ToLower(config.ModuleName)
-	serviceName := toPascalCase(config.ModuleName)
-	pbImportPath := fmt.Sprintf("%s/protogen/%s", config.TargetModule, packageName)
-	pbPackage := fmt.Sprintf("%spb", packageName)
-	return fmt.Sprintf(`package %s
-
-import (
-	%s "%s"
-)
-
-type %sService struct {
-	%s.Unimplemented%sServiceServer
+// ServiceTemplateData is the data rendered into a service template
+type ServiceTemplateData struct {
+	Module  *ModuleConfig
+	Service *ModuleService
 }
 
-func New%sService() *%sService {
-	return &%sService{}
-}
-`, packageName, pbPackage, pbImportPath, serviceName, pbPackage, serviceName, serviceName, serviceName, serviceName)
+func generateServiceContent(config *ModuleConfig, service *ModuleService) (string, error) {
+	return renderGoTemplate("module/service.go.tmpl", &ServiceTemplateData{Module: config, Service: service})
 }
 
 func generateConverterFile(config *ModuleConfig) error {
-	converterContent := generateConverterContent(config)
+	if config.Entity == nil {
+		pkg.WarningLog(fmt.Sprintf("No %s message found in %s, skipping converter", toPascalCase(config.ModuleName), config.ProtoPath))
+		return nil
+	}
+	if config.Model == nil {
+		pkg.WarningLog(fmt.Sprintf("No %s model found in %s, skipping converter", config.Entity.Name, config.Manifest.Layout.Repo))
+		return nil
+	}
+	converterContent, err := generateConverterContent(config)
+	if err != nil {
+		return err
+	}
 	converterPath := filepath.Join(config.AppPath, "converter.go")
-	if err := writeFile(converterPath, converterContent); err != nil {
+	if _, err := config.writeGeneratedFile(converterPath, converterContent, "module/converter.go.tmpl"); err != nil {
 		return fmt.Errorf("failed to write converter file: %w", err)
 	}
 	return nil
 }
 
-func generateConverterContent(config *ModuleConfig) string {
-	packageName := strings.ToLower(config.ModuleName)
-	modelImportPath := fmt.Sprintf("%s/internal/repo", config.TargetModule)
-	pbImportPath := fmt.Sprintf("%s/protogen/%s", config.TargetModule, packageName)
+func generateConverterContent(config *ModuleConfig) (string, error) {
+	return renderGoTemplate("module/converter.go.tmpl", config)
+}
 
-	return fmt.Sprintf(`package %s
+// HandlerTemplateData is the data rendered into a handler template
+type HandlerTemplateData struct {
+	Module *ModuleConfig
+	Method ServiceMethod
+}
 
-import (
-    "%s"
-     %spb "%s"
- 
-)
+func generateHandlerContent(config *ModuleConfig, method ServiceMethod) (string, error) {
+	return renderGoTemplate("module/handler.go.tmpl", &HandlerTemplateData{Module: config, Method: method})
+}
 
-// ProtoToModel converts protobuf message to database model
-func ProtoToModel(pb *%spb.%s) *repo.%s {
-    if pb == nil {
-        return nil
-    }
-    
-    return &repo.%s{
-        // Add your conversion fields here
-        // Example:
-        // ID:        pb.GetId(),
-        // Name:      pb.GetName(),
-        // CreatedAt: time.Unix(pb.GetCreatedAt().GetSeconds(), int64(pb.GetCreatedAt().GetNanos())),
-    }
-}
-
-// ModelToProto converts database model to protobuf message
-func ModelToProto(model *repo.%s) *%spb.%s {
-    if model == nil {
-        return nil
-    }
-    
-    return &%spb.%s{
-        // Add your conversion fields here
-        // Example:
-        // Id:        model.ID,
-        // Name:      model.Name,
-        // CreatedAt: timestamppb.New(model.CreatedAt),
-    }
-}
-
-// ModelsToProtos converts slice of models to slice of protos
-func ModelsToProtos(models []*repo.%s) []*%spb.%s {
-    protos := make([]*%spb.%s, len(models))
-    for i, model := range models {
-        protos[i] = ModelToProto(model)
-    }
-    return protos
-}
-
-// ProtosToModels converts slice of protos to slice of models
-func ProtosToModels(protos []*%spb.%s) []*repo.%s {
-    models := make([]*repo.%s, len(protos))
-    for i, pb := range protos {
-        models[i] = ProtoToModel(pb)
-    }
-    return models
-}
-`, packageName, modelImportPath, packageName, pbImportPath,
-		packageName, toPascalCase(config.ModuleName), toPascalCase(config.ModuleName),
-		toPascalCase(config.ModuleName),
-		toPascalCase(config.ModuleName), packageName, toPascalCase(config.ModuleName),
-		packageName, toPascalCase(config.ModuleName),
-		toPascalCase(config.ModuleName), packageName, toPascalCase(config.ModuleName),
-		packageName, toPascalCase(config.ModuleName),
-		packageName, toPascalCase(config.ModuleName), toPascalCase(config.ModuleName),
-		toPascalCase(config.ModuleName))
-}
-
-func generateHandlerContent(config *ModuleConfig, method ServiceMethod) string {
-	packageName := strings.ToLower(config.ModuleName)
-	serviceName := toPascalCase(config.ModuleName)
-	pbImportPath := fmt.Sprintf("%s/protogen/%s", config.TargetModule, packageName)
-	pbPackage := fmt.Sprintf("%spb", packageName)
-	return fmt.Sprintf(`package %s
+// writeGeneratedFile writes generated content, keeping files that were
+// edited since they were generated
+func (c *ModuleConfig) writeGeneratedFile(path, content, template string) (writeResult, error) {
+	result, err := c.State.writeGenerated(path, content, template)
+	if err != nil {
+		return result, err
+	}
+	if result == writeSkipped {
+		pkg.WarningLog("Keeping", path, "(edited since it was generated)")
+	}
+	return result, nil
+}
 
-import (
-	"context"
-	%s "%s"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
-)
+// PackageName returns the Go package name of the module handlers
+func (c *ModuleConfig) PackageName() string {
+	return strings.ToLower(c.ModuleName)
+}
 
-func (s *%sService) %s(ctx context.Context, req *%s.%s) (*%s.%s, error) {
-	if req == nil {
-		return nil, status.Error(codes.InvalidArgument, "request cannot be nil")
-	}
-	resp := &%s.%s{}
-	return resp, nil
+// PbPackage returns the import alias of the generated protobuf package
+func (c *ModuleConfig) PbPackage() string {
+	return c.PbImport.Alias
 }
-`, packageName, pbPackage, pbImportPath, serviceName, method.Name, pbPackage,
-		method.RequestType, pbPackage, method.ResponseType, pbPackage, method.ResponseType)
+
+// PbImportPath returns the import path of the generated protobuf package
+func (c *ModuleConfig) PbImportPath() string {
+	return c.PbImport.Path
 }
 
-func writeFile(filePath, content string) error {
-	file, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer file.Close()
-	if _, err := file.WriteString(content); err != nil {
-		return fmt.Errorf("failed to write content: %w", err)
-	}
-	return nil
+// RepoImportPath returns the import path of the sqlc repository package
+func (c *ModuleConfig) RepoImportPath() string {
+	return c.Manifest.ImportPath(c.Manifest.Layout.Repo)
 }
 
 func camelToSnake(s string) string {
+	runes := []rune(s)
 	var result strings.Builder
-	for i, r := range s {
+	for i, r := range runes {
 		if i > 0 && unicode.IsUpper(r) {
-			result.WriteRune('_')
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if (prevLower || nextLower) && runes[i-1] != '_' {
+				result.WriteRune('_')
+			}
 		}
 		result.WriteRune(unicode.ToLower(r))
 	}
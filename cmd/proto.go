@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+var protoCmd = &cobra.Command{
+	Use:   "proto",
+	Short: "Proto source commands",
+	Long:  "Commands for scaffolding and maintaining the .proto sources of the modules",
+}
+
+var protoNewRPCs []string
+
+var protoNewCmd = &cobra.Command{
+	Use:   "new [module-name]",
+	Short: "Scaffold the proto sources of a new module",
+	Long: "Writes <module>.proto with the entity message, rpc_<module>_<verb>.proto with the request and response " +
+		"messages of every rpc and service.<module>.proto with google.api.http and openapiv2 annotations. " +
+		"The proto package and the http routes follow the api settings in " + manifestFileName,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := newProtoModule(args[0], protoNewRPCs); err != nil {
+			pkg.Red.Printf("Failed to scaffold proto files: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// ProtoScaffold is the data rendered into the proto templates of a module
+type ProtoScaffold struct {
+	Manifest  *Manifest
+	Module    string
+	Package   string
+	GoPackage string
+	Entity    string
+	// EntityFile is the proto file declaring the entity, empty when the
+	// module has none and the rpc messages cannot reference it
+	EntityFile string
+	// EntityField names the request and response fields holding the entity
+	EntityField string
+	// EntityFields and EntityImports declare the entity message
+	EntityFields  []string
+	EntityImports []string
+	IDField       string
+	IDType        string
+	Service       string
+	RPCs          []ProtoRPCScaffold
+}
+
+// ProtoRPCScaffold is an rpc of a scaffolded service together with the
+// file holding its request and response messages
+type ProtoRPCScaffold struct {
+	Scaffold    *ProtoScaffold
+	Name        string
+	FileName    string
+	ImportPath  string
+	Imports     []string
+	Request     ProtoMessageScaffold
+	Response    ProtoMessageScaffold
+	HTTP        HTTPRule
+	Summary     string
+	Description string
+	// Definition is the rpc rendered from proto/method.proto.tmpl
+	Definition string
+}
+
+// ProtoMessageScaffold is a message with its field declarations
+type ProtoMessageScaffold struct {
+	Name   string
+	Fields []string
+}
+
+func newProtoModule(module string, verbs []string) error {
+	if !moduleNamePattern.MatchString(module) {
+		return fmt.Errorf("invalid module name %q (use lower case letters, digits and underscores)", module)
+	}
+	if len(verbs) == 0 {
+		return fmt.Errorf("at least one rpc is required, for example --rpc Create,Get,List")
+	}
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+
+	protoDir := filepath.Join(manifest.Layout.Proto, module)
+	if existing, _ := filepath.Glob(filepath.Join(protoDir, "*.proto")); len(existing) > 0 {
+		return fmt.Errorf("%s already has proto files, add rpcs with grpcframe rpc add", protoDir)
+	}
+
+	scaffold := newProtoScaffold(manifest, module)
+	if err := scaffold.addRPCs(verbs); err != nil {
+		return err
+	}
+	if err := scaffold.write(protoDir); err != nil {
+		return err
+	}
+
+	for _, dependency := range []string{"google/api/annotations.proto", "protoc-gen-openapiv2/options/annotations.proto"} {
+		if _, err := os.Stat(filepath.Join(manifest.Layout.Proto, dependency)); os.IsNotExist(err) {
+			pkg.WarningLog(dependency, "not found in", manifest.Layout.Proto, "- vendor it before running protogen")
+		}
+	}
+	successBox(fmt.Sprintf("Proto files for '%s' created", module))
+	pkg.InfoLog("Next: grpcframe module add", module)
+	return nil
+}
+
+func newProtoScaffold(manifest *Manifest, module string) *ProtoScaffold {
+	entity := toPascalCase(module)
+	idField := module + "_id"
+	return &ProtoScaffold{
+		Manifest:    manifest,
+		Module:      module,
+		Package:     manifest.ProtoPackage(module),
+		GoPackage:   manifest.ProtoGoImportPath(module + "/" + module + ".proto"),
+		Entity:      entity,
+		EntityFile:  path.Join(module, module+".proto"),
+		EntityField: module,
+		EntityFields: []string{
+			"string " + idField + " = 1;",
+			"google.protobuf.Timestamp created_at = 2;",
+			"google.protobuf.Timestamp updated_at = 3;",
+		},
+		EntityImports: []string{"google/protobuf/timestamp.proto"},
+		IDField:       idField,
+		IDType:        "string",
+		Service:       entity + "Service",
+	}
+}
+
+// addRPCs scaffolds the rpcs of the given verbs
+func (s *ProtoScaffold) addRPCs(verbs []string) error {
+	seen := map[string]bool{}
+	for _, verb := range verbs {
+		verb = strings.TrimSpace(verb)
+		if verb == "" {
+			continue
+		}
+		rpc := s.newRPC(strings.ToUpper(verb[:1]) + verb[1:])
+		if seen[rpc.Name] {
+			return fmt.Errorf("rpc %s is listed twice", rpc.Name)
+		}
+		seen[rpc.Name] = true
+		var err error
+		if rpc.Definition, err = renderRPCDefinition(rpc); err != nil {
+			return err
+		}
+		s.RPCs = append(s.RPCs, rpc)
+	}
+	return nil
+}
+
+// write renders the entity, service and rpc message files into protoDir
+func (s *ProtoScaffold) write(protoDir string) error {
+	files := map[string]projectFile{
+		s.Module + ".proto":              {"proto/entity.proto.tmpl", s},
+		"service." + s.Module + ".proto": {"proto/service.proto.tmpl", s},
+	}
+	for _, rpc := range s.RPCs {
+		files[rpc.FileName] = projectFile{"proto/rpc.proto.tmpl", rpc}
+	}
+
+	if err := makeDir(protoDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", protoDir, err)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file := files[name]
+		content, err := renderTemplate(file.Template, file.Data)
+		if err != nil {
+			return err
+		}
+		if err := createFileWithContent(filepath.Join(protoDir, name), content); err != nil {
+			return fmt.Errorf("failed to create %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// newRPC scaffolds an rpc from a verb. Create, Get, List, Update and
+// Delete follow the usual resource conventions, other verbs become a
+// custom POST method on the collection.
+func (s *ProtoScaffold) newRPC(verb string) ProtoRPCScaffold {
+	entity, route := s.Entity, s.Manifest.HTTPRoute(s.Module)
+	entityField := fmt.Sprintf("%s %s = 1;", entity, s.EntityField)
+	idField := fmt.Sprintf("%s %s = 1;", s.IDType, s.IDField)
+	resource := strings.ToLower(splitWords(entity))
+
+	rpc := ProtoRPCScaffold{Scaffold: s, Name: verb + entity, Summary: splitWords(verb) + " " + resource}
+	var request, response []string
+	usesEntity := true
+	switch verb {
+	case "Create":
+		request, response = []string{entityField}, []string{entityField}
+		rpc.HTTP = HTTPRule{Method: "post", Path: route, Body: "*"}
+		rpc.Description = "Creates a " + resource
+	case "Get":
+		request, response = []string{idField}, []string{entityField}
+		rpc.HTTP = HTTPRule{Method: "get", Path: route + "/{" + s.IDField + "}"}
+		rpc.Description = "Returns a " + resource + " by id"
+	case "List":
+		rpc.Name = verb + pluralize(entity)
+		rpc.Summary = "List " + strings.ToLower(splitWords(pluralize(entity)))
+		request = []string{"int32 page_size = 1;", "string page_token = 2;"}
+		response = []string{fmt.Sprintf("repeated %s %s = 1;", entity, pluralize(s.EntityField)), "string next_page_token = 2;"}
+		rpc.HTTP = HTTPRule{Method: "get", Path: route}
+		rpc.Description = "Lists " + strings.ToLower(splitWords(pluralize(entity))) + " page by page"
+	case "Update":
+		request, response = []string{entityField}, []string{entityField}
+		rpc.HTTP = HTTPRule{Method: "patch", Path: route + "/{" + s.EntityField + "." + s.IDField + "}", Body: "*"}
+		rpc.Description = "Updates a " + resource
+	case "Delete":
+		request = []string{idField}
+		rpc.HTTP = HTTPRule{Method: "delete", Path: route + "/{" + s.IDField + "}"}
+		rpc.Description = "Deletes a " + resource
+		usesEntity = false
+	default:
+		rpc.HTTP = HTTPRule{Method: "post", Path: route + "/" + camelToSnake(verb), Body: "*"}
+		rpc.Description = rpc.Summary
+		usesEntity = false
+	}
+	if usesEntity && s.EntityFile == "" {
+		request, response = withoutEntity(request, entity), withoutEntity(response, entity)
+		usesEntity = false
+	}
+	rpc.Request = ProtoMessageScaffold{Name: rpc.Name + "Request", Fields: request}
+	rpc.Response = ProtoMessageScaffold{Name: rpc.Name + "Response", Fields: response}
+
+	rpc.FileName = fmt.Sprintf("rpc_%s_%s.proto", s.Module, camelToSnake(verb))
+	rpc.ImportPath = path.Join(s.Module, rpc.FileName)
+	if usesEntity {
+		rpc.Imports = []string{s.EntityFile}
+	}
+	return rpc
+}
+
+// withoutEntity drops the fields of the entity type
+func withoutEntity(fields []string, entity string) []string {
+	var kept []string
+	for _, field := range fields {
+		if !strings.HasPrefix(strings.TrimPrefix(field, "repeated "), entity+" ") {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
+
+// renderRPCDefinition renders the rpc declaration of a service
+func renderRPCDefinition(rpc ProtoRPCScaffold) (string, error) {
+	definition, err := renderTemplate("proto/method.proto.tmpl", rpc)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(definition, "\n"), nil
+}
+
+// pluralize returns the naive English plural of a name
+func pluralize(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return name[:len(name)-1] + "ies"
+	}
+	return name + "s"
+}
+
+// splitWords turns CamelCase into space separated words
+func splitWords(name string) string {
+	words := strings.Split(camelToSnake(name), "_")
+	if len(words) > 0 && words[0] != "" {
+		words[0] = strings.ToUpper(words[0][:1]) + words[0][1:]
+	}
+	return strings.Join(words, " ")
+}
+
+func init() {
+	protoNewCmd.Flags().StringSliceVar(&protoNewRPCs, "rpc", []string{"Create", "Get", "List", "Update", "Delete"}, "Rpc verbs to scaffold, comma separated")
+	protoCmd.AddCommand(protoNewCmd)
+	rootCmd.AddCommand(protoCmd)
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+// enumsModule is the proto directory holding the enums of the schema
+const enumsModule = "enums"
+
+var protoEnumsCmd = &cobra.Command{
+	Use:   "enums",
+	Short: "Generate proto enums from the schema enums",
+	Long: "Reads the CREATE TYPE ... AS ENUM statements of the schema directory and writes a proto enum for each of " +
+		"them to " + enumsModule + "/" + enumsModule + ".proto, together with the functions of the convertor package " +
+		"mapping the sqlc enum types to the proto enums and back. Unknown proto values convert to an InvalidArgument error",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := generateProtoEnums(); err != nil {
+			pkg.Red.Printf("Failed to generate proto enums: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// EnumsScaffold is the data rendered into proto/enums.proto.tmpl and
+// project/enums.go.tmpl
+type EnumsScaffold struct {
+	Package   string
+	GoPackage string
+	Enums     []EnumScaffold
+	PbImport  GoImport
+	Repo      GoImport
+}
+
+// EnumScaffold is a schema enum with its proto and Go names
+type EnumScaffold struct {
+	SQLName string
+	Name    string
+	Values  []EnumValueScaffold
+	// Unspecified is the proto constant of the zero value
+	Unspecified string
+}
+
+// EnumValueScaffold is an enum label and its proto constant
+type EnumValueScaffold struct {
+	Label  string
+	Name   string
+	Number int
+}
+
+func generateProtoEnums() error {
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	model, err := loadSQLCModel(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to read the schema: %w", err)
+	}
+	if len(model.Schema.Enums) == 0 {
+		return fmt.Errorf("no CREATE TYPE ... AS ENUM statement found in %s", manifest.Layout.Schema)
+	}
+	state, err := loadGeneratedState()
+	if err != nil {
+		return err
+	}
+	if err := writeProtoEnums(manifest, model, state); err != nil {
+		return err
+	}
+	if err := state.Save(); err != nil {
+		return fmt.Errorf("failed to save generator state: %w", err)
+	}
+	successBox(fmt.Sprintf("%d proto enums generated", len(model.Schema.Enums)))
+	pkg.InfoLog("Next: grpcframe protogen")
+	return nil
+}
+
+// writeProtoEnums writes the proto enums of the schema and their convertor
+// functions. Files edited since they were generated are kept.
+func writeProtoEnums(manifest *Manifest, model *sqlcModel, state *GeneratedState) error {
+	scaffold := newEnumsScaffold(manifest, model)
+	protoPath := filepath.Join(manifest.Layout.Proto, enumsModule, enumsModule+".proto")
+	convertorPath := filepath.Join(manifest.Layout.Convert, "enums.go")
+
+	protoContent, err := renderTemplate("proto/enums.proto.tmpl", scaffold)
+	if err != nil {
+		return err
+	}
+	convertorContent, err := renderGoTemplate("project/enums.go.tmpl", scaffold)
+	if err != nil {
+		return err
+	}
+	for _, file := range []struct{ path, content, template string }{
+		{protoPath, protoContent, "proto/enums.proto.tmpl"},
+		{convertorPath, convertorContent, "project/enums.go.tmpl"},
+	} {
+		if err := makeDir(filepath.Dir(file.path)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(file.path), err)
+		}
+		result, err := state.writeGenerated(file.path, file.content, file.template)
+		if err != nil {
+			return err
+		}
+		switch result {
+		case writeSkipped:
+			pkg.WarningLog("Keeping", file.path, "(edited since it was generated)")
+		case writeCreated, writeUpdated:
+			logChange("Wrote", "Would write", file.path)
+		}
+	}
+	return nil
+}
+
+func newEnumsScaffold(manifest *Manifest, model *sqlcModel) *EnumsScaffold {
+	protoFile := path.Join(enumsModule, enumsModule+".proto")
+	scaffold := &EnumsScaffold{
+		Package:   model.EnumPackage,
+		GoPackage: manifest.ProtoGoImportPath(protoFile),
+		PbImport:  GoImport{Alias: enumsModule + "pb", Path: manifest.ProtoGoImportPath(protoFile)},
+		Repo:      GoImport{Alias: model.Package, Path: manifest.ImportPath(manifest.Layout.Repo)},
+	}
+	for _, enum := range model.Schema.Enums {
+		name := model.EnumName(enum.Name)
+		prefix := protoConstantName(enum.Name)
+		e := EnumScaffold{SQLName: enum.Name, Name: name, Unspecified: prefix + "_UNSPECIFIED"}
+		seen := map[string]bool{e.Unspecified: true}
+		for i, label := range enum.Values {
+			constant := prefix + "_" + protoConstantName(label)
+			for seen[constant] {
+				constant += "_"
+			}
+			seen[constant] = true
+			e.Values = append(e.Values, EnumValueScaffold{Label: label, Name: constant, Number: i + 1})
+		}
+		scaffold.Enums = append(scaffold.Enums, e)
+	}
+	return scaffold
+}
+
+// protoConstantName turns an enum label into the upper snake case suffix
+// of its proto constant
+func protoConstantName(label string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(label) {
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+			b.WriteByte('_')
+		}
+	}
+	name := strings.TrimSuffix(b.String(), "_")
+	if name == "" {
+		return "EMPTY"
+	}
+	return name
+}
+
+func init() {
+	protoCmd.AddCommand(protoEnumsCmd)
+}
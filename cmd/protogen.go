@@ -1,18 +1,41 @@
 package cmd
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
 	"github.com/spf13/cobra"
 )
 
+var (
+	protogenForce  bool
+	protogenEngine string
+)
+
 var protogenCmd = &cobra.Command{
 	Use:   "protogen",
 	Short: "Generate protobuf files",
-	Long:  "Generates Go code from protobuf definitions using buf",
+	Long: "Generates Go code from protobuf definitions with protoc or buf, using the plugins configured in " +
+		manifestFileName + ", and embeds the OpenAPI output with statik. Generation is skipped when the proto " +
+		"files, plugin configuration and tool versions are unchanged since the last run",
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := executeProtogen(); err != nil {
 			pkg.Red.Printf("Protogen failed: %v\n", err)
@@ -21,22 +44,402 @@ var protogenCmd = &cobra.Command{
 	},
 }
 
+const (
+	// protogenCacheFile stores the input hash of the last successful generation
+	protogenCacheFile = ".grpcframe/protogen.sum"
+	// statikPackage is the directory below the doc layout directory holding the embedded swagger files
+	statikPackage = "statik"
+)
+
+// pluginInstallHints tells how to install the tools protogen runs
+var pluginInstallHints = map[string]string{
+	"protoc":                  "see https://grpc.io/docs/protoc-installation/",
+	"buf":                     "go install github.com/bufbuild/buf/cmd/buf@latest",
+	"protoc-gen-go":           "go install google.golang.org/protobuf/cmd/protoc-gen-go@latest",
+	"protoc-gen-go-grpc":      "go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@latest",
+	"protoc-gen-grpc-gateway": "go install github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-grpc-gateway@latest",
+	"protoc-gen-openapiv2":    "go install github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-openapiv2@latest",
+}
+
+// diagnosticPattern matches compiler diagnostics such as course/course.proto:12:3: message
+var diagnosticPattern = regexp.MustCompile(`^(\S+\.proto):(\d+):(\d+):\s*(.*)$`)
+
 func executeProtogen() error {
 	pkg.InfoLog("Starting protobuf code generation...")
 
-	cmd := exec.Command("make", "protoc")
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	if protogenEngine != "" {
+		if protogenEngine != "protoc" && protogenEngine != "buf" {
+			return fmt.Errorf("unsupported engine %q (expected protoc or buf)", protogenEngine)
+		}
+		manifest.Protogen.Engine = protogenEngine
+	}
+
+	return withRollback(protogenOutputs(manifest), func() error {
+		generated, err := generateProto(manifest, protogenForce)
+		if err != nil {
+			return err
+		}
+		if !generated {
+			return nil
+		}
+
+		if err := runExternal("go mod tidy", exec.Command("go", "mod", "tidy").Run); err != nil {
+			return fmt.Errorf("go mod tidy failed: %w", err)
+		}
+		pkg.InfoLog("Protobuf code generation completed successfully")
+		return nil
+	})
+}
+
+// generateProto runs the configured engine and the statik step. It reports
+// false when the inputs are unchanged and generation was skipped.
+func generateProto(manifest *Manifest, force bool) (bool, error) {
+	preferProjectTools()
+
+	files, err := collectProtoFiles(manifest.Layout.Proto)
+	if err != nil {
+		return false, err
+	}
+	if len(files) == 0 {
+		return false, fmt.Errorf("no proto files found in %s", manifest.Layout.Proto)
+	}
+
+	tools := protogenTools(manifest)
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			return false, fmt.Errorf("%s not found in PATH (%s)", tool, pluginInstallHints[tool])
+		}
+	}
+
+	hash, err := protogenInputHash(manifest, files, tools)
+	if err != nil {
+		return false, err
+	}
+	if !force && protogenUpToDate(manifest, hash) {
+		pkg.InfoLog("Protobuf code is up to date, use --force to regenerate")
+		return false, nil
+	}
+	if dryRun {
+		// The generated code and the statik archive depend on the compiler
+		// output, so the whole step waits for the plan to be applied
+		return true, runExternal(manifest.Protogen.Engine+" code generation", func() error {
+			_, err := generateProto(manifest, true)
+			return err
+		})
+	}
+
+	for _, plugin := range manifest.Protogen.Plugins {
+		if err := makeDir(plugin.Out); err != nil {
+			return false, fmt.Errorf("failed to create %s: %w", plugin.Out, err)
+		}
+	}
+
+	var sources []string
+	for _, file := range files {
+		if !manifest.IsVendoredProto(file) {
+			sources = append(sources, file)
+		}
+	}
+	command, err := protogenCommand(manifest, sources)
+	if err != nil {
+		return false, err
+	}
+
+	pkg.InfoLog(fmt.Sprintf("Generating code for %d proto files with %s...", len(sources), manifest.Protogen.Engine))
+	if err := runDiagnosticCommand(command, manifest.Layout.Proto); err != nil {
+		return false, fmt.Errorf("%s failed: %w", manifest.Protogen.Engine, err)
+	}
+
+	if manifest.Features.Swagger {
+		pkg.InfoLog("Embedding swagger files with statik...")
+		if err := generateStatik(manifest.Layout.Swagger, filepath.Join(manifest.Layout.Doc, statikPackage)); err != nil {
+			return false, fmt.Errorf("statik failed: %w", err)
+		}
+	}
+
+	if err := makeDir(filepath.Dir(protogenCacheFile)); err != nil {
+		return false, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := writeFile(protogenCacheFile, hash+"\n"); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", protogenCacheFile, err)
+	}
+	return true, nil
+}
+
+// protogenOutputs returns the paths that code generation and go mod tidy
+// change outside of grpcframe, so the rollback journal can snapshot them
+func protogenOutputs(manifest *Manifest) []string {
+	paths := []string{"go.mod", "go.sum", manifest.Layout.Protogen}
+	for _, plugin := range manifest.Protogen.Plugins {
+		if !slices.Contains(paths, plugin.Out) {
+			paths = append(paths, plugin.Out)
+		}
+	}
+	if manifest.Features.Swagger {
+		paths = append(paths, filepath.Join(manifest.Layout.Doc, statikPackage))
+	}
+	return paths
+}
+
+// collectProtoFiles returns the proto files below dir, relative to dir
+func collectProtoFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".proto" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list proto files in %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("make proto command failed: %w", err)
+// protogenTools returns the binaries the configured engine runs
+func protogenTools(manifest *Manifest) []string {
+	tools := []string{manifest.Protogen.Engine}
+	for _, plugin := range manifest.Protogen.Plugins {
+		if plugin.Path != "" {
+			tools = append(tools, plugin.Path)
+		} else {
+			tools = append(tools, "protoc-gen-"+plugin.Name)
+		}
 	}
+	return tools
+}
+
+// goPackagePlugins are the plugins that accept M flags mapping proto files to Go packages
+var goPackagePlugins = map[string]bool{"go": true, "go-grpc": true, "grpc-gateway": true}
 
-	if err := exec.Command("go", "mod", "tidy").Run(); err != nil {
-		return fmt.Errorf("go mod tidy failed: %w", err)
+// protogenCommand builds the protoc or buf invocation for the configured
+// plugins. Both place the Go code of proto/<dir> in protogen/<dir>: buf
+// through managed mode, protoc through M flags.
+func protogenCommand(manifest *Manifest, sources []string) (*exec.Cmd, error) {
+	if manifest.Protogen.Engine == "buf" {
+		template, err := renderTemplate("project/buf.gen.yaml.tmpl", &ProjectConfig{ModuleName: manifest.Module, Manifest: manifest})
+		if err != nil {
+			return nil, err
+		}
+		return exec.Command("buf", "generate", "--template", template), nil
+	}
+
+	args := []string{"-I", manifest.Layout.Proto, "--experimental_allow_proto3_optional"}
+	for _, plugin := range manifest.Protogen.Plugins {
+		if plugin.Path != "" {
+			args = append(args, fmt.Sprintf("--plugin=protoc-gen-%s=%s", plugin.Name, plugin.Path))
+		}
+		args = append(args, fmt.Sprintf("--%s_out=%s", plugin.Name, plugin.Out))
+
+		opts := append([]string{}, plugin.Opt...)
+		if goPackagePlugins[plugin.Name] {
+			for _, source := range sources {
+				opts = append(opts, fmt.Sprintf("M%s=%s", source, manifest.ProtoGoImportPath(source)))
+			}
+		}
+		if len(opts) > 0 {
+			args = append(args, fmt.Sprintf("--%s_opt=%s", plugin.Name, strings.Join(opts, ",")))
+		}
+	}
+	return exec.Command("protoc", append(args, sources...)...), nil
+}
+
+// protogenInputHash hashes everything that affects the generated code: the
+// proto sources, the engine and plugin configuration and the tool versions
+func protogenInputHash(manifest *Manifest, files []string, tools []string) (string, error) {
+	hash := sha256.New()
+
+	config, err := json.Marshal(manifest.Protogen)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode protogen config: %w", err)
+	}
+	fmt.Fprintf(hash, "module %s\nconfig %s\nswagger %t\n", manifest.Module, config, manifest.Features.Swagger)
+
+	for _, tool := range tools {
+		fmt.Fprintf(hash, "tool %s %s\n", tool, toolVersion(tool))
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(manifest.Layout.Proto, filepath.FromSlash(file)))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(hash, "file %s %x\n", file, sum)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// toolVersion returns the --version output of a binary, or its size and
+// modification time when it has no version flag
+func toolVersion(tool string) string {
+	output, err := exec.Command(tool, "--version").CombinedOutput()
+	if err == nil {
+		return strings.TrimSpace(string(output))
+	}
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return "missing"
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return path
+	}
+	return fmt.Sprintf("%s %d %d", path, info.Size(), info.ModTime().Unix())
+}
+
+// protogenUpToDate reports whether the last generation used the same inputs
+// and its output directories still exist
+func protogenUpToDate(manifest *Manifest, hash string) bool {
+	cached, err := os.ReadFile(protogenCacheFile)
+	if err != nil || strings.TrimSpace(string(cached)) != hash {
+		return false
+	}
+	for _, plugin := range manifest.Protogen.Plugins {
+		entries, err := os.ReadDir(plugin.Out)
+		if err != nil || len(entries) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// runDiagnosticCommand runs cmd and streams its output as it arrives.
+// Compiler diagnostics are printed with paths relative to the project root.
+func runDiagnosticCommand(cmd *exec.Cmd, protoDir string) error {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	if err := cmd.Start(); err != nil {
+		writer.Close()
+		reader.Close()
+		return err
+	}
+	writer.Close()
+
+	diagnostics := 0
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		match := diagnosticPattern.FindStringSubmatch(line)
+		if match == nil {
+			fmt.Println(line)
+			continue
+		}
+		diagnostics++
+		file := match[1]
+		if _, err := os.Stat(file); err != nil {
+			file = filepath.Join(protoDir, file)
+		}
+		pkg.Red.Printf("%s:%s:%s: ", file, match[2], match[3])
+		fmt.Println(match[4])
+	}
+	reader.Close()
+
+	if err := cmd.Wait(); err != nil {
+		if diagnostics > 0 {
+			return fmt.Errorf("%d diagnostics reported", diagnostics)
+		}
+		return err
 	}
-	pkg.InfoLog("Protobuf code generation completed successfully")
 	return nil
 }
 
+// statikModTime is the modification time recorded for embedded swagger files
+var statikModTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const statikSource = `// Code generated by statik. DO NOT EDIT.
+
+package statik
+
+import (
+	"github.com/rakyll/statik/fs"
+)
+
+func init() {
+	data := %s
+	fs.Register(data)
+}
+`
+
+// generateStatik zips the files of srcDir into a statik package in destDir,
+// like statik -src=srcDir -dest=filepath.Dir(destDir) -f
+func generateStatik(srcDir, destDir string) error {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	files := 0
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		// A fixed modification time keeps the output byte-identical across machines
+		header := &zip.FileHeader{Name: filepath.ToSlash(rel), Method: zip.Deflate, Modified: statikModTime}
+		header.SetMode(info.Mode())
+
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if _, err := io.Copy(w, file); err != nil {
+			return err
+		}
+		files++
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		pkg.WarningLog("No swagger directory at", srcDir, "skipping statik")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", srcDir, err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", srcDir, err)
+	}
+	if files == 0 {
+		pkg.WarningLog("No swagger files in", srcDir, "skipping statik")
+		return nil
+	}
+
+	if err := makeDir(destDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", destDir, err)
+	}
+	content := fmt.Sprintf(statikSource, strconv.Quote(archive.String()))
+	return writeFile(filepath.Join(destDir, "statik.go"), content)
+}
+
 func init() {
+	protogenCmd.Flags().BoolVar(&protogenForce, "force", false, "Regenerate even when the inputs are unchanged")
+	protogenCmd.Flags().StringVar(&protogenEngine, "engine", "", "Code generation engine, protoc or buf (default from "+manifestFileName+")")
 	rootCmd.AddCommand(protogenCmd)
 }
@@ -0,0 +1,396 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+var protoHTTPCmd = &cobra.Command{
+	Use:   "http",
+	Short: "Http binding commands",
+	Long:  "Commands for the google.api.http bindings of the module services",
+}
+
+var protoHTTPInferCmd = &cobra.Command{
+	Use:   "infer [module-name...]",
+	Short: "Infer http bindings for rpcs that have none",
+	Long: "Proposes RESTful google.api.http bindings from the method and message names of every rpc without one: " +
+		"Create is a POST with body \"*\", Get and Delete take the *_id fields as path parameters, List is a GET on " +
+		"the collection and Update a PATCH with body \"*\". Other rpcs become a POST on <collection>/<method>. " +
+		"The bindings are shown as a diff and written into the .proto files once confirmed",
+	Run: func(cmd *cobra.Command, args []string) {
+		// Every change goes through the plan so it is shown before writing
+		dryRun = true
+		if err := inferHTTPBindings(args); err != nil {
+			pkg.Red.Printf("Failed to infer http bindings: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// httpVerbs maps the leading word of an rpc name to its resource verb
+var httpVerbs = map[string]string{
+	"Create": "Create", "Add": "Create", "Register": "Create", "New": "Create",
+	"Get": "Get", "Fetch": "Get", "Find": "Get", "Read": "Get", "Retrieve": "Get",
+	"List": "List", "Search": "List",
+	"Update": "Update", "Edit": "Update", "Patch": "Update", "Modify": "Update",
+	"Delete": "Delete", "Remove": "Delete",
+}
+
+// inferredBinding is an http binding proposed for an rpc
+type inferredBinding struct {
+	Method *ProtoMethod
+	Rule   HTTPRule
+}
+
+func inferHTTPBindings(modules []string) error {
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	set, err := parseProtoSet(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to parse proto files: %w", err)
+	}
+
+	inferred := 0
+	for _, file := range set.Files {
+		if manifest.IsVendoredProto(file.Path) || len(file.Services) == 0 {
+			continue
+		}
+		module := path.Dir(file.Path)
+		if len(modules) > 0 && !containsPath(modules, module) {
+			continue
+		}
+
+		var bindings []inferredBinding
+		for _, service := range file.Services {
+			bindings = append(bindings, inferServiceBindings(manifest, set, service, module)...)
+		}
+		if len(bindings) == 0 {
+			continue
+		}
+		if err := writeHTTPBindings(filepath.Join(set.Root, filepath.FromSlash(file.Path)), bindings); err != nil {
+			return err
+		}
+		inferred += len(bindings)
+	}
+
+	if inferred == 0 {
+		pkg.SuccessLog("No rpc left without an http binding")
+		return nil
+	}
+	pkg.InfoLog("Run grpcframe protogen once the bindings are written")
+	return nil
+}
+
+// inferServiceBindings proposes a binding for every unbound rpc of a
+// service. Collections the service already routes are reused, so new rpcs
+// land next to the existing ones.
+func inferServiceBindings(manifest *Manifest, set *ProtoSet, service *ProtoService, module string) []inferredBinding {
+	collections := map[string]string{}
+	for _, method := range service.Methods {
+		verb, resource := splitRPCName(method.Name)
+		if len(method.HTTPRules) == 0 || verb == "" {
+			continue
+		}
+		collection := method.HTTPRules[0].Path
+		if i := strings.LastIndex(collection, "/"); i >= 0 && strings.HasPrefix(collection[i+1:], "{") {
+			collection = collection[:i]
+		}
+		collections[resourceKey(resource)] = collection
+	}
+
+	// Rpcs without a verb go below the collection of the resource the
+	// service manages, whether its rpcs are bound already or inferred now
+	roots := maps.Clone(collections)
+	for _, method := range service.Methods {
+		verb, resource := splitRPCName(method.Name)
+		if verb == "" {
+			continue
+		}
+		if resource == "" {
+			resource = toPascalCase(module)
+		}
+		if _, ok := roots[resourceKey(resource)]; !ok {
+			roots[resourceKey(resource)] = manifest.HTTPRoute(resourceKey(resource))
+		}
+	}
+
+	var bindings []inferredBinding
+	for _, method := range service.Methods {
+		if len(method.HTTPRules) > 0 {
+			continue
+		}
+		if method.ClientStreaming {
+			pkg.WarningLog("Skipping", service.Name+"."+method.Name, "(client streaming rpcs cannot be bound to http)")
+			continue
+		}
+		routes := collections
+		if verb, _ := splitRPCName(method.Name); verb == "" {
+			routes = roots
+		}
+		rule := inferHTTPRule(manifest, set, set.Messages[method.InputType], method.Name, module, routes)
+		pkg.InfoLog(fmt.Sprintf("%s.%s -> %s %s", service.Name, method.Name, strings.ToUpper(rule.Method), rule.Path))
+		bindings = append(bindings, inferredBinding{Method: method, Rule: rule})
+	}
+	return bindings
+}
+
+// inferHTTPRule derives the binding of an rpc from its name and the id
+// fields of its request
+func inferHTTPRule(manifest *Manifest, set *ProtoSet, request *ProtoMessage, name, module string, collections map[string]string) HTTPRule {
+	verb, resource := splitRPCName(name)
+	if resource == "" {
+		resource = toPascalCase(module)
+	}
+
+	var ids []string
+	if request != nil {
+		for _, field := range request.Fields {
+			if isIDField(field) {
+				ids = append(ids, field.Name)
+			}
+		}
+	}
+	own := ownIDField(ids, resource)
+	var parents []string
+	for _, id := range ids {
+		if id != own {
+			parents = append(parents, id)
+		}
+	}
+
+	collection, known := collections[resourceKey(resource)]
+	if !known {
+		route := ""
+		if verb == "Get" || verb == "Delete" {
+			for _, parent := range parents {
+				route += strings.TrimSuffix(parent, "_id") + "/{" + parent + "}/"
+			}
+		}
+		collection = manifest.HTTPRoute(route + resourceKey(resource))
+	}
+
+	switch verb {
+	case "Create":
+		return HTTPRule{Method: "post", Path: collection, Body: "*"}
+	case "List":
+		return HTTPRule{Method: "get", Path: collection}
+	case "Get", "Delete":
+		rule := HTTPRule{Method: strings.ToLower(verb), Path: collection}
+		if own != "" {
+			rule.Path += "/{" + own + "}"
+		}
+		return rule
+	case "Update":
+		rule := HTTPRule{Method: "patch", Path: collection, Body: "*"}
+		if own == "" && request != nil {
+			own = nestedIDField(set, request, resource)
+		}
+		if own != "" {
+			rule.Path += "/{" + own + "}"
+		}
+		return rule
+	}
+	if !known {
+		collection = manifest.HTTPRoute(module)
+		if len(collections) == 1 {
+			for _, only := range collections {
+				collection = only
+			}
+		}
+	}
+	return HTTPRule{Method: "post", Path: collection + "/" + camelToSnake(name), Body: "*"}
+}
+
+// splitRPCName splits an rpc name into its verb and resource, the verb is
+// empty when the name does not start with a known one
+func splitRPCName(name string) (verb, resource string) {
+	for prefix, v := range httpVerbs {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok || (rest != "" && !strings.ContainsAny(rest[:1], "ABCDEFGHIJKLMNOPQRSTUVWXYZ")) {
+			continue
+		}
+		if v == "List" {
+			rest = singularize(rest)
+		}
+		return v, rest
+	}
+	return "", ""
+}
+
+// resourceKey returns the route segment of a resource
+func resourceKey(resource string) string {
+	return camelToSnake(resource)
+}
+
+func isIDField(field *ProtoField) bool {
+	if field.Repeated || field.KeyType != "" || !isScalarProtoType(field.Type) || field.Type == "bytes" {
+		return false
+	}
+	return field.Name == "id" || strings.HasSuffix(field.Name, "_id")
+}
+
+// ownIDField picks the id of the resource itself among the request ids:
+// id or <resource>_id, otherwise the last one
+func ownIDField(ids []string, resource string) string {
+	for _, id := range ids {
+		if id == "id" || id == resourceKey(resource)+"_id" {
+			return id
+		}
+	}
+	if len(ids) > 0 {
+		return ids[len(ids)-1]
+	}
+	return ""
+}
+
+// nestedIDField returns the id inside the resource message of an update
+// request, such as course.course_id. The message field named after the
+// resource wins, otherwise the only message field carrying an id.
+func nestedIDField(set *ProtoSet, request *ProtoMessage, resource string) string {
+	var candidates []string
+	for _, field := range request.Fields {
+		message := set.Messages[field.Type]
+		if message == nil || field.Repeated {
+			continue
+		}
+		var ids []string
+		for _, nested := range message.Fields {
+			if isIDField(nested) {
+				ids = append(ids, nested.Name)
+			}
+		}
+		own := ownIDField(ids, resource)
+		if own == "" {
+			continue
+		}
+		if message.Name == resource || field.Name == resourceKey(resource) {
+			return field.Name + "." + own
+		}
+		candidates = append(candidates, field.Name+"."+own)
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	return ""
+}
+
+// singularize undoes pluralize
+func singularize(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(lower, "ss"), strings.HasSuffix(lower, "us"), strings.HasSuffix(lower, "is"):
+		return name
+	case strings.HasSuffix(lower, "s"):
+		return name[:len(name)-1]
+	}
+	return name
+}
+
+// writeHTTPBindings adds the bindings to the rpcs of a proto file, keeping
+// the indentation of each rpc
+func writeHTTPBindings(filePath string, bindings []inferredBinding) error {
+	content, err := readFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	source := string(content)
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	for _, binding := range bindings {
+		method := binding.Method
+		start, end, ok := serviceBody(source, method.Service.Name)
+		if !ok {
+			return fmt.Errorf("%s: service %s not found", filePath, method.Service.Name)
+		}
+		pattern := regexp.MustCompile(`\brpc\s+` + regexp.QuoteMeta(method.Name) + `\s*\([^)]*\)\s*returns\s*\([^)]*\)\s*([;{])`)
+		match := pattern.FindStringSubmatchIndex(source[start:end])
+		if match == nil {
+			return fmt.Errorf("%s: rpc %s not found in %s", filePath, method.Name, method.Service.Name)
+		}
+		// The rpc indentation, empty when the rpc shares its line
+		indent := source[strings.LastIndex(source[:start+match[0]], "\n")+1 : start+match[0]]
+		if strings.TrimSpace(indent) != "" {
+			indent = ""
+		}
+		option := httpOptionSource(binding.Rule, indent)
+		at := start + match[2]
+
+		if source[at] == ';' {
+			open := "{\n"
+			if !strings.ContainsAny(source[at-1:at], " \t") {
+				open = " {\n"
+			}
+			edits = append(edits, edit{at, at + 1, open + option + indent + "}"})
+			continue
+		}
+		closing := matchingBrace(source, at)
+		if closing < 0 {
+			return fmt.Errorf("%s: rpc %s is not closed", filePath, method.Name)
+		}
+		if strings.TrimSpace(source[at+1:closing]) == "" {
+			edits = append(edits, edit{at, closing + 1, "{\n" + option + indent + "}"})
+		} else {
+			edits = append(edits, edit{at + 1, at + 1, "\n" + strings.TrimSuffix(option, "\n")})
+		}
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		source = source[:e.start] + e.text + source[e.end:]
+	}
+	source = addProtoImport(source, "google/api/annotations.proto")
+	return writeFile(filePath, source)
+}
+
+// serviceBody returns the offsets of the braces around a service body
+func serviceBody(source, serviceName string) (start, end int, ok bool) {
+	header := regexp.MustCompile(`service\s+` + regexp.QuoteMeta(serviceName) + `\s*\{`).FindStringIndex(source)
+	if header == nil {
+		return 0, 0, false
+	}
+	end = matchingBrace(source, header[1]-1)
+	return header[1] - 1, end, end >= 0
+}
+
+// httpOptionSource renders a google.api.http option one level below the
+// rpc indentation
+func httpOptionSource(rule HTTPRule, indent string) string {
+	unit := indent
+	if unit == "" {
+		unit = "  "
+	}
+	inner := indent + unit
+	var b strings.Builder
+	fmt.Fprintf(&b, "%soption (google.api.http) = {\n", inner)
+	fmt.Fprintf(&b, "%s%s%s: %q;\n", inner, unit, rule.Method, rule.Path)
+	if rule.Body != "" {
+		fmt.Fprintf(&b, "%s%sbody: %q;\n", inner, unit, rule.Body)
+	}
+	fmt.Fprintf(&b, "%s};\n", inner)
+	return b.String()
+}
+
+func init() {
+	protoHTTPCmd.AddCommand(protoHTTPInferCmd)
+	protoCmd.AddCommand(protoHTTPCmd)
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testHTTPMessages = `
+message User { string user_id = 1; string name = 2; }
+message Empty {}
+message UserIdRequest { string user_id = 1; }
+message IdRequest { string id = 1; }
+message MemberRequest { string group_id = 1; string user_id = 2; }
+message UpdateUserRequest { User user = 1; }
+message ListUsersRequest { int32 page_size = 1; }
+`
+
+func TestInferHTTPRule(t *testing.T) {
+	set := testProtoSet(t, map[string]string{
+		"users/users.proto": "syntax = \"proto3\";\npackage app.users;\n" + testHTTPMessages,
+	})
+	tests := []struct {
+		name        string
+		request     string
+		collections map[string]string
+		want        HTTPRule
+	}{
+		{name: "CreateUser", request: "Empty", want: HTTPRule{Method: "post", Path: "/app/v1/user", Body: "*"}},
+		{name: "RegisterUser", request: "Empty", want: HTTPRule{Method: "post", Path: "/app/v1/user", Body: "*"}},
+		{name: "GetUser", request: "UserIdRequest", want: HTTPRule{Method: "get", Path: "/app/v1/user/{user_id}"}},
+		{name: "FetchUser", request: "IdRequest", want: HTTPRule{Method: "get", Path: "/app/v1/user/{id}"}},
+		{name: "DeleteUser", request: "MemberRequest", want: HTTPRule{Method: "delete", Path: "/app/v1/group/{group_id}/user/{user_id}"}},
+		{name: "ListUsers", request: "ListUsersRequest", want: HTTPRule{Method: "get", Path: "/app/v1/user"}},
+		{name: "List", request: "ListUsersRequest", want: HTTPRule{Method: "get", Path: "/app/v1/users"}},
+		{name: "UpdateUser", request: "UpdateUserRequest", want: HTTPRule{Method: "patch", Path: "/app/v1/user/{user.user_id}", Body: "*"}},
+		{name: "UpdateUser", request: "UserIdRequest", want: HTTPRule{Method: "patch", Path: "/app/v1/user/{user_id}", Body: "*"}},
+		{
+			name:        "GetUser",
+			request:     "UserIdRequest",
+			collections: map[string]string{"user": "/v1/members"},
+			want:        HTTPRule{Method: "get", Path: "/v1/members/{user_id}"},
+		},
+		{name: "Ping", request: "Empty", want: HTTPRule{Method: "post", Path: "/app/v1/users/ping", Body: "*"}},
+		{name: "Getaway", request: "Empty", want: HTTPRule{Method: "post", Path: "/app/v1/users/getaway", Body: "*"}},
+		{
+			name:        "ResetPassword",
+			request:     "UserIdRequest",
+			collections: map[string]string{"user": "/v1/members"},
+			want:        HTTPRule{Method: "post", Path: "/v1/members/reset_password", Body: "*"},
+		},
+		{
+			name:        "ResetPassword",
+			request:     "UserIdRequest",
+			collections: map[string]string{"user": "/v1/members", "group": "/v1/groups"},
+			want:        HTTPRule{Method: "post", Path: "/app/v1/users/reset_password", Body: "*"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"("+tt.request+")", func(t *testing.T) {
+			manifest := defaultManifest("example.com/app")
+			request := set.Messages["app.users."+tt.request]
+			if request == nil {
+				t.Fatalf("message %s not found", tt.request)
+			}
+			got := inferHTTPRule(manifest, set, request, tt.name, "users", tt.collections)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inferHTTPRule() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferServiceBindings(t *testing.T) {
+	tests := []struct {
+		name string
+		rpcs string
+		want map[string]HTTPRule
+	}{
+		{
+			name: "custom rpc next to inferred crud rpcs",
+			rpcs: `
+  rpc CreateUser(Empty) returns (User);
+  rpc GetUser(UserIdRequest) returns (User);
+  rpc Ping(Empty) returns (Empty);`,
+			want: map[string]HTTPRule{
+				"CreateUser": {Method: "post", Path: "/app/v1/user", Body: "*"},
+				"GetUser":    {Method: "get", Path: "/app/v1/user/{user_id}"},
+				"Ping":       {Method: "post", Path: "/app/v1/user/ping", Body: "*"},
+			},
+		},
+		{
+			name: "custom rpc next to bound crud rpcs",
+			rpcs: `
+  rpc GetUser(UserIdRequest) returns (User) {
+    option (google.api.http) = { get: "/v1/members/{user_id}" };
+  }
+  rpc CreateUser(Empty) returns (User);
+  rpc Ping(Empty) returns (Empty);
+  rpc Upload(stream Empty) returns (Empty);`,
+			want: map[string]HTTPRule{
+				"CreateUser": {Method: "post", Path: "/v1/members", Body: "*"},
+				"Ping":       {Method: "post", Path: "/v1/members/ping", Body: "*"},
+			},
+		},
+		{
+			name: "custom rpc of several resources",
+			rpcs: `
+  rpc CreateUser(Empty) returns (User);
+  rpc CreateGroup(Empty) returns (Empty);
+  rpc Ping(Empty) returns (Empty);`,
+			want: map[string]HTTPRule{
+				"CreateUser":  {Method: "post", Path: "/app/v1/user", Body: "*"},
+				"CreateGroup": {Method: "post", Path: "/app/v1/group", Body: "*"},
+				"Ping":        {Method: "post", Path: "/app/v1/users/ping", Body: "*"},
+			},
+		},
+		{
+			name: "custom rpc only",
+			rpcs: `
+  rpc Ping(Empty) returns (Empty);`,
+			want: map[string]HTTPRule{
+				"Ping": {Method: "post", Path: "/app/v1/users/ping", Body: "*"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := testProtoSet(t, map[string]string{
+				"users/users.proto": "syntax = \"proto3\";\npackage app.users;\n" + testHTTPMessages +
+					"\nservice UserService {" + tt.rpcs + "\n}\n",
+			})
+			service, err := findModuleService(set, "users", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]HTTPRule{}
+			captureOutput(t, func() {
+				for _, binding := range inferServiceBindings(defaultManifest("example.com/app"), set, service, "users") {
+					got[binding.Method.Name] = binding.Rule
+				}
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inferServiceBindings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
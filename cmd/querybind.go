@@ -0,0 +1,368 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+)
+
+// queryAnnotation matches the rpc comment line binding an rpc to a query
+var queryAnnotation = regexp.MustCompile(`(?m)^\s*grpcframe:query\s+(\w+)\s*$`)
+
+// bindQueries binds the rpcs of a module to the queries of the sqlc
+// Querier. An rpc is bound to the query named by a grpcframe:query comment,
+// or else to the query of the same name.
+func bindQueries(config *ModuleConfig, set *ProtoSet) error {
+	models, err := bindModel(config, set)
+	if err != nil || models == nil || !config.Store {
+		return err
+	}
+	declared, err := declaredQueries(config.Manifest.Layout.Queries)
+	if err != nil {
+		return err
+	}
+	return bindQueryMethods(config, set, models, declared)
+}
+
+// bindQueryMethods binds the unary rpcs without a query yet, copies the
+// bound methods into the services and writes the errs package the bound
+// handlers call
+func bindQueryMethods(config *ModuleConfig, set *ProtoSet, models *repoModels, declared map[string]string) error {
+	for i := range config.ServiceMethods {
+		method := &config.ServiceMethods[i]
+		if method.Query != nil || method.IsStreaming() {
+			continue
+		}
+		name := method.RpcName
+		if match := queryAnnotation.FindStringSubmatch(method.Comment); match != nil {
+			name = match[1]
+			if models.Queries[name] == nil {
+				pkg.WarningLog("Query", name, "of", method.RpcName, "is not in the Querier interface, run sqlc generate")
+				continue
+			}
+		}
+		if query := models.Queries[name]; query != nil {
+			method.Query = models.boundQuery(config, set, method, query, declared[name])
+		}
+	}
+
+	// The services hold copies of their methods
+	bound := false
+	for _, method := range config.ServiceMethods {
+		bound = bound || method.Query != nil
+	}
+	for _, service := range config.Services {
+		for i, method := range service.Methods {
+			for _, candidate := range config.ServiceMethods {
+				if candidate.ServiceStruct == method.ServiceStruct && candidate.Name == method.Name {
+					service.Methods[i] = candidate
+				}
+			}
+		}
+	}
+	if !bound {
+		return nil
+	}
+	return writeErrsPackage(config.Manifest, config.State)
+}
+
+// queryKind returns the sqlc command of a query, read from its annotation
+// or else from its results
+func queryKind(query *querierMethod, declared string) string {
+	if declared != "" {
+		return declared
+	}
+	switch {
+	case len(query.Results) == 0:
+		return "exec"
+	case strings.HasPrefix(query.Results[0], "[]") && query.Results[0] != "[]byte":
+		return "many"
+	}
+	return "one"
+}
+
+// boundQuery builds the handler body of an rpc calling a query: the query
+// arguments are read from the request fields of the same name, and the
+// response is filled from the row. Arguments without a request field are
+// left as TODO comments. Returns nil for queries the handlers cannot call.
+func (r *repoModels) boundQuery(config *ModuleConfig, set *ProtoSet, method *ServiceMethod, query *querierMethod, declared string) *QueryCall {
+	request, response := set.Messages[method.Descriptor.InputType], set.Messages[method.Descriptor.OutputType]
+	if request == nil || response == nil {
+		return nil
+	}
+	kind := queryKind(query, declared)
+	switch kind {
+	case "one", "many":
+		if len(query.Results) != 1 {
+			return nil
+		}
+	case "exec", "execrows", "execresult":
+	default:
+		return nil
+	}
+
+	call := &QueryCall{
+		Name:   query.Name,
+		Kind:   kind,
+		Assign: ":=",
+	}
+	imports := []GoImport{{Path: config.Manifest.ImportPath(config.Manifest.Layout.Errs)}}
+	errDeclared := false
+
+	// argument returns the code setting dst from the request field of a
+	// query argument, ok is false when there is no field to read it from
+	argument := func(dst string, param repoField) (expr, stmt string, ok bool) {
+		field, source := requestField(set, request, param.Name)
+		if field == nil {
+			return "", "", false
+		}
+		conversion := r.conversion(param.Type, protoFieldType(field))
+		if conversion == nil || len(conversion.Helpers) > 0 {
+			return "", "", false
+		}
+		imports = append(imports, r.imports(conversion)...)
+		onError := fmt.Sprintf("return nil, status.Errorf(codes.InvalidArgument, \"invalid %s: %%v\", err)", field.Name)
+		if conversion.Status {
+			onError = "return nil, err"
+		}
+		if conversion.Fallible && conversion.Wrap == "" {
+			errDeclared = true
+		}
+		expr, stmt = conversion.toModelCode(dst, source, onError)
+		return expr, stmt, true
+	}
+
+	var stmts []string
+	if params := r.paramsStruct(query); params != nil {
+		var fields []string
+		for _, field := range params.Fields {
+			expr, stmt, ok := argument("arg."+field.Name, field)
+			switch {
+			case !ok:
+				fields = append(fields, fmt.Sprintf("// TODO: %s (%s) has no field in %s, set it by hand", field.Name, field.Type, request.Name))
+				pkg.WarningLog("Argument", field.Name, "of", query.Name, "has no field in", request.Name+", complete the TODO in", filepath.Join(config.AppPath, method.FileName))
+			case expr != "":
+				fields = append(fields, field.Name+": "+expr+",")
+			default:
+				stmts = append(stmts, stmt)
+			}
+		}
+		call.Prepare = append(call.Prepare, fmt.Sprintf("arg := %s{\n%s\n}", query.Params[0].Type, strings.Join(fields, "\n")))
+		call.Args = "arg"
+	} else {
+		var args []string
+		for _, param := range query.Params {
+			expr, stmt, ok := argument(param.Name, param)
+			switch {
+			case !ok:
+				call.Prepare = append(call.Prepare, fmt.Sprintf("var %s %s // TODO: %s has no field in %s, set it by hand", param.Name, param.Type, param.Name, request.Name))
+				pkg.WarningLog("Argument", param.Name, "of", query.Name, "has no field in", request.Name+", complete the TODO in", filepath.Join(config.AppPath, method.FileName))
+				if imp, ok := typeImport(param.Type); ok {
+					imports = append(imports, imp)
+				}
+			case expr != "":
+				call.Prepare = append(call.Prepare, param.Name+" := "+expr)
+			default:
+				call.Prepare = append(call.Prepare, "var "+param.Name+" "+param.Type)
+				stmts = append(stmts, stmt)
+				if imp, ok := typeImport(param.Type); ok {
+					imports = append(imports, imp)
+				}
+			}
+			args = append(args, param.Name)
+		}
+		call.Args = strings.Join(args, ", ")
+	}
+	if errDeclared {
+		call.Prepare = append(call.Prepare, "var err error")
+	}
+	call.Prepare = append(call.Prepare, stmts...)
+
+	switch kind {
+	case "one":
+		var resultImports []GoImport
+		call.Row = "row"
+		call.Resource = r.resource(query.Results[0])
+		call.Result, resultImports = r.responseFromRow(config, response, query.Results[0], "row")
+		imports = append(imports, resultImports...)
+	case "many":
+		var resultImports []GoImport
+		call.Row = "rows"
+		call.Result, resultImports = r.responseFromRows(config, set, response, strings.TrimPrefix(query.Results[0], "[]"))
+		imports = append(imports, resultImports...)
+	case "execrows", "execresult":
+		call.Row = "_"
+	}
+	if call.Row != "" && len(call.Result) == 0 {
+		call.Row = "_"
+	}
+	if errDeclared && (call.Row == "" || call.Row == "_") {
+		call.Assign = "="
+	}
+
+	repo := r.Package
+	if strings.Contains(strings.Join(call.Prepare, "\n")+call.Args, repo+".") {
+		imports = append(imports, GoImport{Alias: repo, Path: config.RepoImportPath()})
+	}
+	for _, imp := range imports {
+		if !containsImport(method.Imports, imp) {
+			method.Imports = append(method.Imports, imp)
+		}
+	}
+	return call
+}
+
+// paramsStruct returns the Params struct sqlc declares for queries with
+// several arguments, or nil
+func (r *repoModels) paramsStruct(query *querierMethod) *repoStruct {
+	if len(query.Params) != 1 {
+		return nil
+	}
+	name, ok := strings.CutPrefix(query.Params[0].Type, r.Package+".")
+	if !ok || !strings.HasSuffix(name, "Params") {
+		return nil
+	}
+	return r.Structs[name]
+}
+
+// resource names the rows of a query returning rowType in errors: the
+// model name, or nothing for other rows
+func (r *repoModels) resource(rowType string) string {
+	name, ok := strings.CutPrefix(rowType, r.Package+".")
+	if !ok || r.Structs[name] == nil || strings.HasSuffix(name, "Row") {
+		return ""
+	}
+	return strings.ToLower(splitWords(name))
+}
+
+// requestField returns the request field a query argument is read from and
+// the expression reading it: a field of the request, or a field of a
+// message held by the request
+func requestField(set *ProtoSet, request *ProtoMessage, name string) (*ProtoField, string) {
+	key := modelFieldKey(name)
+	for _, field := range request.Fields {
+		if modelFieldKey(field.Name) != key || field.KeyType != "" {
+			continue
+		}
+		if field.Optional {
+			return field, "req." + field.GoName()
+		}
+		return field, "req.Get" + field.GoName() + "()"
+	}
+	for _, field := range request.Fields {
+		message := set.Messages[field.Type]
+		if message == nil || field.Repeated || field.KeyType != "" {
+			continue
+		}
+		for _, nested := range message.Fields {
+			if modelFieldKey(nested.Name) == key && nested.KeyType == "" && !nested.Optional {
+				return nested, "req.Get" + field.GoName() + "().Get" + nested.GoName() + "()"
+			}
+		}
+	}
+	return nil, ""
+}
+
+// responseFromRow returns the statements filling resp from a row: the
+// entity through ModelToProto, or the response fields of the same name as
+// the row fields, and the imports they use
+func (r *repoModels) responseFromRow(config *ModuleConfig, response *ProtoMessage, rowType, row string) ([]string, []GoImport) {
+	if config.Model != nil && rowType == config.Model.Type {
+		if field := messageField(response, config.EntityMessage.FullName, false); field != nil {
+			return []string{fmt.Sprintf("resp.%s = ModelToProto(&%s)", field.GoName(), row)}, nil
+		}
+	}
+	if name, ok := strings.CutPrefix(rowType, r.Package+"."); ok && r.Structs[name] != nil {
+		return r.fillMessage(response, r.Structs[name], "resp", row)
+	}
+	// A scalar row fills the only field of the response
+	if len(response.Fields) == 1 && response.Fields[0].KeyType == "" {
+		field := response.Fields[0]
+		if conversion := r.conversion(rowType, protoFieldType(field)); conversion != nil && len(conversion.Helpers) == 0 {
+			expr, stmt := conversion.toProtoCode("resp."+field.GoName(), row)
+			if expr != "" {
+				stmt = fmt.Sprintf("resp.%s = %s", field.GoName(), expr)
+			}
+			return []string{stmt}, r.imports(conversion)
+		}
+	}
+	return nil, nil
+}
+
+// responseFromRows returns the statements appending the rows of a many
+// query to the first repeated message field of the response they convert
+// to, and the imports they use
+func (r *repoModels) responseFromRows(config *ModuleConfig, set *ProtoSet, response *ProtoMessage, rowType string) ([]string, []GoImport) {
+	name, ok := strings.CutPrefix(rowType, r.Package+".")
+	if !ok || r.Structs[name] == nil {
+		return nil, nil
+	}
+	for _, field := range response.Fields {
+		if !field.Repeated || field.KeyType != "" {
+			continue
+		}
+		if config.Model != nil && rowType == config.Model.Type && field.Type == config.EntityMessage.FullName {
+			return []string{fmt.Sprintf("resp.%[1]s = make([]*%[2]s, 0, len(rows))\nfor i := range rows {\nresp.%[1]s = append(resp.%[1]s, ModelToProto(&rows[i]))\n}", field.GoName(), config.Entity.String())}, []GoImport{config.Entity.Import}
+		}
+		message := set.Messages[field.Type]
+		if message == nil {
+			continue
+		}
+		goType, err := set.GoType(message.FullName)
+		if err != nil {
+			continue
+		}
+		stmts, imports := r.fillMessage(message, r.Structs[name], "item", "rows[i]")
+		if stmts == nil {
+			continue
+		}
+		return []string{fmt.Sprintf("resp.%[1]s = make([]*%[2]s, 0, len(rows))\nfor i := range rows {\nitem := &%[2]s{}\n%[3]s\nresp.%[1]s = append(resp.%[1]s, item)\n}", field.GoName(), goType.String(), strings.Join(stmts, "\n"))}, append(imports, goType.Import)
+	}
+	return nil, nil
+}
+
+// fillMessage returns the statements setting the fields of dst from the
+// fields of the same name of src, nil when no field converts, and the
+// imports they use. Conversions calling converter helpers are left out.
+func (r *repoModels) fillMessage(message *ProtoMessage, row *repoStruct, dst, src string) ([]string, []GoImport) {
+	var stmts []string
+	var imports []GoImport
+	for _, field := range message.Fields {
+		if field.KeyType != "" {
+			continue
+		}
+		var rowField *repoField
+		for i := range row.Fields {
+			if modelFieldKey(row.Fields[i].Name) == modelFieldKey(field.Name) {
+				rowField = &row.Fields[i]
+			}
+		}
+		if rowField == nil {
+			continue
+		}
+		conversion := r.conversion(rowField.Type, protoFieldType(field))
+		if conversion == nil || len(conversion.Helpers) > 0 {
+			continue
+		}
+		imports = append(imports, r.imports(conversion)...)
+		target := dst + "." + field.GoName()
+		expr, stmt := conversion.toProtoCode(target, src+"."+rowField.Name)
+		if expr != "" {
+			stmt = target + " = " + expr
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, imports
+}
+
+// typeImport returns the import of the package qualifying a Go type
+func typeImport(goType string) (GoImport, bool) {
+	qualifier, _, ok := strings.Cut(strings.TrimLeft(goType, "[]*"), ".")
+	if !ok || goTypeImports[qualifier] == "" {
+		return GoImport{}, false
+	}
+	return GoImport{Path: goTypeImports[qualifier]}, true
+}
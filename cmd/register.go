@@ -4,15 +4,20 @@ import (
 	"fmt"
 	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
 	"github.com/spf13/cobra"
+	"go/ast"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+var registerPrune bool
+
 var moduleRegisterCmd = &cobra.Command{
 	Use:   "register",
 	Short: "Register all gRPC services in server.go",
-	Long:  "Automatically discovers and registers all gRPC services in the server.go file",
+	Long: "Automatically discovers and registers all gRPC services in the server.go file. " +
+		"Missing imports, constructors and registrations are added in place, so custom code in server.go is kept. " +
+		"Registrations of deleted modules are reported, and removed with --prune",
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := registerServices(); err != nil {
 			pkg.Red.Printf("Failed to register services: %v\n", err)
@@ -22,34 +27,53 @@ var moduleRegisterCmd = &cobra.Command{
 }
 
 type ServiceRegistration struct {
-	ModuleName   string
-	ServiceName  string
-	PbImportPath string
-	PbPackage    string
-	ServiceVar   string
-	RegisterFunc string
+	ModuleName    string
+	ServiceName   string
+	HandlerImport GoImport
+	PbImport      GoImport
+	ServiceVar    string
+	Constructor   string
+	RegisterFunc  string
+	Unimplemented string
+	// Params are the constructor parameters, wired to the Server fields of
+	// the same type into Args
+	Params []constructorParam
+	Args   string
 }
 
-func discoverModules() ([]ServiceRegistration, error) {
-	var registrations []ServiceRegistration
+// constructorParam is a parameter of a service constructor
+type constructorParam struct {
+	Name string
+	Type goTypeRef
+}
 
-	// Get target module name from go.mod
-	targetModule, err := getTargetModuleName()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get target module name: %w", err)
-	}
+// moduleService is a proto service owned by a module directory
+type moduleService struct {
+	ModuleName string
+	Descriptor *ProtoService
+}
+
+// discoverModuleServices finds the module directories below the rpc layout
+// directory and the proto services each of them implements
+func discoverModuleServices(manifest *Manifest) ([]moduleService, error) {
+	var services []moduleService
 
-	rpcPath := filepath.Join("app", "rpc")
+	rpcPath := manifest.Layout.RPC
 	if _, err := os.Stat(rpcPath); os.IsNotExist(err) {
-		return registrations, nil
+		return services, nil
 	}
 
-	// Read all directories in app/rpc
+	// Read all directories in the rpc directory
 	entries, err := os.ReadDir(rpcPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read rpc directory: %w", err)
 	}
 
+	set, err := parseProtoSet(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proto files: %w", err)
+	}
+
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
@@ -57,25 +81,84 @@ func discoverModules() ([]ServiceRegistration, error) {
 
 		moduleName := entry.Name()
 
-		// Check if service.go exists
-		servicePath := filepath.Join(rpcPath, moduleName, "service.go")
-		if _, err := os.Stat(servicePath); os.IsNotExist(err) {
+		// Check if a service file exists
+		serviceFiles, _ := filepath.Glob(filepath.Join(rpcPath, moduleName, "service*.go"))
+		if len(serviceFiles) == 0 {
 			continue
 		}
 
-		// Extract service information
-		serviceInfo, err := extractServiceInfo(servicePath, moduleName, targetModule)
-		if err != nil {
-			pkg.Red.Printf("Warning: failed to extract service info for %s: %v\n", moduleName, err)
+		descriptors := set.ServicesInDir(moduleName)
+		if len(descriptors) == 0 {
+			pkg.Red.Printf("Warning: no proto services found for %s in %s\n", moduleName, filepath.Join(manifest.Layout.Proto, moduleName))
 			continue
 		}
+		for _, descriptor := range descriptors {
+			services = append(services, moduleService{ModuleName: moduleName, Descriptor: descriptor})
+		}
+	}
 
-		registrations = append(registrations, serviceInfo)
+	return services, nil
+}
+
+func discoverModules(manifest *Manifest) ([]ServiceRegistration, error) {
+	services, err := discoverModuleServices(manifest)
+	if err != nil {
+		return nil, err
 	}
 
+	registrations := make([]ServiceRegistration, 0, len(services))
+	for _, service := range services {
+		registration := newServiceRegistration(manifest, service)
+		if registration.Params, err = constructorParams(manifest, registration); err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, registration)
+	}
 	return registrations, nil
 }
 
+// constructorParams reads the parameters of a service constructor from the
+// handler package of its module
+func constructorParams(manifest *Manifest, reg ServiceRegistration) ([]constructorParam, error) {
+	dir := filepath.Join(manifest.Layout.RPC, reg.ModuleName)
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	_, constructor := splitQualified(reg.Constructor)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := readFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		g, err := parseGoSource(file, src)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range g.file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Name.Name != constructor {
+				continue
+			}
+			var params []constructorParam
+			for _, field := range fn.Type.Params.List {
+				ref, ok := g.typeRef(field.Type, reg.HandlerImport.Path)
+				if !ok {
+					return nil, fmt.Errorf("%s: unsupported parameter type in %s", file, constructor)
+				}
+				for _, name := range field.Names {
+					params = append(params, constructorParam{Name: name.Name, Type: ref})
+				}
+			}
+			return params, nil
+		}
+	}
+	return nil, fmt.Errorf("constructor %s not found in %s", constructor, dir)
+}
+
 func getTargetModuleName() (string, error) {
 	goModPath := "go.mod"
 	content, err := os.ReadFile(goModPath)
@@ -93,24 +176,24 @@ func getTargetModuleName() (string, error) {
 	return "", fmt.Errorf("module name not found in go.mod")
 }
 
-func extractServiceInfo(servicePath, moduleName, targetModule string) (ServiceRegistration, error) {
-	_, err := os.ReadFile(servicePath)
-	if err != nil {
-		return ServiceRegistration{}, fmt.Errorf("failed to read service file: %w", err)
+func newServiceRegistration(manifest *Manifest, service moduleService) ServiceRegistration {
+	pbImport := service.Descriptor.File.GoImport
+	structName := serviceStructName(service.Descriptor.Name)
+	handlerImport := GoImport{
+		Alias: sanitizeGoPackageName(strings.ToLower(service.ModuleName)) + "sv",
+		Path:  manifest.ImportPath(filepath.Join(manifest.Layout.RPC, service.ModuleName)),
 	}
 
-	packageName := strings.ToLower(moduleName)
-	serviceName := toPascalCase(moduleName)
-	pbPackage := fmt.Sprintf("%spb", packageName)
-
 	return ServiceRegistration{
-		ModuleName:   moduleName,
-		ServiceName:  serviceName,
-		PbImportPath: fmt.Sprintf("%s/protogen/%s", targetModule, packageName),
-		PbPackage:    pbPackage,
-		ServiceVar:   fmt.Sprintf("%sService", strings.ToLower(serviceName)),
-		RegisterFunc: fmt.Sprintf("%s.Register%sServiceServer", pbPackage, serviceName),
-	}, nil
+		ModuleName:    service.ModuleName,
+		ServiceName:   structName,
+		HandlerImport: handlerImport,
+		PbImport:      pbImport,
+		ServiceVar:    strings.ToLower(structName[:1]) + structName[1:],
+		Constructor:   fmt.Sprintf("%s.New%s", handlerImport.Alias, structName),
+		RegisterFunc:  fmt.Sprintf("%s.Register%sServer", pbImport.Alias, service.Descriptor.GoName()),
+		Unimplemented: fmt.Sprintf("%s.Unimplemented%sServer", pbImport.Alias, service.Descriptor.GoName()),
+	}
 }
 
 //func generateServerContent(registrations []ServiceRegistration) (string, error) {
@@ -201,6 +284,7 @@ func extractServiceInfo(servicePath, moduleName, targetModule string) (ServiceRe
 //}
 
 func init() {
+	moduleRegisterCmd.Flags().BoolVar(&registerPrune, "prune", false, "Remove registrations of modules that no longer exist")
 	moduleCmd.AddCommand(moduleAddCmd)
 	moduleCmd.AddCommand(moduleRegisterCmd)
 	rootCmd.AddCommand(moduleCmd)
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+// removeProto also deletes the proto sources and generated code of a removed module
+var removeProto bool
+
+var moduleRemoveCmd = &cobra.Command{
+	Use:   "remove [module-name]",
+	Short: "Remove a module and unregister its services",
+	Long: "Deletes the handler package of a module and removes its registrations from server.go and gateway.go. " +
+		"The proto sources and the generated code are kept unless --proto is set",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := removeModule(args[0]); err != nil {
+			pkg.Red.Printf("Failed to remove module: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func removeModule(moduleName string) error {
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	appPath := filepath.Join(manifest.Layout.RPC, moduleName)
+	if _, err := os.Stat(appPath); os.IsNotExist(err) {
+		return fmt.Errorf("module %s not found at %s", moduleName, appPath)
+	}
+	state, err := loadGeneratedState()
+	if err != nil {
+		return err
+	}
+
+	snapshots := []string{manifest.Layout.RPC, manifest.Layout.Gateway}
+	return withRollback(snapshots, func() error {
+		if err := unregisterModule(manifest, moduleName); err != nil {
+			return err
+		}
+
+		pkg.InfoLog("Removing", appPath)
+		if err := removeTree(appPath); err != nil {
+			return err
+		}
+		state.ForgetDir(appPath)
+		delete(state.Modules, moduleName)
+
+		if removeProto {
+			for _, dir := range moduleProtoDirs(manifest, moduleName) {
+				if _, err := os.Stat(dir); os.IsNotExist(err) {
+					continue
+				}
+				pkg.InfoLog("Removing", dir)
+				if err := removeTree(dir); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := state.Save(); err != nil {
+			return fmt.Errorf("failed to save generator state: %w", err)
+		}
+		successBox(fmt.Sprintf("Module '%s' removed", moduleName))
+		return nil
+	})
+}
+
+// moduleProtoDirs returns the proto sources of a module and the directories
+// the protogen plugins generate it into
+func moduleProtoDirs(manifest *Manifest, moduleName string) []string {
+	dirs := []string{filepath.Join(manifest.Layout.Proto, moduleName), filepath.Join(manifest.Layout.Protogen, moduleName)}
+	for _, plugin := range manifest.Protogen.Plugins {
+		dir := filepath.Join(plugin.Out, moduleName)
+		if !containsPath(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// unregisterModule removes the registrations of a module from server.go and
+// regenerates gateway.go without its handlers
+func unregisterModule(manifest *Manifest, moduleName string) error {
+	pbPath := manifest.ImportPath(filepath.Join(manifest.Layout.Protogen, moduleName))
+	ownedByModule := func(importPath string) bool { return isWithinImportPath(importPath, pbPath) }
+
+	serverPath := filepath.Join(manifest.Layout.RPC, "server.go")
+	src, err := readFile(serverPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to read server file: %w", err)
+	default:
+		// Without expected registrations every project service counts as
+		// stale, ownedByModule picks the ones to remove
+		updated, result, err := updateServerFile(serverPath, src, manifest, nil, ownedByModule)
+		if err != nil {
+			return err
+		}
+		for _, name := range result.Pruned {
+			logChange("Unregistered", "Would unregister", name)
+		}
+		if string(updated) != string(src) {
+			if err := writeFile(serverPath, string(updated)); err != nil {
+				return fmt.Errorf("failed to write server file: %w", err)
+			}
+		}
+	}
+
+	gatewayPath := filepath.Join(manifest.Layout.Gateway, "gateway.go")
+	if !manifest.Features.Gateway || !fileExists(gatewayPath) {
+		return nil
+	}
+	services, err := discoverModuleServices(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to discover modules: %w", err)
+	}
+	var remaining []moduleService
+	for _, service := range services {
+		if service.ModuleName != moduleName {
+			remaining = append(remaining, service)
+		}
+	}
+	content, err := generateGatewayContent(manifest, gatewayRegistrations(remaining))
+	if err != nil {
+		return fmt.Errorf("failed to generate gateway content: %w", err)
+	}
+	if err := writeFile(gatewayPath, content); err != nil {
+		return fmt.Errorf("failed to write gateway file: %w", err)
+	}
+	return nil
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if filepath.Clean(p) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	moduleRemoveCmd.Flags().BoolVar(&removeProto, "proto", false, "Also delete the proto sources and the generated code of the module")
+	moduleCmd.AddCommand(moduleRemoveCmd)
+}
@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+var moduleRenameCmd = &cobra.Command{
+	Use:   "rename [old-name] [new-name]",
+	Short: "Rename a module",
+	Long: "Moves the proto and handler directories of a module, rewrites the proto package, go_package and imports, " +
+		"fixes the Go imports across the project and regenerates the protobuf code. Only paths and packages change: " +
+		"the proto service and message names, the service struct and its constructor keep their names, and Go files " +
+		"importing the handler package keep the old package name as import alias, so clients of the api are not broken",
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := renameModule(args[0], args[1]); err != nil {
+			pkg.Red.Printf("Failed to rename module: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var (
+	moduleNamePattern   = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	protoPackagePattern = regexp.MustCompile(`(?m)^(\s*package\s+)([\w.]+)(\s*;)`)
+	goPackagePattern    = regexp.MustCompile(`(option\s+go_package\s*=\s*")([^"]*)(")`)
+	protoImportPattern  = regexp.MustCompile(`(import\s+(?:public\s+|weak\s+)?")([^"]+)(")`)
+)
+
+// moduleRename holds the old and new paths of a renamed module
+type moduleRename struct {
+	Manifest *Manifest
+	State    *GeneratedState
+	Old, New string
+	// ImportMoves maps the old Go import path of a package to the new one
+	ImportMoves map[string]string
+}
+
+func renameModule(oldName, newName string) error {
+	if !moduleNamePattern.MatchString(newName) {
+		return fmt.Errorf("invalid module name %q (use lower case letters, digits and underscores)", newName)
+	}
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	layout := manifest.Layout
+	for _, dir := range []string{layout.Proto, layout.RPC} {
+		if _, err := os.Stat(filepath.Join(dir, oldName)); os.IsNotExist(err) {
+			return fmt.Errorf("module %s not found at %s", oldName, filepath.Join(dir, oldName))
+		}
+		if _, err := os.Stat(filepath.Join(dir, newName)); err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(dir, newName))
+		}
+	}
+	state, err := loadGeneratedState()
+	if err != nil {
+		return err
+	}
+
+	r := &moduleRename{
+		Manifest: manifest,
+		State:    state,
+		Old:      oldName,
+		New:      newName,
+		ImportMoves: map[string]string{
+			manifest.ImportPath(filepath.Join(layout.Protogen, oldName)): manifest.ImportPath(filepath.Join(layout.Protogen, newName)),
+			manifest.ImportPath(filepath.Join(layout.RPC, oldName)):      manifest.ImportPath(filepath.Join(layout.RPC, newName)),
+		},
+	}
+
+	snapshots := append(protogenOutputs(manifest), layout.Proto, layout.RPC, layout.Gateway)
+	return withRollback(snapshots, func() error {
+		pkg.InfoLog("Moving proto sources...")
+		if err := r.moveProtoSources(); err != nil {
+			return err
+		}
+		pkg.InfoLog("Moving handlers...")
+		if err := r.moveHandlers(); err != nil {
+			return err
+		}
+		pkg.InfoLog("Fixing Go imports...")
+		if err := r.fixGoImports(); err != nil {
+			return err
+		}
+
+		for _, dir := range moduleProtoDirs(manifest, oldName)[1:] {
+			if _, err := os.Stat(dir); err == nil {
+				if err := removeTree(dir); err != nil {
+					return err
+				}
+			}
+		}
+		if module, ok := state.Modules[oldName]; ok {
+			delete(state.Modules, oldName)
+			state.Modules[newName] = module
+		}
+
+		pkg.InfoLog("Regenerating protobuf files...")
+		if _, err := generateProto(manifest, true); err != nil {
+			return fmt.Errorf("protogen failed: %w", err)
+		}
+
+		if err := state.Save(); err != nil {
+			return fmt.Errorf("failed to save generator state: %w", err)
+		}
+		successBox(fmt.Sprintf("Module '%s' renamed to '%s'", oldName, newName))
+		return nil
+	})
+}
+
+// moveProtoSources moves proto/<old> to proto/<new>, renaming the package
+// and go_package of the moved files and the imports and qualified type
+// names in every project proto file
+func (r *moduleRename) moveProtoSources() error {
+	protoDir := r.Manifest.Layout.Proto
+	files, err := collectProtoFiles(protoDir)
+	if err != nil {
+		return err
+	}
+
+	// Proto imports are relative to the proto directory
+	renamedFiles := map[string]string{}
+	renamedPackages := map[string]string{}
+	for _, file := range files {
+		rest, ok := strings.CutPrefix(file, r.Old+"/")
+		if !ok {
+			continue
+		}
+		dir, base := path.Split(rest)
+		renamedFiles[file] = r.New + "/" + dir + renameToken(base, r.Old, r.New, "_.")
+
+		content, err := os.ReadFile(filepath.Join(protoDir, filepath.FromSlash(file)))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if match := protoPackagePattern.FindSubmatch(content); match != nil {
+			oldPackage := string(match[2])
+			if newPackage := renameToken(oldPackage, r.Old, r.New, "."); newPackage != oldPackage {
+				renamedPackages[oldPackage] = newPackage
+			}
+		}
+	}
+
+	var qualified []*regexp.Regexp
+	var qualifiedNew []string
+	for oldPackage, newPackage := range renamedPackages {
+		qualified = append(qualified, regexp.MustCompile(`(^|[^\w.])(\.?)`+regexp.QuoteMeta(oldPackage)+`\.`))
+		qualifiedNew = append(qualifiedNew, "${1}${2}"+newPackage+".")
+	}
+
+	for _, file := range files {
+		if r.Manifest.IsVendoredProto(file) {
+			continue
+		}
+		source := filepath.Join(protoDir, filepath.FromSlash(file))
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		updated := protoImportPattern.ReplaceAllStringFunc(string(content), func(match string) string {
+			parts := protoImportPattern.FindStringSubmatch(match)
+			if renamed, ok := renamedFiles[parts[2]]; ok {
+				return parts[1] + renamed + parts[3]
+			}
+			return match
+		})
+		for i, pattern := range qualified {
+			updated = pattern.ReplaceAllString(updated, qualifiedNew[i])
+		}
+
+		renamed, moved := renamedFiles[file]
+		if !moved {
+			if updated != string(content) {
+				if err := writeFile(source, updated); err != nil {
+					return fmt.Errorf("failed to write %s: %w", source, err)
+				}
+			}
+			continue
+		}
+
+		updated = protoPackagePattern.ReplaceAllStringFunc(updated, func(match string) string {
+			parts := protoPackagePattern.FindStringSubmatch(match)
+			return parts[1] + renameToken(parts[2], r.Old, r.New, ".") + parts[3]
+		})
+		updated = goPackagePattern.ReplaceAllStringFunc(updated, func(match string) string {
+			parts := goPackagePattern.FindStringSubmatch(match)
+			return parts[1] + r.renameGoPackage(parts[2]) + parts[3]
+		})
+
+		target := filepath.Join(protoDir, filepath.FromSlash(renamed))
+		if err := makeDir(filepath.Dir(target)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+		if err := writeFile(target, updated); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+	}
+	return removeTree(filepath.Join(protoDir, r.Old))
+}
+
+// renameGoPackage renames the module in a go_package option value such as
+// example.com/app/protogen/course;coursepb
+func (r *moduleRename) renameGoPackage(value string) string {
+	importPath, name, hasName := strings.Cut(value, ";")
+	if rest, ok := strings.CutPrefix(importPath, r.Manifest.Module+"/"); ok {
+		importPath = r.Manifest.Module + "/" + renameToken(rest, r.Old, r.New, "/")
+	} else if path.Base(importPath) == r.Old {
+		importPath = path.Join(path.Dir(importPath), r.New)
+	}
+	if !hasName {
+		return importPath
+	}
+	// userpb and user_v1 are names of user, userspb is not
+	if rest, ok := strings.CutPrefix(name, r.Old); ok && (rest == "" || rest == "pb" || strings.HasPrefix(rest, "_")) {
+		name = r.New + rest
+	}
+	return importPath + ";" + name
+}
+
+// moveHandlers moves the handler package to its new directory and renames
+// its package clause
+func (r *moduleRename) moveHandlers() error {
+	oldDir := filepath.Join(r.Manifest.Layout.RPC, r.Old)
+	newDir := filepath.Join(r.Manifest.Layout.RPC, r.New)
+
+	err := filepath.WalkDir(oldDir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(oldDir, file)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		updated := content
+		if filepath.Ext(file) == ".go" {
+			if updated, err = r.rewriteGoFile(file, content, true); err != nil {
+				return err
+			}
+		}
+
+		target := filepath.Join(newDir, rel)
+		if err := makeDir(filepath.Dir(target)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+		if err := writeFile(target, string(updated)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		r.State.Move(file, target, content, updated)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move %s: %w", oldDir, err)
+	}
+	return removeTree(oldDir)
+}
+
+// fixGoImports rewrites the imports of the renamed packages in every Go
+// file of the project outside of the generated code
+func (r *moduleRename) fixGoImports() error {
+	skip := []string{
+		r.Manifest.Layout.Protogen,
+		filepath.Join(r.Manifest.Layout.RPC, r.Old),
+		filepath.Join(r.Manifest.Layout.RPC, r.New),
+	}
+	return filepath.WalkDir(".", func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if file != "." && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor" || containsPath(skip, file)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(file) != ".go" {
+			return nil
+		}
+
+		content, err := readFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		updated, err := r.rewriteGoFile(file, content, false)
+		if err != nil {
+			return err
+		}
+		if string(updated) == string(content) {
+			return nil
+		}
+		pkg.InfoLog("Updated imports in", file)
+		if err := writeFile(file, string(updated)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		r.State.Move(file, file, content, updated)
+		return nil
+	})
+}
+
+// rewriteGoFile points the imports of the renamed packages at their new
+// paths. Imports without a name keep the old package name as an explicit
+// one, so the references in the file stay valid. The package clause is
+// renamed for files of the moved handler package.
+func (r *moduleRename) rewriteGoFile(filename string, src []byte, moved bool) ([]byte, error) {
+	g, err := parseGoSource(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	var edits []textEdit
+	if moved && g.file.Name.Name == sanitizeGoPackageName(r.Old) {
+		edits = append(edits, textEdit{
+			Start: g.offset(g.file.Name.Pos()),
+			End:   g.offset(g.file.Name.End()),
+			Text:  sanitizeGoPackageName(r.New),
+		})
+	}
+	for _, spec := range g.file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		for oldPath, newPath := range r.ImportMoves {
+			if !isWithinImportPath(importPath, oldPath) {
+				continue
+			}
+			renamed := newPath + strings.TrimPrefix(importPath, oldPath)
+			text := strconv.Quote(renamed)
+			if spec.Name == nil && path.Base(renamed) != path.Base(importPath) {
+				text = path.Base(importPath) + " " + text
+			}
+			edits = append(edits, textEdit{Start: g.offset(spec.Path.Pos()), End: g.offset(spec.Path.End()), Text: text})
+		}
+	}
+	if len(edits) == 0 {
+		return src, nil
+	}
+
+	updated, err := format.Source(applyEdits(src, edits))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update %s: %w", filename, err)
+	}
+	return updated, nil
+}
+
+// renameToken replaces the old name where it appears as a whole token of s,
+// tokens being separated by any of the separator characters
+func renameToken(s, oldName, newName, separators string) string {
+	var b, current strings.Builder
+	flush := func() {
+		if current.String() == oldName {
+			b.WriteString(newName)
+		} else {
+			b.WriteString(current.String())
+		}
+		current.Reset()
+	}
+	for _, c := range s {
+		if strings.ContainsRune(separators, c) {
+			flush()
+			b.WriteRune(c)
+			continue
+		}
+		current.WriteRune(c)
+	}
+	flush()
+	return b.String()
+}
+
+func init() {
+	moduleCmd.AddCommand(moduleRenameCmd)
+}
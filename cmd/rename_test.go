@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenameToken(t *testing.T) {
+	tests := []struct {
+		s, separators string
+		want          string
+	}{
+		{s: "user", separators: "_.", want: "account"},
+		{s: "users", separators: "_.", want: "users"},
+		{s: "superuser.proto", separators: "_.", want: "superuser.proto"},
+		{s: "user_service.proto", separators: "_.", want: "account_service.proto"},
+		{s: "users_user.proto", separators: "_.", want: "users_account.proto"},
+		{s: "app.user.v1", separators: ".", want: "app.account.v1"},
+		{s: "app.users.v1", separators: ".", want: "app.users.v1"},
+		{s: "user_v1", separators: ".", want: "user_v1"},
+		{s: "protogen/user/v1", separators: "/", want: "protogen/account/v1"},
+		{s: "", separators: ".", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := renameToken(tt.s, "user", "account", tt.separators); got != tt.want {
+				t.Errorf("renameToken(%q, %q) = %q, want %q", tt.s, tt.separators, got, tt.want)
+			}
+		})
+	}
+}
+
+// testModuleRename renames the user module of the example.com/app test
+// project to account
+func testModuleRename() *moduleRename {
+	manifest := defaultManifest("example.com/app")
+	return &moduleRename{
+		Manifest: manifest,
+		Old:      "user",
+		New:      "account",
+		ImportMoves: map[string]string{
+			"example.com/app/protogen/user": "example.com/app/protogen/account",
+			"example.com/app/app/rpc/user":  "example.com/app/app/rpc/account",
+		},
+	}
+}
+
+func TestRenameGoPackage(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "example.com/app/protogen/user", want: "example.com/app/protogen/account"},
+		{value: "example.com/app/protogen/user/v1;user_v1", want: "example.com/app/protogen/account/v1;account_v1"},
+		{value: "example.com/app/protogen/user;userpb", want: "example.com/app/protogen/account;accountpb"},
+		{value: "example.com/app/protogen/user;userspb", want: "example.com/app/protogen/account;userspb"},
+		{value: "example.com/app/protogen/users", want: "example.com/app/protogen/users"},
+		{value: "github.com/acme/apis/user", want: "github.com/acme/apis/account"},
+		{value: "github.com/acme/apis/users", want: "github.com/acme/apis/users"},
+	}
+	r := testModuleRename()
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := r.renameGoPackage(tt.value); got != tt.want {
+				t.Errorf("renameGoPackage(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveProtoSources(t *testing.T) {
+	t.Chdir(t.TempDir())
+	files := map[string]string{
+		"proto/user/user.proto": `syntax = "proto3";
+
+package app.user;
+
+option go_package = "example.com/app/protogen/user;userpb";
+
+import "users/users.proto";
+
+message User {
+  app.users.Group group = 1;
+}
+`,
+		"proto/users/users.proto": `syntax = "proto3";
+
+package app.users;
+
+option go_package = "example.com/app/protogen/users";
+
+message Group {}
+`,
+		"proto/order/order.proto": `syntax = "proto3";
+
+package app.order;
+
+import "user/user.proto";
+import public "users/users.proto";
+
+message Order {
+  app.user.User buyer = 1;
+  .app.user.User seller = 2;
+  app.users.Group group = 3;
+  myapp.user.Thing other = 4;
+}
+`,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := testModuleRename().moveProtoSources(); err != nil {
+		t.Fatalf("moveProtoSources() error = %v", err)
+	}
+
+	want := map[string]string{
+		"proto/account/account.proto": `syntax = "proto3";
+
+package app.account;
+
+option go_package = "example.com/app/protogen/account;accountpb";
+
+import "users/users.proto";
+
+message User {
+  app.users.Group group = 1;
+}
+`,
+		"proto/users/users.proto": files["proto/users/users.proto"],
+		"proto/order/order.proto": `syntax = "proto3";
+
+package app.order;
+
+import "account/account.proto";
+import public "users/users.proto";
+
+message Order {
+  app.account.User buyer = 1;
+  .app.account.User seller = 2;
+  app.users.Group group = 3;
+  myapp.user.Thing other = 4;
+}
+`,
+	}
+	for path, content := range want {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s =\n%s\nwant\n%s", path, got, content)
+		}
+	}
+	if _, err := os.Stat("proto/user"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("proto/user was not removed")
+	}
+}
+
+func TestRewriteGoFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		moved bool
+		src   string
+		want  string
+	}{
+		{
+			name: "imports of the renamed packages",
+			src: `package rpc
+
+import (
+	"example.com/app/app/rpc/user"
+	"example.com/app/app/rpc/users"
+	userpb "example.com/app/protogen/user"
+	"example.com/app/protogen/user/v1"
+)
+
+var _ = user.New
+`,
+			want: `package rpc
+
+import (
+	user "example.com/app/app/rpc/account"
+	"example.com/app/app/rpc/users"
+	userpb "example.com/app/protogen/account"
+	"example.com/app/protogen/account/v1"
+)
+
+var _ = user.New
+`,
+		},
+		{
+			name:  "package clause of a moved file",
+			moved: true,
+			src:   "package user\n\nimport userpb \"example.com/app/protogen/user\"\n\nvar _ userpb.User\n",
+			want:  "package account\n\nimport userpb \"example.com/app/protogen/account\"\n\nvar _ userpb.User\n",
+		},
+		{
+			name:  "other package",
+			moved: true,
+			src:   "package users\n\nimport \"example.com/app/app/rpc/users\"\n",
+		},
+	}
+	r := testModuleRename()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.rewriteGoFile("file.go", []byte(tt.src), tt.moved)
+			if err != nil {
+				t.Fatalf("rewriteGoFile() error = %v", err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.src
+			}
+			if string(got) != want {
+				t.Errorf("rewriteGoFile() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+)
+
+// repoModels are the types declared by the sqlc generated package. Type
+// expressions are qualified with Package, the name the generated code
+// imports the package as.
+type repoModels struct {
+	Package string
+	Structs map[string]*repoStruct
+	// Enums are the string types sqlc declares for database enums, and
+	// NullEnums maps their Null wrappers to the wrapped enum
+	Enums     map[string]bool
+	NullEnums map[string]string
+	// ProtoEnums are the full names of the proto enums, which convert to
+	// the enum types through the convertor package
+	ProtoEnums map[string]bool
+	Convertor  GoImport
+	// ConvertorFuncs are the functions the convertor package declares.
+	// Conversions calling the package are only used when they are declared.
+	ConvertorFuncs map[string]bool
+	// Queries are the methods of the Querier interface by name
+	Queries map[string]*querierMethod
+}
+
+// querierMethod is a query of the Querier interface. Params leave out the
+// context and Results the error.
+type querierMethod struct {
+	Name    string
+	Params  []repoField
+	Results []string
+}
+
+// ModelMapping converts the proto entity of a module to its sqlc model
+// and back. Fields without a known conversion are listed in Unmapped and
+// left as TODO comments.
+type ModelMapping struct {
+	Type     string
+	ToProto  []fieldMapping
+	ToModel  []fieldMapping
+	Fallible bool
+	Imports  []GoImport
+	// Helpers is the source of the functions the conversions call
+	Helpers  []string
+	Unmapped []string
+}
+
+// fieldMapping is one field of a conversion: an element of the composite
+// literal, a statement run after it, or a comment for unmapped fields
+type fieldMapping struct {
+	Expr    string
+	Stmt    string
+	Comment string
+}
+
+// repoStruct is a struct type of the generated package
+type repoStruct struct {
+	Name   string
+	Fields []repoField
+}
+
+// repoField is a field of a generated struct with its qualified type
+type repoField struct {
+	Name string
+	Type string
+}
+
+// Field returns the field with the given name, or nil
+func (s *repoStruct) Field(name string) *repoField {
+	for i := range s.Fields {
+		if s.Fields[i].Name == name {
+			return &s.Fields[i]
+		}
+	}
+	return nil
+}
+
+// loadRepoModels parses the Go files of the sqlc output directory
+func loadRepoModels(dir, packageName string) (*repoModels, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	sort.Strings(files)
+
+	models := &repoModels{
+		Package:    packageName,
+		Structs:    map[string]*repoStruct{},
+		Enums:      map[string]bool{},
+		NullEnums:  map[string]string{},
+		ProtoEnums: map[string]bool{},
+		Queries:    map[string]*querierMethod{},
+	}
+	var specs []*ast.TypeSpec
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		content, err := readFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		parsed, err := parser.ParseFile(fset, file, content, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		for _, decl := range parsed.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					specs = append(specs, spec.(*ast.TypeSpec))
+				}
+			}
+		}
+	}
+
+	declared := map[string]bool{}
+	for _, spec := range specs {
+		declared[spec.Name.Name] = true
+	}
+	for _, spec := range specs {
+		name := spec.Name.Name
+		switch typ := spec.Type.(type) {
+		case *ast.Ident:
+			if typ.Name == "string" {
+				models.Enums[name] = true
+			}
+		case *ast.StructType:
+			model := &repoStruct{Name: name}
+			for _, field := range typ.Fields.List {
+				fieldType := models.qualify(field.Type, declared)
+				for _, ident := range field.Names {
+					model.Fields = append(model.Fields, repoField{Name: ident.Name, Type: fieldType})
+				}
+			}
+			models.Structs[name] = model
+		case *ast.InterfaceType:
+			if name == "Querier" {
+				models.addQueries(typ, declared)
+			}
+		}
+	}
+	for name, model := range models.Structs {
+		wrapped := strings.TrimPrefix(name, "Null")
+		if wrapped == name || !models.Enums[wrapped] || len(model.Fields) != 2 {
+			continue
+		}
+		if model.Field(wrapped) != nil && model.Field("Valid") != nil {
+			models.NullEnums[name] = wrapped
+		}
+	}
+	return models, nil
+}
+
+// addQueries reads the methods of the Querier interface
+func (r *repoModels) addQueries(querier *ast.InterfaceType, declared map[string]bool) {
+	for _, method := range querier.Methods.List {
+		fn, ok := method.Type.(*ast.FuncType)
+		if !ok || len(method.Names) == 0 {
+			continue
+		}
+		query := &querierMethod{Name: method.Names[0].Name}
+		for i, param := range fn.Params.List {
+			paramType := r.qualify(param.Type, declared)
+			if i == 0 && paramType == "context.Context" {
+				continue
+			}
+			for _, ident := range param.Names {
+				query.Params = append(query.Params, repoField{Name: ident.Name, Type: paramType})
+			}
+		}
+		if fn.Results != nil {
+			for _, result := range fn.Results.List {
+				if resultType := r.qualify(result.Type, declared); resultType != "error" {
+					query.Results = append(query.Results, resultType)
+				}
+			}
+		}
+		r.Queries[query.Name] = query
+	}
+}
+
+// qualify returns the source of a type expression with the types declared
+// by the generated package qualified by its import name
+func (r *repoModels) qualify(expr ast.Expr, declared map[string]bool) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if declared[t.Name] && types.Universe.Lookup(t.Name) == nil {
+			return r.Package + "." + t.Name
+		}
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + r.qualify(t.X, declared)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + r.qualify(t.Elt, declared)
+		}
+		return "[" + types.ExprString(t.Len) + "]" + r.qualify(t.Elt, declared)
+	case *ast.MapType:
+		return "map[" + r.qualify(t.Key, declared) + "]" + r.qualify(t.Value, declared)
+	}
+	return types.ExprString(expr)
+}
+
+// conversion returns how a Go type of the generated package converts to a
+// proto field type and back, or nil when the generator has no mapping for
+// the pair
+func (r *repoModels) conversion(goType, protoType string) *fieldConversion {
+	name := strings.TrimPrefix(goType, r.Package+".")
+	if name != goType && r.ProtoEnums[protoType] {
+		enum := protoType[strings.LastIndex(protoType, ".")+1:]
+		if wrapped, ok := r.NullEnums[name]; ok && wrapped == enum {
+			name = "Null" + enum
+		} else if !r.Enums[name] || name != enum {
+			return nil
+		}
+		conversion := &fieldConversion{
+			ToProto:  r.Convertor.Alias + "." + name + "ToProto(%[1]s)",
+			ToModel:  r.Convertor.Alias + "." + name + "FromProto(%[1]s)",
+			Fallible: true,
+			Status:   true,
+			Imports:  []string{"convertor"},
+		}
+		if !conversion.declaresCalls(r.ConvertorFuncs) {
+			return nil
+		}
+		return conversion
+	}
+	if name != goType && protoType == "string" {
+		if r.Enums[name] {
+			return &fieldConversion{ToProto: "string(%[1]s)", ToModel: goType + "(%[1]s)"}
+		}
+		if wrapped, ok := r.NullEnums[name]; ok {
+			return &fieldConversion{
+				ToProto: "string(%[1]s." + wrapped + ")",
+				Present: `%[1]s != ""`,
+				ToModel: r.Package + "." + wrapped + "(%[1]s)",
+				Wrap:    fmt.Sprintf("%s{%s: %%[1]s, Valid: true}", goType, wrapped),
+			}
+		}
+	}
+	key := goType + " " + protoType
+	if conversion, ok := convertorConversions[key]; ok && conversion.declaresCalls(r.ConvertorFuncs) {
+		return conversion
+	}
+	return knownConversions[key]
+}
+
+// imports returns the packages a conversion uses
+func (r *repoModels) imports(c *fieldConversion) []GoImport {
+	var imports []GoImport
+	for _, name := range c.Imports {
+		if name == "convertor" {
+			imports = append(imports, r.Convertor)
+		} else {
+			imports = append(imports, GoImport{Path: goTypeImports[name]})
+		}
+	}
+	return imports
+}
+
+// modelFieldKey folds proto and Go field names to a common form, so that
+// course_id matches CourseID
+func modelFieldKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// bindModel loads the sqlc package and maps the entity message of a module
+// to the model of the same name. Without a model the module gets no
+// converter.
+func bindModel(config *ModuleConfig, set *ProtoSet) (*repoModels, error) {
+	if !config.Manifest.Features.SQLC {
+		return nil, nil
+	}
+	models, err := loadRepoModels(config.Manifest.Layout.Repo, config.Manifest.Database.Package)
+	if err != nil {
+		return nil, err
+	}
+	for name := range set.Enums {
+		models.ProtoEnums[name] = true
+	}
+	models.Convertor = GoImport{Alias: "convertor", Path: config.Manifest.ImportPath(config.Manifest.Layout.Convert)}
+	if models.ConvertorFuncs, err = writeConvertorLibrary(config.Manifest, config.State); err != nil {
+		return nil, err
+	}
+	if config.EntityMessage == nil {
+		return models, nil
+	}
+	model := models.Structs[config.Entity.Name]
+	if model == nil {
+		return models, nil
+	}
+	config.Model = models.mapping(model, config.EntityMessage)
+	for _, field := range config.Model.Unmapped {
+		pkg.WarningLog("Field", field, "is not mapped, complete the TODO in", filepath.Join(config.AppPath, "converter.go"))
+	}
+	return models, nil
+}
+
+// mapping builds the field conversions between an entity message and a
+// model. Fields are matched by name, and fields without a counterpart or
+// a known conversion are left as TODO comments and listed in Unmapped.
+func (r *repoModels) mapping(model *repoStruct, entity *ProtoMessage) *ModelMapping {
+	mapping := &ModelMapping{Type: r.Package + "." + model.Name}
+	fields := map[string]*ProtoField{}
+	for _, field := range entity.Fields {
+		fields[modelFieldKey(field.Name)] = field
+	}
+
+	matched := map[*ProtoField]bool{}
+	for _, goField := range model.Fields {
+		field := fields[modelFieldKey(goField.Name)]
+		var conversion *fieldConversion
+		if field != nil {
+			matched[field] = true
+			if field.KeyType == "" {
+				conversion = r.conversion(goField.Type, protoFieldType(field))
+			}
+		}
+		if conversion == nil {
+			var comment string
+			if field == nil {
+				comment = fmt.Sprintf("TODO: %s (%s) has no field in %s, map it by hand", goField.Name, goField.Type, entity.Name)
+			} else {
+				comment = fmt.Sprintf("TODO: %s (%s) has no conversion to %s %s, map it by hand", goField.Name, goField.Type, protoFieldType(field), field.Name)
+			}
+			mapping.ToProto = append(mapping.ToProto, fieldMapping{Comment: comment})
+			mapping.ToModel = append(mapping.ToModel, fieldMapping{Comment: comment})
+			mapping.Unmapped = append(mapping.Unmapped, mapping.Type+"."+goField.Name)
+			continue
+		}
+		for _, imp := range r.imports(conversion) {
+			mapping.addImport(imp)
+		}
+		for _, helper := range conversion.Helpers {
+			mapping.addHelper(helper)
+		}
+
+		protoField := field.GoName()
+		expr, stmt := conversion.toProtoCode("pb."+protoField, "model."+goField.Name)
+		if expr != "" {
+			expr = protoField + ": " + expr
+		}
+		mapping.ToProto = append(mapping.ToProto, fieldMapping{Expr: expr, Stmt: stmt})
+
+		onError := fmt.Sprintf("return nil, fmt.Errorf(\"invalid %s: %%w\", err)", field.Name)
+		if conversion.Status {
+			onError = "return nil, err"
+		}
+		source := "pb.Get" + protoField + "()"
+		if field.Optional {
+			// the getter dereferences optional fields, the conversion
+			// needs the pointer to tell an unset field apart
+			source = "pb." + protoField
+		}
+		expr, stmt = conversion.toModelCode("model."+goField.Name, source, onError)
+		if expr != "" {
+			expr = goField.Name + ": " + expr
+		}
+		mapping.ToModel = append(mapping.ToModel, fieldMapping{Expr: expr, Stmt: stmt})
+		if conversion.Fallible {
+			if !conversion.Status {
+				mapping.addImport(GoImport{Path: "fmt"})
+			}
+			if conversion.Wrap == "" {
+				mapping.Fallible = true
+			}
+		}
+	}
+
+	for _, field := range entity.Fields {
+		if !matched[field] {
+			comment := fmt.Sprintf("TODO: %s has no field in %s, set it by hand", field.Name, mapping.Type)
+			mapping.ToProto = append(mapping.ToProto, fieldMapping{Comment: comment})
+			mapping.Unmapped = append(mapping.Unmapped, entity.FullName+"."+field.Name)
+		}
+	}
+	return mapping
+}
+
+func (m *ModelMapping) addImport(imp GoImport) {
+	if !containsImport(m.Imports, imp) {
+		m.Imports = append(m.Imports, imp)
+	}
+}
+
+func (m *ModelMapping) addHelper(name string) {
+	if source := convertHelpers[name]; !slices.Contains(m.Helpers, source) {
+		m.Helpers = append(m.Helpers, source)
+	}
+}
+
+// protoFieldType returns the type of a field as written in a proto file
+func protoFieldType(field *ProtoField) string {
+	if field.Repeated {
+		return "repeated " + field.Type
+	}
+	if field.Optional {
+		return "optional " + field.Type
+	}
+	return field.Type
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"os"
 
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
 	"github.com/spf13/cobra"
 )
 
@@ -25,6 +26,15 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print a diff of the planned file changes and skip external commands instead of applying them")
+	rootCmd.PersistentFlags().BoolVar(&keepPartial, "keep-partial", false, "Keep the files of a failed command instead of rolling them back")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Apply the changes of a dry run without asking")
+	rootCmd.PersistentPostRun = func(cmd *cobra.Command, args []string) {
+		if err := finishDryRun(); err != nil {
+			pkg.Red.Printf("Failed to apply changes: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rpcAddHTTP    string
+	rpcAddBody    string
+	rpcAddService string
+)
+
+var rpcCmd = &cobra.Command{
+	Use:   "rpc",
+	Short: "Rpc management commands",
+	Long:  "Commands for managing the rpcs of a module service",
+}
+
+var rpcAddCmd = &cobra.Command{
+	Use:   "add [module-name] [rpc-name]",
+	Short: "Add an rpc to a module service",
+	Long: "Inserts the rpc into the service definition of the module, keeping the formatting of the file, " +
+		"writes its request and response messages to rpc_<module>_<verb>.proto, runs protogen and generates " +
+		"the handler stub of the new rpc only. --http takes method:path, for example patch:/lms/v1/course/{course_id}",
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := addRPC(args[0], args[1]); err != nil {
+			pkg.Red.Printf("Failed to add rpc: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// pathVariablePattern matches the {field} variables of an http path template
+var pathVariablePattern = regexp.MustCompile(`\{([\w.]+)(?:=[^}]*)?\}`)
+
+func addRPC(moduleName, rpcName string) error {
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	if !goIdentifierPattern.MatchString(rpcName) {
+		return fmt.Errorf("invalid rpc name %q", rpcName)
+	}
+	rpcName = strings.ToUpper(rpcName[:1]) + rpcName[1:]
+
+	set, err := parseProtoSet(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to parse proto files: %w", err)
+	}
+	service, err := findModuleService(set, moduleName, rpcAddService)
+	if err != nil {
+		return err
+	}
+	for _, method := range service.Methods {
+		if method.Name == rpcName {
+			return fmt.Errorf("%s already has an rpc %s", service.Name, rpcName)
+		}
+	}
+
+	scaffold := newProtoScaffold(manifest, moduleName)
+	scaffold.Package = service.File.Package
+	if service.File.GoPackage != "" {
+		// otherwise the message file keeps the go_package derived from its
+		// directory, like the files of grpcframe proto new
+		scaffold.GoPackage = service.File.GoPackage
+	}
+	scaffold.Service = service.Name
+	scaffold.EntityFile = ""
+	if entity, ok := set.Messages[joinProtoName(service.File.Package, scaffold.Entity)]; ok {
+		scaffold.EntityFile = entity.File.Path
+	}
+
+	rpc := scaffold.rpcByName(rpcName)
+	if rpcAddHTTP != "" {
+		rule, err := parseHTTPFlag(rpcAddHTTP, rpcAddBody)
+		if err != nil {
+			return err
+		}
+		rpc.HTTP = rule
+	} else if rpcAddBody != "" {
+		rpc.HTTP.Body = rpcAddBody
+	}
+	rpc.Request.Fields = withPathFields(rpc.Request.Fields, rpc.HTTP.Path)
+	if rpc.Definition, err = renderRPCDefinition(rpc); err != nil {
+		return err
+	}
+
+	protoDir := filepath.Join(manifest.Layout.Proto, moduleName)
+	messagePath := filepath.Join(protoDir, rpc.FileName)
+	if fileExists(messagePath) {
+		return fmt.Errorf("%s already exists", messagePath)
+	}
+	servicePath := filepath.Join(manifest.Layout.Proto, filepath.FromSlash(service.File.Path))
+	source, err := readFile(servicePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", servicePath, err)
+	}
+	updated, err := insertRPC(string(source), service.Name, rpc.Definition, rpc.ImportPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", servicePath, err)
+	}
+
+	snapshots := append(protogenOutputs(manifest), protoDir, manifest.Layout.RPC, manifest.Layout.Convert, manifest.Layout.Errs)
+	return withRollback(snapshots, func() error {
+		messages, err := renderTemplate("proto/rpc.proto.tmpl", rpc)
+		if err != nil {
+			return err
+		}
+		if err := createFileWithContent(messagePath, messages); err != nil {
+			return err
+		}
+		if err := writeFile(servicePath, updated); err != nil {
+			return fmt.Errorf("failed to write %s: %w", servicePath, err)
+		}
+		logChange("Added", "Would add", rpc.Name, "to", service.Name, "in", servicePath)
+
+		pkg.InfoLog("Generating protobuf files...")
+		if err := runProtogen(manifest); err != nil {
+			return fmt.Errorf("protogen failed: %w", err)
+		}
+		// The handler is generated from the updated proto sources, so a dry
+		// run defers it together with protogen
+		return runExternal("handler generation for "+rpc.Name, func() error {
+			return generateRPCHandler(moduleName, rpc.Name)
+		})
+	})
+}
+
+// goIdentifierPattern matches names usable as proto and Go identifiers
+var goIdentifierPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// findModuleService returns the service of a module, by name when the
+// module defines several
+func findModuleService(set *ProtoSet, moduleName, name string) (*ProtoService, error) {
+	services := set.ServicesInDir(moduleName)
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no proto service found in %s, scaffold one with grpcframe proto new %s", filepath.Join(set.Root, moduleName), moduleName)
+	}
+	if name == "" {
+		if len(services) > 1 {
+			var names []string
+			for _, service := range services {
+				names = append(names, service.Name)
+			}
+			return nil, fmt.Errorf("%s defines several services (%s), pick one with --service", moduleName, strings.Join(names, ", "))
+		}
+		return services[0], nil
+	}
+	for _, service := range services {
+		if service.Name == name {
+			return service, nil
+		}
+	}
+	return nil, fmt.Errorf("service %s not found in %s", name, filepath.Join(set.Root, moduleName))
+}
+
+// rpcByName scaffolds an rpc from its full name. Names made of a verb and
+// the entity, such as UpdateCourse or ListCourses, get the conventions of
+// the verb, any other name a custom POST method.
+func (s *ProtoScaffold) rpcByName(name string) ProtoRPCScaffold {
+	verb := name
+	for _, suffix := range []string{pluralize(s.Entity), s.Entity} {
+		if prefix, ok := strings.CutSuffix(name, suffix); ok && prefix != "" {
+			verb = prefix
+			break
+		}
+	}
+
+	rpc := s.newRPC(verb)
+	if rpc.Name != name {
+		rpc.Name = name
+		rpc.Request.Name = name + "Request"
+		rpc.Response.Name = name + "Response"
+	}
+	return rpc
+}
+
+// parseHTTPFlag parses method:path. POST, PUT and PATCH take the whole
+// request as body unless body says otherwise.
+func parseHTTPFlag(value, body string) (HTTPRule, error) {
+	method, route, ok := strings.Cut(value, ":")
+	method = strings.ToLower(strings.TrimSpace(method))
+	if !ok || !strings.HasPrefix(route, "/") {
+		return HTTPRule{}, fmt.Errorf("invalid --http %q (expected method:/path)", value)
+	}
+	switch method {
+	case "get", "delete":
+	case "post", "put", "patch":
+		if body == "" {
+			body = "*"
+		}
+	default:
+		return HTTPRule{}, fmt.Errorf("unsupported http method %q (expected get, post, put, patch or delete)", method)
+	}
+	return HTTPRule{Method: method, Path: route, Body: body}, nil
+}
+
+// withPathFields adds a string field for every path variable the request
+// does not declare, so the gateway can bind it
+func withPathFields(fields []string, route string) []string {
+	declared := map[string]bool{}
+	for _, field := range fields {
+		parts := strings.Fields(strings.TrimPrefix(field, "repeated "))
+		if len(parts) > 1 {
+			declared[parts[1]] = true
+		}
+	}
+	for _, match := range pathVariablePattern.FindAllStringSubmatch(route, -1) {
+		name, _, _ := strings.Cut(match[1], ".")
+		if declared[name] {
+			continue
+		}
+		declared[name] = true
+		fields = append(fields, fmt.Sprintf("string %s = %d;", name, len(fields)+1))
+	}
+	return fields
+}
+
+// insertRPC adds an rpc before the closing brace of a service and imports
+// its message file, leaving the rest of the source as written
+func insertRPC(source, serviceName, definition, importPath string) (string, error) {
+	_, closing, ok := serviceBody(source, serviceName)
+	if !ok {
+		return "", fmt.Errorf("service %s not found", serviceName)
+	}
+
+	body := strings.TrimRight(source[:closing], " \t\r\n")
+	separator := "\n\n"
+	if strings.HasSuffix(body, "{") {
+		separator = "\n"
+	}
+	updated := body + separator + definition + "\n" + source[closing:]
+	return addProtoImport(updated, importPath), nil
+}
+
+// matchingBrace returns the offset of the brace closing the one at open,
+// skipping strings and comments
+func matchingBrace(source string, open int) int {
+	depth := 0
+	for i := open; i < len(source); i++ {
+		switch c := source[i]; {
+		case c == '"' || c == '\'':
+			for i++; i < len(source) && source[i] != c; i++ {
+				if source[i] == '\\' {
+					i++
+				}
+			}
+		case strings.HasPrefix(source[i:], "//"):
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end < 0 {
+				return -1
+			}
+			i += end + 3
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+var (
+	protoImportLinePattern = regexp.MustCompile(`(?m)^import\s+[^;]*;[^\n]*$`)
+	protoHeaderLinePattern = regexp.MustCompile(`(?m)^(?:option\s+go_package|package)\s+[^;]*;[^\n]*$`)
+)
+
+// addProtoImport adds an import after the last import of a proto file
+func addProtoImport(source, importPath string) string {
+	statement := fmt.Sprintf("import %q;", importPath)
+	if strings.Contains(source, statement) {
+		return source
+	}
+	if imports := protoImportLinePattern.FindAllStringIndex(source, -1); len(imports) > 0 {
+		at := imports[len(imports)-1][1]
+		return source[:at] + "\n" + statement + source[at:]
+	}
+	if headers := protoHeaderLinePattern.FindAllStringIndex(source, -1); len(headers) > 0 {
+		at := headers[len(headers)-1][1]
+		return source[:at] + "\n\n" + statement + source[at:]
+	}
+	return statement + "\n" + source
+}
+
+// generateRPCHandler writes the handler stub of a single rpc
+func generateRPCHandler(moduleName, rpcName string) error {
+	config, err := newModuleConfig(moduleName, "")
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(config.AppPath); os.IsNotExist(err) {
+		pkg.InfoLog("Module", moduleName, "has no handlers yet, create them with grpcframe module add", moduleName)
+		return nil
+	}
+	if err := discoverProtoFiles(config); err != nil {
+		return fmt.Errorf("failed to discover proto files: %w", err)
+	}
+	if err := extractServiceMethods(config); err != nil {
+		return fmt.Errorf("failed to extract service methods: %w", err)
+	}
+
+	for _, method := range config.ServiceMethods {
+		if method.RpcName != rpcName {
+			continue
+		}
+		content, err := generateHandlerContent(config, method)
+		if err != nil {
+			return err
+		}
+		handlerPath := filepath.Join(config.AppPath, method.FileName)
+		result, err := config.writeGeneratedFile(handlerPath, content, "module/handler.go.tmpl")
+		if err != nil {
+			return fmt.Errorf("failed to write handler file %s: %w", handlerPath, err)
+		}
+		if result != writeSkipped {
+			logChange("Generated", "Would generate", "handler", handlerPath)
+		}
+		return config.State.Save()
+	}
+	return fmt.Errorf("rpc %s not found in %s", rpcName, config.ProtoPath)
+}
+
+func init() {
+	rpcAddCmd.Flags().StringVar(&rpcAddHTTP, "http", "", "Http binding as method:path (default from the rpc verb)")
+	rpcAddCmd.Flags().StringVar(&rpcAddBody, "body", "", "Request field mapped to the http body (default * for post, put and patch)")
+	rpcAddCmd.Flags().StringVar(&rpcAddService, "service", "", "Service to add the rpc to when the module defines several")
+	rpcCmd.AddCommand(rpcAddCmd)
+	rootCmd.AddCommand(rpcCmd)
+}
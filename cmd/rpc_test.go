@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchingBrace(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   int
+	}{
+		{name: "flat", source: "{ a }", want: 4},
+		{name: "nested", source: "{ { } { { } } }x", want: 14},
+		{name: "braces in strings", source: `{ "}" '}' "\"}" }`, want: 16},
+		{name: "braces in comments", source: "{ // }\n /* } { */ }", want: 18},
+		{name: "unterminated", source: "{ { }", want: -1},
+		{name: "unterminated block comment", source: "{ /* } ", want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchingBrace(tt.source, 0); got != tt.want {
+				t.Errorf("matchingBrace(%q) = %d, want %d", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertRPC(t *testing.T) {
+	const (
+		header     = "syntax = \"proto3\";\n\npackage app.courses;\n\noption go_package = \"example.com/app/protogen/courses\";\n"
+		definition = "  rpc Ping(PingRequest) returns (PingResponse);"
+		importPath = "courses/rpc_courses_ping.proto"
+		imported   = "\n\nimport \"courses/rpc_courses_ping.proto\";\n"
+	)
+	tests := []struct {
+		name    string
+		source  string
+		service string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "empty service",
+			source:  header + "\nservice CourseService {}\n",
+			service: "CourseService",
+			want:    header[:len(header)-1] + imported + "\nservice CourseService {\n" + definition + "\n}\n",
+		},
+		{
+			name: "braces in comments and strings",
+			source: "import \"google/api/annotations.proto\";\n\n" +
+				"service CourseService {\n" +
+				"  // GetCourse returns a course, } is not the end\n" +
+				"  rpc GetCourse(GetCourseRequest) returns (Course) {\n" +
+				"    option (google.api.http) = {get: \"/v1/courses/{course_id}\"};\n" +
+				"  }\n" +
+				"  /* { */\n" +
+				"}\n",
+			service: "CourseService",
+			want: "import \"google/api/annotations.proto\";\n" +
+				"import \"courses/rpc_courses_ping.proto\";\n\n" +
+				"service CourseService {\n" +
+				"  // GetCourse returns a course, } is not the end\n" +
+				"  rpc GetCourse(GetCourseRequest) returns (Course) {\n" +
+				"    option (google.api.http) = {get: \"/v1/courses/{course_id}\"};\n" +
+				"  }\n" +
+				"  /* { */\n\n" +
+				definition + "\n" +
+				"}\n",
+		},
+		{
+			name: "nested messages",
+			source: "message Course {\n  message Section {\n    message Lesson {}\n  }\n}\n\n" +
+				"service CourseService {\n  rpc GetCourse(GetCourseRequest) returns (Course);\n}\n\n" +
+				"message Trailer {}\n",
+			service: "CourseService",
+			want: "import \"courses/rpc_courses_ping.proto\";\n" +
+				"message Course {\n  message Section {\n    message Lesson {}\n  }\n}\n\n" +
+				"service CourseService {\n  rpc GetCourse(GetCourseRequest) returns (Course);\n\n" + definition + "\n}\n\n" +
+				"message Trailer {}\n",
+		},
+		{
+			name: "second of several services",
+			source: "import \"courses/rpc_courses_ping.proto\";\n\n" +
+				"service CourseService {\n  rpc GetCourse(GetCourseRequest) returns (Course);\n}\n\n" +
+				"service CourseAdminService {\n  rpc PurgeCourse(PurgeCourseRequest) returns (Empty);\n}\n",
+			service: "CourseAdminService",
+			want: "import \"courses/rpc_courses_ping.proto\";\n\n" +
+				"service CourseService {\n  rpc GetCourse(GetCourseRequest) returns (Course);\n}\n\n" +
+				"service CourseAdminService {\n  rpc PurgeCourse(PurgeCourseRequest) returns (Empty);\n\n" + definition + "\n}\n",
+		},
+		{
+			name: "first of several services",
+			source: "service CourseService {\n  rpc GetCourse(GetCourseRequest) returns (Course);\n}\n\n" +
+				"service CourseAdminService {\n  rpc PurgeCourse(PurgeCourseRequest) returns (Empty);\n}\n",
+			service: "CourseService",
+			want: "import \"courses/rpc_courses_ping.proto\";\n" +
+				"service CourseService {\n  rpc GetCourse(GetCourseRequest) returns (Course);\n\n" + definition + "\n}\n\n" +
+				"service CourseAdminService {\n  rpc PurgeCourse(PurgeCourseRequest) returns (Empty);\n}\n",
+		},
+		{
+			name:    "service not found",
+			source:  header + "\nservice CourseAdminService {}\n",
+			service: "CourseService",
+			wantErr: "service CourseService not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := insertRPC(tt.source, tt.service, definition, importPath)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("insertRPC() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("insertRPC() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("insertRPC() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
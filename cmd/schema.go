@@ -0,0 +1,894 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SQLSchema is the model of the tables, enums and indexes declared by the
+// schema files
+type SQLSchema struct {
+	Tables  []*SQLTable
+	Enums   []*SQLEnum
+	Indexes []*SQLIndex
+}
+
+// SQLTable is a CREATE TABLE statement
+type SQLTable struct {
+	Name        string
+	Columns     []*SQLColumn
+	PrimaryKey  []string
+	ForeignKeys []*SQLForeignKey
+	// Constraints are the CHECK, multi column UNIQUE and EXCLUDE
+	// constraints, column CHECK constraints included
+	Constraints []*SQLConstraint
+}
+
+// SQLColumn is a table column. Type holds the canonical postgres type name,
+// such as int4, varchar or timestamptz, or the name of an enum.
+type SQLColumn struct {
+	Name       string
+	Type       string
+	RawType    string
+	Array      bool
+	NotNull    bool
+	Default    string
+	PrimaryKey bool
+	Unique     bool
+	Generated  bool
+}
+
+// SQLForeignKey is a REFERENCES constraint of a table
+type SQLForeignKey struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string
+	OnUpdate   string
+}
+
+// SQLConstraint is a table constraint the model keeps as written, such as
+// CHECK (price > 0) or UNIQUE (a, b)
+type SQLConstraint struct {
+	Name       string
+	Definition string
+}
+
+// SQLIndex is a CREATE INDEX statement
+type SQLIndex struct {
+	Name       string
+	Table      string
+	Definition string
+}
+
+// SQLEnum is a CREATE TYPE ... AS ENUM statement
+type SQLEnum struct {
+	Name   string
+	Values []string
+}
+
+// sqlTypeAliases maps postgres type spellings to their canonical name
+var sqlTypeAliases = map[string]string{
+	"smallint": "int2", "int2": "int2",
+	"integer": "int4", "int": "int4", "int4": "int4",
+	"bigint": "int8", "int8": "int8",
+	"smallserial": "serial2", "serial2": "serial2",
+	"serial": "serial4", "serial4": "serial4",
+	"bigserial": "serial8", "serial8": "serial8",
+	"real": "float4", "float4": "float4",
+	"double precision": "float8", "float8": "float8", "float": "float8",
+	"numeric": "numeric", "decimal": "numeric",
+	"boolean": "bool", "bool": "bool",
+	"text": "text", "citext": "text",
+	"varchar": "varchar", "character varying": "varchar",
+	"char": "bpchar", "character": "bpchar", "bpchar": "bpchar",
+	"uuid":      "uuid",
+	"timestamp": "timestamp", "timestamp without time zone": "timestamp",
+	"timestamptz": "timestamptz", "timestamp with time zone": "timestamptz",
+	"date": "date",
+	"time": "time", "time without time zone": "time",
+	"timetz": "timetz", "time with time zone": "timetz",
+	"interval": "interval",
+	"json":     "json", "jsonb": "jsonb",
+	"bytea": "bytea",
+	"inet":  "inet", "cidr": "cidr",
+}
+
+// loadSQLSchema parses the .sql files of the schema directory in name order
+func loadSQLSchema(dir string) (*SQLSchema, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .sql files found in %s", dir)
+	}
+	sort.Strings(files)
+
+	schema := &SQLSchema{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if err := schema.apply(string(content)); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return schema, nil
+}
+
+// Table returns the table with the given name, ignoring case
+func (s *SQLSchema) Table(name string) *SQLTable {
+	for _, table := range s.Tables {
+		if strings.EqualFold(table.Name, name) {
+			return table
+		}
+	}
+	return nil
+}
+
+// Enum returns the enum with the given name, ignoring case
+func (s *SQLSchema) Enum(name string) *SQLEnum {
+	for _, enum := range s.Enums {
+		if strings.EqualFold(enum.Name, name) {
+			return enum
+		}
+	}
+	return nil
+}
+
+// Index returns the index with the given name
+func (s *SQLSchema) Index(name string) *SQLIndex {
+	for _, index := range s.Indexes {
+		if index.Name == name {
+			return index
+		}
+	}
+	return nil
+}
+
+// Column returns the column with the given name, ignoring case
+func (t *SQLTable) Column(name string) *SQLColumn {
+	for _, column := range t.Columns {
+		if strings.EqualFold(column.Name, name) {
+			return column
+		}
+	}
+	return nil
+}
+
+// apply runs the DDL statements of a file against the schema. Statements
+// other than CREATE TYPE, CREATE TABLE, CREATE INDEX, ALTER TABLE
+// ADD/DROP COLUMN and ADD/DROP CONSTRAINT and DROP TABLE/TYPE/INDEX are
+// ignored.
+func (s *SQLSchema) apply(src string) error {
+	for _, statement := range splitSQLStatements(src) {
+		tokens := tokenizeSQL(statement)
+		switch {
+		case matchKeywords(tokens, "CREATE", "TYPE") && len(tokens) > 5 && tokens[3].is("AS") && tokens[4].is("ENUM"):
+			enum := &SQLEnum{Name: sqlIdentifier(tokens[2].Text)}
+			for _, value := range splitSQLList(tokens[5].inner()) {
+				enum.Values = append(enum.Values, sqlStringValue(value))
+			}
+			s.removeEnum(enum.Name)
+			s.Enums = append(s.Enums, enum)
+		case matchKeywords(tokens, "CREATE") && createsTable(tokens):
+			table, err := parseCreateTable(tokens)
+			if err != nil {
+				return err
+			}
+			s.removeTable(table.Name)
+			s.Tables = append(s.Tables, table)
+		case matchKeywords(tokens, "CREATE", "INDEX"), matchKeywords(tokens, "CREATE", "UNIQUE", "INDEX"):
+			index := parseCreateIndex(tokens)
+			if index.Table == "" || index.Name != "" && s.Index(index.Name) != nil {
+				continue
+			}
+			s.Indexes = append(s.Indexes, index)
+		case matchKeywords(tokens, "ALTER", "TABLE"):
+			if err := s.alterTable(tokens[2:]); err != nil {
+				return err
+			}
+		case matchKeywords(tokens, "DROP", "INDEX"):
+			for _, name := range droppedNames(tokens[2:]) {
+				s.removeIndex(name)
+			}
+		case matchKeywords(tokens, "DROP", "TABLE"):
+			for _, name := range droppedNames(tokens[2:]) {
+				s.removeTable(name)
+				s.Indexes = slices.DeleteFunc(s.Indexes, func(index *SQLIndex) bool { return index.Table == name })
+			}
+		case matchKeywords(tokens, "DROP", "TYPE"):
+			for _, name := range droppedNames(tokens[2:]) {
+				s.removeEnum(name)
+			}
+		}
+	}
+	return nil
+}
+
+// droppedNames returns the names of a DROP statement
+func droppedNames(tokens []sqlToken) []string {
+	if matchKeywords(tokens, "CONCURRENTLY") {
+		tokens = tokens[1:]
+	}
+	if matchKeywords(tokens, "IF", "EXISTS") {
+		tokens = tokens[2:]
+	}
+	var names []string
+	for _, name := range tokens {
+		if name.Kind == sqlWord && (name.is("CASCADE") || name.is("RESTRICT")) {
+			break
+		}
+		if name.Kind == sqlWord || name.Kind == sqlQuoted {
+			names = append(names, sqlIdentifier(name.Text))
+		}
+	}
+	return names
+}
+
+func (s *SQLSchema) removeTable(name string) {
+	for i, table := range s.Tables {
+		if table.Name == name {
+			s.Tables = append(s.Tables[:i], s.Tables[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *SQLSchema) removeIndex(name string) {
+	s.Indexes = slices.DeleteFunc(s.Indexes, func(index *SQLIndex) bool { return index.Name == name })
+}
+
+func (s *SQLSchema) removeEnum(name string) {
+	for i, enum := range s.Enums {
+		if enum.Name == name {
+			s.Enums = append(s.Enums[:i], s.Enums[i+1:]...)
+			return
+		}
+	}
+}
+
+// alterTable applies ADD/DROP COLUMN and ADD/DROP CONSTRAINT actions
+func (s *SQLSchema) alterTable(tokens []sqlToken) error {
+	if matchKeywords(tokens, "IF", "EXISTS") {
+		tokens = tokens[2:]
+	}
+	if matchKeywords(tokens, "ONLY") {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	table := s.Table(sqlIdentifier(tokens[0].Text))
+	if table == nil {
+		return nil
+	}
+
+	for _, action := range splitSQLTokens(tokens[1:]) {
+		switch {
+		case matchKeywords(action, "ADD"):
+			action = action[1:]
+			if matchKeywords(action, "COLUMN") {
+				action = action[1:]
+			}
+			if matchKeywords(action, "IF", "NOT", "EXISTS") {
+				action = action[3:]
+			}
+			if len(action) == 0 {
+				continue
+			}
+			if isTableConstraint(action) {
+				table.addConstraint(action)
+				continue
+			}
+			if table.Column(sqlIdentifier(action[0].Text)) != nil {
+				continue
+			}
+			if err := table.addColumn(action); err != nil {
+				return err
+			}
+		case matchKeywords(action, "DROP"):
+			action = action[1:]
+			if matchKeywords(action, "COLUMN") {
+				action = action[1:]
+			}
+			if matchKeywords(action, "IF", "EXISTS") {
+				action = action[2:]
+			}
+			if len(action) == 0 {
+				continue
+			}
+			if matchKeywords(action, "CONSTRAINT") {
+				if names := droppedNames(action[1:]); len(names) > 0 {
+					table.dropConstraint(names[0])
+				}
+				continue
+			}
+			name := sqlIdentifier(action[0].Text)
+			for i, column := range table.Columns {
+				if column.Name == name {
+					table.Columns = append(table.Columns[:i], table.Columns[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func createsTable(tokens []sqlToken) bool {
+	for _, token := range tokens[1:] {
+		switch {
+		case token.is("TABLE"):
+			return true
+		case token.is("UNLOGGED"), token.is("TEMP"), token.is("TEMPORARY"):
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+func parseCreateTable(tokens []sqlToken) (*SQLTable, error) {
+	i := 1
+	for !tokens[i].is("TABLE") {
+		i++
+	}
+	i++
+	if matchKeywords(tokens[i:], "IF", "NOT", "EXISTS") {
+		i += 3
+	}
+	if i+1 >= len(tokens) || tokens[i+1].Kind != sqlGroup {
+		return nil, fmt.Errorf("unsupported CREATE TABLE statement")
+	}
+
+	table := &SQLTable{Name: sqlIdentifier(tokens[i].Text)}
+	for _, definition := range splitSQLTokens(tokenizeSQL(tokens[i+1].inner())) {
+		if len(definition) == 0 {
+			continue
+		}
+		if isTableConstraint(definition) {
+			table.addConstraint(definition)
+			continue
+		}
+		if definition[0].is("LIKE") {
+			continue
+		}
+		if err := table.addColumn(definition); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range table.PrimaryKey {
+		if column := table.Column(name); column != nil {
+			column.NotNull = true
+		}
+	}
+	return table, nil
+}
+
+// addColumn parses a column definition and adds the column with its
+// constraints to the table
+func (t *SQLTable) addColumn(tokens []sqlToken) error {
+	column, foreignKey, checks, err := parseColumn(tokens)
+	if err != nil {
+		return fmt.Errorf("table %s: %w", t.Name, err)
+	}
+	t.Columns = append(t.Columns, column)
+	if column.PrimaryKey {
+		t.PrimaryKey = []string{column.Name}
+	}
+	if foreignKey != nil {
+		t.ForeignKeys = append(t.ForeignKeys, foreignKey)
+	}
+	t.Constraints = append(t.Constraints, checks...)
+	return nil
+}
+
+// parseCreateIndex parses "CREATE [UNIQUE] INDEX [CONCURRENTLY] [IF NOT
+// EXISTS] [name] ON [ONLY] table ...". The name is empty when postgres
+// picks it.
+func parseCreateIndex(tokens []sqlToken) *SQLIndex {
+	index := &SQLIndex{Definition: joinSQLTokens(tokens)}
+	rest := tokens[2:]
+	if matchKeywords(rest, "INDEX") {
+		rest = rest[1:]
+	}
+	if matchKeywords(rest, "CONCURRENTLY") {
+		rest = rest[1:]
+	}
+	if matchKeywords(rest, "IF", "NOT", "EXISTS") {
+		rest = rest[3:]
+	}
+	if len(rest) > 0 && !rest[0].is("ON") {
+		index.Name = sqlIdentifier(rest[0].Text)
+		rest = rest[1:]
+	}
+	if !matchKeywords(rest, "ON") {
+		return index
+	}
+	rest = rest[1:]
+	if matchKeywords(rest, "ONLY") {
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		index.Table = sqlIdentifier(rest[0].Text)
+	}
+	return index
+}
+
+// columnConstraintKeywords end the type of a column definition
+var columnConstraintKeywords = map[string]bool{
+	"CONSTRAINT": true, "NOT": true, "NULL": true, "DEFAULT": true, "PRIMARY": true, "UNIQUE": true,
+	"REFERENCES": true, "CHECK": true, "GENERATED": true, "COLLATE": true,
+}
+
+// parseColumn parses a column definition and its inline constraints. CHECK
+// constraints are returned as table constraints.
+func parseColumn(tokens []sqlToken) (*SQLColumn, *SQLForeignKey, []*SQLConstraint, error) {
+	if len(tokens) < 2 {
+		return nil, nil, nil, fmt.Errorf("invalid column definition %q", joinSQLTokens(tokens))
+	}
+	column := &SQLColumn{Name: sqlIdentifier(tokens[0].Text)}
+
+	i := 1
+	var typeWords, rawType []string
+	for ; i < len(tokens) && !columnConstraintKeywords[tokens[i].upper()]; i++ {
+		token := tokens[i]
+		rawType = append(rawType, token.Text)
+		switch {
+		case token.Kind == sqlGroup:
+		case token.Text == "[" || token.Text == "]" || token.is("ARRAY"):
+			column.Array = true
+		default:
+			typeWords = append(typeWords, strings.ToLower(token.Text))
+		}
+	}
+	typeName := strings.Join(typeWords, " ")
+	if canonical, ok := sqlTypeAliases[typeName]; ok {
+		typeName = canonical
+	} else {
+		typeName = sqlIdentifier(typeName)
+	}
+	column.Type = typeName
+	column.RawType = strings.ReplaceAll(strings.Join(rawType, " "), " (", "(")
+
+	var foreignKey *SQLForeignKey
+	var checks []*SQLConstraint
+	constraintName := ""
+	for i < len(tokens) {
+		token := tokens[i]
+		i++
+		switch token.upper() {
+		case "CONSTRAINT":
+			if i < len(tokens) {
+				constraintName = sqlIdentifier(tokens[i].Text)
+				i++
+			}
+			continue
+		case "NOT":
+			if i < len(tokens) && tokens[i].is("NULL") {
+				column.NotNull = true
+				i++
+			}
+		case "PRIMARY":
+			column.PrimaryKey, column.NotNull = true, true
+			if i < len(tokens) && tokens[i].is("KEY") {
+				i++
+			}
+		case "UNIQUE":
+			column.Unique = true
+		case "DEFAULT":
+			start := i
+			for i < len(tokens) && !columnConstraintKeywords[tokens[i].upper()] {
+				i++
+			}
+			column.Default = joinSQLTokens(tokens[start:i])
+		case "GENERATED":
+			column.Generated = true
+			for i < len(tokens) && !columnConstraintKeywords[tokens[i].upper()] {
+				i++
+			}
+		case "COLLATE":
+			i++
+		case "CHECK":
+			if i < len(tokens) && tokens[i].Kind == sqlGroup {
+				checks = append(checks, &SQLConstraint{Name: constraintName, Definition: "CHECK " + tokens[i].Text})
+				i++
+			}
+		case "REFERENCES":
+			foreignKey = &SQLForeignKey{Name: constraintName, Columns: []string{column.Name}}
+			i = parseReferences(tokens, i, foreignKey)
+		}
+		constraintName = ""
+	}
+	return column, foreignKey, checks, nil
+}
+
+// parseReferences reads "table [(columns)] [ON DELETE action] ..." from
+// tokens[i:] and returns the index after the clause
+func parseReferences(tokens []sqlToken, i int, foreignKey *SQLForeignKey) int {
+	if i < len(tokens) {
+		foreignKey.RefTable = sqlIdentifier(tokens[i].Text)
+		i++
+	}
+	if i < len(tokens) && tokens[i].Kind == sqlGroup {
+		foreignKey.RefColumns = sqlIdentifierList(tokens[i].inner())
+		i++
+	}
+	for i < len(tokens) && !columnConstraintKeywords[tokens[i].upper()] {
+		if matchKeywords(tokens[i:], "ON") && i+2 < len(tokens) {
+			action := []string{tokens[i+2].upper()}
+			if i+3 < len(tokens) && (tokens[i+3].is("NULL") || tokens[i+3].is("DEFAULT") || tokens[i+3].is("ACTION")) {
+				action = append(action, tokens[i+3].upper())
+			}
+			switch {
+			case tokens[i+1].is("DELETE"):
+				foreignKey.OnDelete = strings.Join(action, " ")
+			case tokens[i+1].is("UPDATE"):
+				foreignKey.OnUpdate = strings.Join(action, " ")
+			}
+		}
+		i++
+	}
+	return i
+}
+
+func isTableConstraint(tokens []sqlToken) bool {
+	switch tokens[0].upper() {
+	case "CONSTRAINT", "PRIMARY", "FOREIGN", "UNIQUE", "CHECK", "EXCLUDE":
+		return true
+	}
+	return false
+}
+
+// addConstraint records a table level PRIMARY KEY, FOREIGN KEY, UNIQUE,
+// CHECK or EXCLUDE constraint
+func (t *SQLTable) addConstraint(tokens []sqlToken) {
+	name := ""
+	if tokens[0].is("CONSTRAINT") && len(tokens) > 2 {
+		name = sqlIdentifier(tokens[1].Text)
+		tokens = tokens[2:]
+	}
+	switch {
+	case matchKeywords(tokens, "PRIMARY", "KEY") && len(tokens) > 2:
+		t.PrimaryKey = sqlIdentifierList(tokens[2].inner())
+	case matchKeywords(tokens, "FOREIGN", "KEY") && len(tokens) > 3 && tokens[3].is("REFERENCES"):
+		foreignKey := &SQLForeignKey{Name: name, Columns: sqlIdentifierList(tokens[2].inner())}
+		parseReferences(tokens, 4, foreignKey)
+		t.ForeignKeys = append(t.ForeignKeys, foreignKey)
+	case tokens[0].is("UNIQUE") && len(tokens) > 1 && tokens[1].Kind == sqlGroup:
+		if columns := sqlIdentifierList(tokens[1].inner()); len(columns) == 1 && name == "" {
+			if column := t.Column(columns[0]); column != nil {
+				column.Unique = true
+				return
+			}
+		}
+		t.Constraints = append(t.Constraints, &SQLConstraint{Name: name, Definition: joinSQLDefinition(tokens)})
+	case tokens[0].is("CHECK"), tokens[0].is("EXCLUDE"):
+		t.Constraints = append(t.Constraints, &SQLConstraint{Name: name, Definition: joinSQLDefinition(tokens)})
+	}
+}
+
+// dropConstraint removes the named foreign key or constraint
+func (t *SQLTable) dropConstraint(name string) {
+	t.ForeignKeys = slices.DeleteFunc(t.ForeignKeys, func(foreignKey *SQLForeignKey) bool { return foreignKey.Name == name })
+	t.Constraints = slices.DeleteFunc(t.Constraints, func(constraint *SQLConstraint) bool { return constraint.Name == name })
+}
+
+// sqlTokenKind classifies the tokens of a statement
+type sqlTokenKind int
+
+const (
+	sqlWord sqlTokenKind = iota
+	sqlQuoted
+	sqlString
+	sqlGroup
+	sqlPunct
+)
+
+// sqlToken is a word, quoted identifier, string literal, parenthesized
+// group or punctuation character of a statement
+type sqlToken struct {
+	Kind sqlTokenKind
+	Text string
+}
+
+func (t sqlToken) upper() string {
+	if t.Kind != sqlWord {
+		return ""
+	}
+	return strings.ToUpper(t.Text)
+}
+
+func (t sqlToken) is(keyword string) bool {
+	return t.upper() == keyword
+}
+
+// inner returns the text inside the parentheses of a group token
+func (t sqlToken) inner() string {
+	if t.Kind != sqlGroup {
+		return ""
+	}
+	return t.Text[1 : len(t.Text)-1]
+}
+
+func matchKeywords(tokens []sqlToken, keywords ...string) bool {
+	if len(tokens) < len(keywords) {
+		return false
+	}
+	for i, keyword := range keywords {
+		if !tokens[i].is(keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitSQLStatements strips comments and splits src on the semicolons
+// outside of strings, quoted identifiers and dollar quoted bodies
+func splitSQLStatements(src string) []string {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end + 3
+			}
+			current.WriteByte(' ')
+		case c == '\'' || c == '"':
+			end := quotedEnd(src, i)
+			current.WriteString(src[i:end])
+			i = end - 1
+		case c == '$':
+			tag := dollarTag(src[i:])
+			if tag == "" {
+				current.WriteByte(c)
+				continue
+			}
+			end := strings.Index(src[i+len(tag):], tag)
+			if end < 0 {
+				end = len(src) - i - len(tag)
+			} else {
+				end += len(tag)
+			}
+			current.WriteString(src[i : i+len(tag)+end])
+			i += len(tag) + end - 1
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
+
+// quotedEnd returns the offset after the quoted string starting at i,
+// where a doubled quote character escapes itself
+func quotedEnd(src string, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		if src[j] != quote {
+			continue
+		}
+		if j+1 < len(src) && src[j+1] == quote {
+			j++
+			continue
+		}
+		return j + 1
+	}
+	return len(src)
+}
+
+// dollarTag returns the $tag$ opening a dollar quoted string, or ""
+func dollarTag(src string) string {
+	for j := 1; j < len(src); j++ {
+		c := src[j]
+		if c == '$' {
+			return src[:j+1]
+		}
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || j > 1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+// tokenizeSQL splits a statement into tokens, keeping parenthesized groups
+// together
+func tokenizeSQL(statement string) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(statement); {
+		c := statement[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			depth, j := 0, i
+			for ; j < len(statement); j++ {
+				switch statement[j] {
+				case '\'', '"':
+					j = quotedEnd(statement, j) - 1
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			end := min(j+1, len(statement))
+			tokens = append(tokens, sqlToken{Kind: sqlGroup, Text: statement[i:end]})
+			i = end
+		case c == '\'':
+			end := quotedEnd(statement, i)
+			tokens = append(tokens, sqlToken{Kind: sqlString, Text: statement[i:end]})
+			i = end
+		case c == '"' || isSQLWordByte(c):
+			// qualified names such as public."Order" are a single token
+			j := i
+			for j < len(statement) {
+				if statement[j] == '"' {
+					j = quotedEnd(statement, j)
+				} else if isSQLWordByte(statement[j]) {
+					for j < len(statement) && isSQLWordByte(statement[j]) {
+						j++
+					}
+				} else {
+					break
+				}
+				if j+1 >= len(statement) || statement[j] != '.' || !(statement[j+1] == '"' || isSQLWordByte(statement[j+1])) {
+					break
+				}
+				j++
+			}
+			kind := sqlWord
+			if c == '"' {
+				kind = sqlQuoted
+			}
+			tokens = append(tokens, sqlToken{Kind: kind, Text: statement[i:j]})
+			i = j
+		default:
+			tokens = append(tokens, sqlToken{Kind: sqlPunct, Text: string(c)})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isSQLWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// splitSQLTokens splits tokens on top level commas
+func splitSQLTokens(tokens []sqlToken) [][]sqlToken {
+	var parts [][]sqlToken
+	start := 0
+	for i, token := range tokens {
+		if token.Kind == sqlPunct && token.Text == "," {
+			parts = append(parts, tokens[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+// splitSQLList splits a comma separated list such as the values of an enum
+func splitSQLList(list string) []string {
+	var items []string
+	for _, part := range splitSQLTokens(tokenizeSQL(list)) {
+		if len(part) > 0 {
+			items = append(items, joinSQLTokens(part))
+		}
+	}
+	return items
+}
+
+func sqlIdentifierList(list string) []string {
+	var names []string
+	for _, item := range splitSQLList(list) {
+		names = append(names, sqlIdentifier(item))
+	}
+	return names
+}
+
+func joinSQLTokens(tokens []sqlToken) string {
+	var b strings.Builder
+	for i, token := range tokens {
+		if i > 0 && token.Kind != sqlGroup && !(token.Kind == sqlPunct && token.Text != "=") {
+			b.WriteByte(' ')
+		}
+		b.WriteString(token.Text)
+	}
+	return b.String()
+}
+
+// joinSQLDefinition joins the tokens of a constraint with a space before
+// every group, as in CHECK (a > 0) or UNIQUE (a, b)
+func joinSQLDefinition(tokens []sqlToken) string {
+	var b strings.Builder
+	for i, token := range tokens {
+		if i > 0 && !(token.Kind == sqlPunct && token.Text != "=") {
+			b.WriteByte(' ')
+		}
+		b.WriteString(token.Text)
+	}
+	return b.String()
+}
+
+// sqlIdentifier folds the unquoted parts of a name to lower case the way
+// postgres does, keeps quoted ones and drops the public schema
+func sqlIdentifier(name string) string {
+	name = strings.TrimSpace(name)
+	var parts []string
+	for start, i := 0, 0; i <= len(name); i++ {
+		if i < len(name) && name[i] == '"' {
+			i = quotedEnd(name, i) - 1
+			continue
+		}
+		if i == len(name) || name[i] == '.' {
+			parts = append(parts, sqlIdentifierPart(name[start:i]))
+			start = i + 1
+		}
+	}
+	if len(parts) > 1 && parts[0] == "public" {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, ".")
+}
+
+func sqlIdentifierPart(part string) string {
+	if strings.HasPrefix(part, `"`) {
+		return strings.ReplaceAll(strings.TrimSuffix(part[1:], `"`), `""`, `"`)
+	}
+	return strings.ToLower(part)
+}
+
+// sqlQuoteIdentifier quotes identifiers postgres would otherwise fold to
+// lower case or not read as a name, such as names starting with a digit
+func sqlQuoteIdentifier(name string) string {
+	plain := name != "" && !(name[0] >= '0' && name[0] <= '9')
+	for _, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			plain = false
+		}
+	}
+	if plain {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqlStringValue returns the value of a string literal
+func sqlStringValue(literal string) string {
+	literal = strings.TrimSpace(literal)
+	if strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") && len(literal) > 1 {
+		return strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+	}
+	return literal
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSplitSQLStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "semicolons",
+			src:  "CREATE TABLE a (id int);\n\nCREATE TABLE b (id int);\n",
+			want: []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"},
+		},
+		{
+			name: "no trailing semicolon",
+			src:  "SELECT 1",
+			want: []string{"SELECT 1"},
+		},
+		{
+			name: "line comments",
+			src:  "-- create a; and b\nSELECT 1; -- done;\nSELECT 2;",
+			want: []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name: "block comments",
+			src:  "/* first;\nsecond; */SELECT 1;/* unterminated; SELECT 2;",
+			want: []string{"SELECT 1"},
+		},
+		{
+			name: "strings and quoted identifiers",
+			src:  `INSERT INTO "we;ird" VALUES ('a;b', 'it''s;');SELECT 2;`,
+			want: []string{`INSERT INTO "we;ird" VALUES ('a;b', 'it''s;')`, "SELECT 2"},
+		},
+		{
+			name: "dollar quoted body",
+			src:  "CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n  NEW.a := 1; -- keep;\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql;\nSELECT 1;",
+			want: []string{"CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n  NEW.a := 1; -- keep;\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql", "SELECT 1"},
+		},
+		{
+			name: "tagged dollar quote",
+			src:  "DO $body$ BEGIN PERFORM '$$;'; END $body$;SELECT $1;",
+			want: []string{"DO $body$ BEGIN PERFORM '$$;'; END $body$", "SELECT $1"},
+		},
+		{
+			name: "only comments",
+			src:  "-- nothing here;\n/* ; */",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitSQLStatements(tt.src); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitSQLStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeSQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      []sqlToken
+	}{
+		{
+			name:      "words and punctuation",
+			statement: "ALTER TABLE public.users ADD COLUMN age int4, DROP x;",
+			want: []sqlToken{
+				{sqlWord, "ALTER"}, {sqlWord, "TABLE"}, {sqlWord, "public.users"}, {sqlWord, "ADD"},
+				{sqlWord, "COLUMN"}, {sqlWord, "age"}, {sqlWord, "int4"}, {sqlPunct, ","},
+				{sqlWord, "DROP"}, {sqlWord, "x"}, {sqlPunct, ";"},
+			},
+		},
+		{
+			name:      "strings and quoted identifiers",
+			statement: `"My ""Table""" DEFAULT 'it''s'`,
+			want:      []sqlToken{{sqlQuoted, `"My ""Table"""`}, {sqlWord, "DEFAULT"}, {sqlString, `'it''s'`}},
+		},
+		{
+			name:      "qualified names",
+			statement: `public."Order Items" "app".users 1.5`,
+			want:      []sqlToken{{sqlWord, `public."Order Items"`}, {sqlQuoted, `"app".users`}, {sqlWord, "1.5"}},
+		},
+		{
+			name:      "nested groups",
+			statement: "CHECK (price > 0 AND (kind IN ('a)', 'b')))",
+			want:      []sqlToken{{sqlWord, "CHECK"}, {sqlGroup, "(price > 0 AND (kind IN ('a)', 'b')))"}},
+		},
+		{
+			name:      "unterminated group",
+			statement: "numeric(10",
+			want:      []sqlToken{{sqlWord, "numeric"}, {sqlGroup, "(10"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenizeSQL(tt.statement); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenizeSQL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Users", "users"},
+		{`"Users"`, "Users"},
+		{`"say ""hi"""`, `say "hi"`},
+		{"public.users", "users"},
+		{`PUBLIC."Order Items"`, "Order Items"},
+		{`"public"."a.b"`, "a.b"},
+		{"Billing.Invoices", "billing.invoices"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sqlIdentifier(tt.name); got != tt.want {
+				t.Errorf("sqlIdentifier(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCreateTable(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      *SQLTable
+		wantErr   bool
+	}{
+		{
+			name:      "column constraints",
+			statement: "CREATE TABLE IF NOT EXISTS users (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), email varchar(255) NOT NULL UNIQUE, bio text)",
+			want: &SQLTable{
+				Name:       "users",
+				PrimaryKey: []string{"id"},
+				Columns: []*SQLColumn{
+					{Name: "id", Type: "uuid", RawType: "uuid", NotNull: true, Default: "gen_random_uuid()", PrimaryKey: true},
+					{Name: "email", Type: "varchar", RawType: "varchar(255)", NotNull: true, Unique: true},
+					{Name: "bio", Type: "text", RawType: "text"},
+				},
+			},
+		},
+		{
+			name: "table level primary and foreign keys",
+			statement: "CREATE TABLE enrollments (course_id uuid, user_id uuid, seat int, " +
+				"PRIMARY KEY (course_id, user_id), " +
+				"CONSTRAINT enrollments_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE, " +
+				"FOREIGN KEY (course_id) REFERENCES courses ON UPDATE RESTRICT)",
+			want: &SQLTable{
+				Name:       "enrollments",
+				PrimaryKey: []string{"course_id", "user_id"},
+				Columns: []*SQLColumn{
+					{Name: "course_id", Type: "uuid", RawType: "uuid", NotNull: true},
+					{Name: "user_id", Type: "uuid", RawType: "uuid", NotNull: true},
+					{Name: "seat", Type: "int4", RawType: "int"},
+				},
+				ForeignKeys: []*SQLForeignKey{
+					{Name: "enrollments_user_fk", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}, OnDelete: "CASCADE"},
+					{Columns: []string{"course_id"}, RefTable: "courses", OnUpdate: "RESTRICT"},
+				},
+			},
+		},
+		{
+			name:      "quoted names",
+			statement: `CREATE TABLE public."Order Items" ("Item ID" int8 NOT NULL, "order" text REFERENCES "public"."Orders" ("Order ID"), CONSTRAINT "Items PK" PRIMARY KEY ("Item ID"))`,
+			want: &SQLTable{
+				Name:       "Order Items",
+				PrimaryKey: []string{"Item ID"},
+				Columns: []*SQLColumn{
+					{Name: "Item ID", Type: "int8", RawType: "int8", NotNull: true},
+					{Name: "order", Type: "text", RawType: "text"},
+				},
+				ForeignKeys: []*SQLForeignKey{
+					{Columns: []string{"order"}, RefTable: "Orders", RefColumns: []string{"Order ID"}},
+				},
+			},
+		},
+		{
+			name:      "check and unique constraints",
+			statement: "CREATE TABLE prices (a int CHECK (a > 0), b int, UNIQUE (a, b), CONSTRAINT b_positive CHECK (b > 0))",
+			want: &SQLTable{
+				Name: "prices",
+				Columns: []*SQLColumn{
+					{Name: "a", Type: "int4", RawType: "int"},
+					{Name: "b", Type: "int4", RawType: "int"},
+				},
+				Constraints: []*SQLConstraint{
+					{Definition: "CHECK (a > 0)"},
+					{Definition: "UNIQUE (a, b)"},
+					{Name: "b_positive", Definition: "CHECK (b > 0)"},
+				},
+			},
+		},
+		{
+			name:      "no column list",
+			statement: "CREATE TABLE copy AS SELECT * FROM users",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCreateTable(tokenizeSQL(tt.statement))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCreateTable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCreateTable() = %s, want %s", describeTable(got), describeTable(tt.want))
+			}
+		})
+	}
+}
+
+// describeTable prints a table with its columns and constraints for test
+// failures
+func describeTable(table *SQLTable) string {
+	if table == nil {
+		return "<nil>"
+	}
+	s := fmt.Sprintf("%s pk%v", table.Name, table.PrimaryKey)
+	for _, column := range table.Columns {
+		s += fmt.Sprintf("\n  column %+v", *column)
+	}
+	for _, foreignKey := range table.ForeignKeys {
+		s += fmt.Sprintf("\n  foreign key %+v", *foreignKey)
+	}
+	for _, constraint := range table.Constraints {
+		s += fmt.Sprintf("\n  constraint %+v", *constraint)
+	}
+	return s
+}
@@ -0,0 +1,654 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// serverEditResult reports the changes made to an existing server.go
+type serverEditResult struct {
+	Added  []string
+	Stale  []string
+	Pruned []string
+	// Rewired are the constructor calls whose arguments were updated to
+	// the current constructor parameters
+	Rewired []string
+}
+
+// textEdit replaces src[Start:End] with Text
+type textEdit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// goSource is a parsed Go file together with its import table
+type goSource struct {
+	src     []byte
+	fset    *token.FileSet
+	file    *ast.File
+	aliases map[string]string // import name -> path
+	paths   map[string]string // path -> import name
+}
+
+// registerCall is a RegisterXxxServer call found in Server.Run
+type registerCall struct {
+	Stmt    ast.Stmt
+	Path    string
+	Func    string
+	Service string
+}
+
+func parseGoSource(filename string, src []byte) (*goSource, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	g := &goSource{src: src, fset: fset, file: file, aliases: map[string]string{}, paths: map[string]string{}}
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		g.aliases[name] = importPath
+		g.paths[importPath] = name
+	}
+	return g, nil
+}
+
+func (g *goSource) offset(pos token.Pos) int {
+	return g.fset.Position(pos).Offset
+}
+
+// lineRange returns the offsets of the full lines spanned by node
+func (g *goSource) lineRange(node ast.Node) (int, int) {
+	start := g.offset(node.Pos())
+	for start > 0 && g.src[start-1] != '\n' {
+		start--
+	}
+	end := g.offset(node.End())
+	for end < len(g.src) && g.src[end] != '\n' {
+		end++
+	}
+	if end < len(g.src) {
+		end++
+	}
+	return start, end
+}
+
+// qualifiedRef returns the import path and name of a pkg.Name expression
+func (g *goSource) qualifiedRef(expr ast.Expr) (string, string, bool) {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return "", "", false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", "", false
+	}
+	importPath, ok := g.aliases[ident.Name]
+	return importPath, sel.Sel.Name, ok
+}
+
+// goTypeRef identifies a Go type by import path and name
+type goTypeRef struct {
+	Path    string
+	Name    string
+	Pointer bool
+}
+
+func (r goTypeRef) String() string {
+	name := r.Name
+	if r.Path != "" {
+		name = path.Base(r.Path) + "." + name
+	}
+	if r.Pointer {
+		return "*" + name
+	}
+	return name
+}
+
+// typeRef resolves a type expression of the file, attributing unqualified
+// names other than the predeclared types to localPath
+func (g *goSource) typeRef(expr ast.Expr, localPath string) (goTypeRef, bool) {
+	var ref goTypeRef
+	if star, ok := expr.(*ast.StarExpr); ok {
+		ref.Pointer = true
+		expr = star.X
+	}
+	switch e := expr.(type) {
+	case *ast.Ident:
+		ref.Name = e.Name
+		if types.Universe.Lookup(e.Name) == nil {
+			ref.Path = localPath
+		}
+		return ref, true
+	case *ast.SelectorExpr:
+		importPath, name, ok := g.qualifiedRef(e)
+		ref.Path, ref.Name = importPath, name
+		return ref, ok
+	}
+	return ref, false
+}
+
+// usesName reports whether the file references the package imported as name
+func (g *goSource) usesName(name string) bool {
+	used := false
+	ast.Inspect(g.file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok && ident.Name == name {
+				used = true
+			}
+		}
+		return !used
+	})
+	return used
+}
+
+func applyEdits(src []byte, edits []textEdit) []byte {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+
+	var buf bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		if edit.Start < last {
+			continue // overlapping edit, the earlier one wins
+		}
+		buf.Write(src[last:edit.Start])
+		buf.WriteString(edit.Text)
+		last = edit.End
+	}
+	buf.Write(src[last:])
+	return buf.Bytes()
+}
+
+// splitQualified splits "pkg.Name" into its parts
+func splitQualified(name string) (string, string) {
+	alias, rest, _ := strings.Cut(name, ".")
+	return alias, rest
+}
+
+// updateServerFile adds missing service registrations to an existing
+// server.go and reports (or with prune removes) registrations of services
+// that no longer exist. Only the affected lines are touched, so interceptors,
+// options and other custom code are kept. prune selects the stale
+// registrations to remove by the import path of their protobuf package.
+func updateServerFile(filename string, src []byte, manifest *Manifest, registrations []ServiceRegistration, prune func(pbPath string) bool) ([]byte, *serverEditResult, error) {
+	g, err := parseGoSource(filename, src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	run := findServerMethod(g.file, "Run")
+	if run == nil || run.Body == nil {
+		return nil, nil, fmt.Errorf("%s has no Server.Run method to register services in", filename)
+	}
+	serverStmt, serverVar := findGRPCServer(g, run.Body)
+	if serverStmt == nil {
+		return nil, nil, fmt.Errorf("%s: no grpc.NewServer() call found in Server.Run", filename)
+	}
+	serverStruct := findServerStruct(g.file)
+
+	// Split the existing registrations into current ones and registrations
+	// of project services that no longer exist
+	expected := map[string]bool{}
+	for _, reg := range registrations {
+		_, registerFunc := splitQualified(reg.RegisterFunc)
+		expected[reg.PbImport.Path+"."+registerFunc] = true
+	}
+	protogenPath := manifest.ImportPath(manifest.Layout.Protogen)
+	var calls, staleCalls []registerCall
+	for _, call := range findRegisterCalls(g, run.Body, serverVar) {
+		if expected[call.Path+"."+call.Func] || !isWithinImportPath(call.Path, protogenPath) {
+			calls = append(calls, call)
+		} else {
+			staleCalls = append(staleCalls, call)
+		}
+	}
+
+	assigned := map[string]ast.Stmt{}
+	var lastConstructor ast.Stmt
+	for _, stmt := range run.Body.List {
+		assign, ok := stmt.(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+			continue
+		}
+		ident, ok := assign.Lhs[0].(*ast.Ident)
+		if !ok {
+			continue
+		}
+		assigned[ident.Name] = stmt
+		for _, call := range calls {
+			if argIdent(call.Stmt, 1) == ident.Name {
+				lastConstructor = stmt
+			}
+		}
+	}
+
+	result := &serverEditResult{}
+	var edits []textEdit
+
+	// Imports added by this run, reused across registrations
+	newImports := map[string]string{}
+	importName := func(imp GoImport) string {
+		if name, ok := g.paths[imp.Path]; ok {
+			return name
+		}
+		if name, ok := newImports[imp.Path]; ok {
+			return name
+		}
+		name := imp.Alias
+		for i := 2; ; i++ {
+			if _, taken := g.aliases[name]; !taken {
+				break
+			}
+			name = fmt.Sprintf("%s%d", imp.Alias, i)
+		}
+		g.aliases[name] = imp.Path
+		newImports[imp.Path] = name
+		return name
+	}
+
+	receiver := ""
+	if names := run.Recv.List[0].Names; len(names) > 0 {
+		receiver = names[0].Name
+	}
+	serverPath := manifest.ImportPath(manifest.Layout.RPC)
+
+	var constructors, registers, embeds strings.Builder
+	var assignedVars []ast.Stmt
+	for _, reg := range registrations {
+		if call := constructorCall(g, assigned[reg.ServiceVar], reg); call != nil && len(call.Args) != len(reg.Params) {
+			args, err := wireArgs(g, serverStruct, serverPath, receiver, reg)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", filename, err)
+			}
+			edits = append(edits, textEdit{Start: g.offset(call.Lparen) + 1, End: g.offset(call.Rparen), Text: args})
+			result.Rewired = append(result.Rewired, reg.Constructor)
+		}
+
+		_, registerFunc := splitQualified(reg.RegisterFunc)
+		if hasRegisterCall(calls, reg.PbImport.Path, registerFunc) {
+			continue
+		}
+
+		pbName := importName(reg.PbImport)
+		handlerName := importName(reg.HandlerImport)
+		_, constructor := splitQualified(reg.Constructor)
+		_, unimplemented := splitQualified(reg.Unimplemented)
+
+		if stmt, exists := assigned[reg.ServiceVar]; exists {
+			// a hand-written assignment must come before the register call
+			assignedVars = append(assignedVars, stmt)
+		} else {
+			args, err := wireArgs(g, serverStruct, serverPath, receiver, reg)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", filename, err)
+			}
+			fmt.Fprintf(&constructors, "\n\t%s := %s.%s(%s)", reg.ServiceVar, handlerName, constructor, args)
+		}
+		fmt.Fprintf(&registers, "\n\t%s.%s(%s, %s)", pbName, registerFunc, serverVar, reg.ServiceVar)
+		if serverStruct != nil && !hasEmbeddedField(g, serverStruct, reg.PbImport.Path, unimplemented) {
+			fmt.Fprintf(&embeds, "\n\t%s.%s", pbName, unimplemented)
+		}
+		result.Added = append(result.Added, fmt.Sprintf("%s.%s", pbName, registerFunc))
+	}
+
+	if constructors.Len() > 0 || registers.Len() > 0 {
+		// New code goes after the server, the existing constructors and the
+		// variables it registers, whichever comes last
+		constructorAt := latestStmt(append([]ast.Stmt{serverStmt, lastConstructor}, assignedVars...)...)
+		edits = append(edits, textEdit{Start: g.offset(constructorAt.End()), End: g.offset(constructorAt.End()), Text: constructors.String()})
+
+		if len(calls) > 0 {
+			at := g.offset(latestStmt(constructorAt, calls[len(calls)-1].Stmt).End())
+			edits = append(edits, textEdit{Start: at, End: at, Text: registers.String()})
+		} else {
+			at := g.offset(constructorAt.End())
+			edits = append(edits, textEdit{Start: at, End: at, Text: "\n\n\t// Register services with gRPC server" + registers.String()})
+		}
+	}
+	if embeds.Len() > 0 {
+		at := g.offset(serverStruct.Fields.Opening) + 1
+		for _, field := range serverStruct.Fields.List {
+			if len(field.Names) == 0 {
+				at = g.offset(field.End())
+			}
+		}
+		edits = append(edits, textEdit{Start: at, End: at, Text: embeds.String()})
+	}
+	if len(newImports) > 0 {
+		edits = append(edits, importEdit(g, newImports))
+	}
+
+	pruneCandidates := map[string]bool{}
+	for _, call := range staleCalls {
+		name := fmt.Sprintf("%s.%s", g.paths[call.Path], call.Func)
+		if prune == nil || !prune(call.Path) {
+			result.Stale = append(result.Stale, name)
+			continue
+		}
+		result.Pruned = append(result.Pruned, name)
+		pruneCandidates[call.Path] = true
+
+		start, end := g.lineRange(call.Stmt)
+		edits = append(edits, textEdit{Start: start, End: end})
+		if variable := argIdent(call.Stmt, 1); variable != "" {
+			if stmt, ok := assigned[variable]; ok {
+				if path, ok := constructorPath(g, stmt); ok {
+					pruneCandidates[path] = true
+				}
+				start, end := g.lineRange(stmt)
+				edits = append(edits, textEdit{Start: start, End: end})
+			}
+		}
+		if serverStruct != nil {
+			for _, field := range serverStruct.Fields.List {
+				path, name, ok := g.qualifiedRef(field.Type)
+				if ok && len(field.Names) == 0 && path == call.Path && name == "Unimplemented"+call.Service+"Server" {
+					start, end := g.lineRange(field)
+					edits = append(edits, textEdit{Start: start, End: end})
+				}
+			}
+		}
+	}
+
+	if len(edits) == 0 {
+		return src, result, nil
+	}
+
+	updated, err := format.Source(applyEdits(src, edits))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update %s: %w", filename, err)
+	}
+	if len(pruneCandidates) > 0 {
+		if updated, err = removeUnusedImports(filename, updated, pruneCandidates); err != nil {
+			return nil, nil, err
+		}
+	}
+	return updated, result, nil
+}
+
+// wireArgs returns the arguments of a service constructor called in
+// Server.Run: the Server field of the type of each parameter. A Querier
+// parameter takes the Store field of its package.
+func wireArgs(g *goSource, serverStruct *ast.StructType, serverPath, receiver string, reg ServiceRegistration) (string, error) {
+	if len(reg.Params) == 0 {
+		return "", nil
+	}
+	if serverStruct == nil || receiver == "" {
+		return "", fmt.Errorf("no Server struct and receiver to pass the parameters of %s from", reg.Constructor)
+	}
+
+	type serverField struct {
+		Name string
+		Type goTypeRef
+	}
+	var fields []serverField
+	for _, field := range serverStruct.Fields.List {
+		ref, ok := g.typeRef(field.Type, serverPath)
+		if !ok {
+			continue
+		}
+		for _, name := range field.Names {
+			fields = append(fields, serverField{Name: name.Name, Type: ref})
+		}
+	}
+
+	var args []string
+	for _, param := range reg.Params {
+		var matches []serverField
+		for _, field := range fields {
+			if field.Type == param.Type {
+				matches = append(matches, field)
+			}
+		}
+		if len(matches) == 0 && param.Type.Name == "Querier" && !param.Type.Pointer {
+			store := goTypeRef{Path: param.Type.Path, Name: "Store", Pointer: true}
+			for _, field := range fields {
+				if field.Type == store {
+					matches = append(matches, field)
+				}
+			}
+		}
+		if len(matches) > 1 {
+			for _, field := range matches {
+				if field.Name == param.Name {
+					matches = []serverField{field}
+					break
+				}
+			}
+		}
+		if len(matches) != 1 {
+			return "", fmt.Errorf("parameter %s %s of %s matches %d Server fields, add one of that type to the Server struct", param.Name, param.Type, reg.Constructor, len(matches))
+		}
+		args = append(args, receiver+"."+matches[0].Name)
+	}
+	return strings.Join(args, ", "), nil
+}
+
+// removeUnusedImports drops the candidate imports the file no longer references
+func removeUnusedImports(filename string, src []byte, candidates map[string]bool) ([]byte, error) {
+	g, err := parseGoSource(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	var edits []textEdit
+	for _, spec := range g.file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if candidates[importPath] && !g.usesName(g.paths[importPath]) {
+			start, end := g.lineRange(spec)
+			edits = append(edits, textEdit{Start: start, End: end})
+		}
+	}
+	if len(edits) == 0 {
+		return src, nil
+	}
+	return format.Source(applyEdits(src, edits))
+}
+
+// importEdit adds imports to the last import block, or adds a new block
+func importEdit(g *goSource, imports map[string]string) textEdit {
+	paths := make([]string, 0, len(imports))
+	for importPath := range imports {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+
+	var lines strings.Builder
+	for _, importPath := range paths {
+		fmt.Fprintf(&lines, "\t%s %q\n", imports[importPath], importPath)
+	}
+
+	var lastImport *ast.GenDecl
+	for _, decl := range g.file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			lastImport = gen
+		}
+	}
+	if lastImport != nil && lastImport.Rparen.IsValid() {
+		at := g.offset(lastImport.Rparen)
+		return textEdit{Start: at, End: at, Text: lines.String()}
+	}
+
+	at := g.offset(g.file.Name.End())
+	if lastImport != nil {
+		at = g.offset(lastImport.End())
+	}
+	return textEdit{Start: at, End: at, Text: "\n\nimport (\n" + lines.String() + ")"}
+}
+
+func findServerMethod(file *ast.File, name string) *ast.FuncDecl {
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != name || fn.Recv == nil || len(fn.Recv.List) == 0 {
+			continue
+		}
+		receiver := fn.Recv.List[0].Type
+		if star, ok := receiver.(*ast.StarExpr); ok {
+			receiver = star.X
+		}
+		if ident, ok := receiver.(*ast.Ident); ok && ident.Name == "Server" {
+			return fn
+		}
+	}
+	return nil
+}
+
+func findServerStruct(file *ast.File) *ast.StructType {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			if structType, ok := typeSpec.Type.(*ast.StructType); ok && typeSpec.Name.Name == "Server" {
+				return structType
+			}
+		}
+	}
+	return nil
+}
+
+// findGRPCServer returns the statement creating the grpc.Server and its variable name
+func findGRPCServer(g *goSource, body *ast.BlockStmt) (ast.Stmt, string) {
+	for _, stmt := range body.List {
+		assign, ok := stmt.(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+			continue
+		}
+		call, ok := assign.Rhs[0].(*ast.CallExpr)
+		if !ok {
+			continue
+		}
+		if path, name, ok := g.qualifiedRef(call.Fun); ok && path == "google.golang.org/grpc" && name == "NewServer" {
+			if ident, ok := assign.Lhs[0].(*ast.Ident); ok {
+				return stmt, ident.Name
+			}
+		}
+	}
+	return nil, ""
+}
+
+func findRegisterCalls(g *goSource, body *ast.BlockStmt, serverVar string) []registerCall {
+	var calls []registerCall
+	for _, stmt := range body.List {
+		exprStmt, ok := stmt.(*ast.ExprStmt)
+		if !ok {
+			continue
+		}
+		call, ok := exprStmt.X.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			continue
+		}
+		path, name, ok := g.qualifiedRef(call.Fun)
+		if !ok || !strings.HasPrefix(name, "Register") || !strings.HasSuffix(name, "Server") {
+			continue
+		}
+		if ident, ok := call.Args[0].(*ast.Ident); !ok || ident.Name != serverVar {
+			continue
+		}
+		calls = append(calls, registerCall{
+			Stmt:    stmt,
+			Path:    path,
+			Func:    name,
+			Service: strings.TrimSuffix(strings.TrimPrefix(name, "Register"), "Server"),
+		})
+	}
+	return calls
+}
+
+func hasRegisterCall(calls []registerCall, importPath, name string) bool {
+	for _, call := range calls {
+		if call.Path == importPath && call.Func == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasEmbeddedField(g *goSource, structType *ast.StructType, importPath, name string) bool {
+	for _, field := range structType.Fields.List {
+		if fieldPath, fieldName, ok := g.qualifiedRef(field.Type); ok && len(field.Names) == 0 && fieldPath == importPath && fieldName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// latestStmt returns the statement ending last, skipping nil ones
+func latestStmt(stmts ...ast.Stmt) ast.Stmt {
+	var latest ast.Stmt
+	for _, stmt := range stmts {
+		if stmt != nil && (latest == nil || stmt.End() > latest.End()) {
+			latest = stmt
+		}
+	}
+	return latest
+}
+
+// argIdent returns the identifier passed as argument i of a call statement
+func argIdent(stmt ast.Stmt, i int) string {
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return ""
+	}
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok || len(call.Args) <= i {
+		return ""
+	}
+	if ident, ok := call.Args[i].(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// constructorPath returns the import path of the constructor called in v := pkg.NewX()
+func constructorPath(g *goSource, stmt ast.Stmt) (string, bool) {
+	assign, ok := stmt.(*ast.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 {
+		return "", false
+	}
+	call, ok := assign.Rhs[0].(*ast.CallExpr)
+	if !ok {
+		return "", false
+	}
+	path, _, ok := g.qualifiedRef(call.Fun)
+	return path, ok
+}
+
+// constructorCall returns the call of the constructor of a registration
+// assigned by stmt, or nil when stmt assigns something else
+func constructorCall(g *goSource, stmt ast.Stmt, reg ServiceRegistration) *ast.CallExpr {
+	assign, ok := stmt.(*ast.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 {
+		return nil
+	}
+	call, ok := assign.Rhs[0].(*ast.CallExpr)
+	if !ok || call.Ellipsis.IsValid() {
+		return nil
+	}
+	_, constructor := splitQualified(reg.Constructor)
+	if path, name, ok := g.qualifiedRef(call.Fun); !ok || path != reg.HandlerImport.Path || name != constructor {
+		return nil
+	}
+	return call
+}
+
+func isWithinImportPath(importPath, root string) bool {
+	return importPath == root || strings.HasPrefix(importPath, root+"/")
+}
@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testRegistration returns the registration of the service of a module of
+// the example.com/app test project
+func testRegistration(module, service string) ServiceRegistration {
+	return ServiceRegistration{
+		ModuleName:    module,
+		ServiceName:   service,
+		HandlerImport: GoImport{Alias: module, Path: "example.com/app/app/rpc/" + module},
+		PbImport:      GoImport{Alias: module + "pb", Path: "example.com/app/protogen/" + module},
+		ServiceVar:    lowerFirst(service),
+		Constructor:   module + ".New" + service,
+		RegisterFunc:  module + "pb.Register" + service + "Server",
+		Unimplemented: module + "pb.Unimplemented" + service + "Server",
+		Params: []constructorParam{
+			{Name: "store", Type: goTypeRef{Path: "example.com/app/internal/repo", Name: "Querier"}},
+			{Name: "logger", Type: goTypeRef{Path: "github.com/sirupsen/logrus", Name: "Logger", Pointer: true}},
+		},
+	}
+}
+
+// withConfig adds a config parameter to the constructor of reg, as a
+// developer would by hand
+func withConfig(reg ServiceRegistration) ServiceRegistration {
+	reg.Params = append(reg.Params, constructorParam{
+		Name: "cfg",
+		Type: goTypeRef{Path: "example.com/app/internal/config", Name: "Config", Pointer: true},
+	})
+	return reg
+}
+
+func lowerFirst(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// testServer returns a server.go importing the standard packages of the
+// template and extra, followed by decls
+func testServer(extra []string, decls string) string {
+	imports := append([]string{
+		`"github.com/sirupsen/logrus"`,
+		`"google.golang.org/grpc"`,
+		`db "example.com/app/internal/repo"`,
+	}, extra...)
+	importPath := func(spec string) string { return spec[strings.Index(spec, `"`):] }
+	sort.Slice(imports, func(i, j int) bool { return importPath(imports[i]) < importPath(imports[j]) })
+	return "package rpc\n\nimport (\n\t\"net\"\n\n\t" + strings.Join(imports, "\n\t") + "\n)\n" + decls
+}
+
+func TestUpdateServerFile(t *testing.T) {
+	courses := testRegistration("courses", "CourseService")
+	tests := []struct {
+		name          string
+		src           string
+		registrations []ServiceRegistration
+		prune         bool
+		want          string
+		result        serverEditResult
+		wantErr       string
+	}{
+		{
+			name: "first registration",
+			src: testServer(nil, `
+type Server struct {
+	store  *db.Store
+	logger *logrus.Logger
+}
+
+func (s *Server) Run() error {
+	listener, _ := net.Listen("tcp", ":9001")
+	grpcServer := grpc.NewServer()
+
+	return grpcServer.Serve(listener)
+}
+`),
+			registrations: []ServiceRegistration{courses},
+			want: testServer([]string{`courses "example.com/app/app/rpc/courses"`, `coursespb "example.com/app/protogen/courses"`}, `
+type Server struct {
+	coursespb.UnimplementedCourseServiceServer
+	store  *db.Store
+	logger *logrus.Logger
+}
+
+func (s *Server) Run() error {
+	listener, _ := net.Listen("tcp", ":9001")
+	grpcServer := grpc.NewServer()
+	courseService := courses.NewCourseService(s.store, s.logger)
+
+	// Register services with gRPC server
+	coursespb.RegisterCourseServiceServer(grpcServer, courseService)
+
+	return grpcServer.Serve(listener)
+}
+`),
+			result: serverEditResult{Added: []string{"coursespb.RegisterCourseServiceServer"}},
+		},
+		{
+			name: "hand-added config parameter",
+			src: testServer([]string{`"example.com/app/internal/config"`}, `
+type Server struct {
+	store  *db.Store
+	logger *logrus.Logger
+	config *config.Config
+}
+
+func (s *Server) Run() error {
+	grpcServer := grpc.NewServer()
+	return nil
+}
+`),
+			registrations: []ServiceRegistration{withConfig(courses)},
+			want: testServer([]string{`"example.com/app/internal/config"`, `courses "example.com/app/app/rpc/courses"`, `coursespb "example.com/app/protogen/courses"`}, `
+type Server struct {
+	coursespb.UnimplementedCourseServiceServer
+	store  *db.Store
+	logger *logrus.Logger
+	config *config.Config
+}
+
+func (s *Server) Run() error {
+	grpcServer := grpc.NewServer()
+	courseService := courses.NewCourseService(s.store, s.logger, s.config)
+
+	// Register services with gRPC server
+	coursespb.RegisterCourseServiceServer(grpcServer, courseService)
+	return nil
+}
+`),
+			result: serverEditResult{Added: []string{"coursespb.RegisterCourseServiceServer"}},
+		},
+		{
+			name: "hand-written service variable",
+			src: testServer([]string{`courses "example.com/app/app/rpc/courses"`}, `
+type Server struct {
+	store  *db.Store
+	logger *logrus.Logger
+}
+
+func (s *Server) Run() error {
+	listener, _ := net.Listen("tcp", ":9001")
+	grpcServer := grpc.NewServer()
+	s.logger.Println("wiring")
+	courseService := courses.NewCourseService(s.store, s.logger)
+
+	return grpcServer.Serve(listener)
+}
+`),
+			registrations: []ServiceRegistration{courses},
+			want: testServer([]string{`courses "example.com/app/app/rpc/courses"`, `coursespb "example.com/app/protogen/courses"`}, `
+type Server struct {
+	coursespb.UnimplementedCourseServiceServer
+	store  *db.Store
+	logger *logrus.Logger
+}
+
+func (s *Server) Run() error {
+	listener, _ := net.Listen("tcp", ":9001")
+	grpcServer := grpc.NewServer()
+	s.logger.Println("wiring")
+	courseService := courses.NewCourseService(s.store, s.logger)
+
+	// Register services with gRPC server
+	coursespb.RegisterCourseServiceServer(grpcServer, courseService)
+
+	return grpcServer.Serve(listener)
+}
+`),
+			result: serverEditResult{Added: []string{"coursespb.RegisterCourseServiceServer"}},
+		},
+		{
+			name: "after existing registrations with an alias collision",
+			src: testServer([]string{`courses "example.com/other/courses"`, `userspb "example.com/app/protogen/users"`, `"example.com/app/app/rpc/users"`}, `
+type Server struct {
+	userspb.UnimplementedUserServiceServer
+	store  *db.Store
+	logger *logrus.Logger
+}
+
+func (s *Server) Run() error {
+	listener, _ := net.Listen("tcp", ":9001")
+	grpcServer := grpc.NewServer()
+	userService := users.NewUserService(s.store, s.logger)
+
+	userspb.RegisterUserServiceServer(grpcServer, userService)
+	courses.Audit(grpcServer)
+
+	return grpcServer.Serve(listener)
+}
+`),
+			registrations: []ServiceRegistration{testRegistration("users", "UserService"), courses},
+			want: testServer([]string{`courses "example.com/other/courses"`, `userspb "example.com/app/protogen/users"`, `"example.com/app/app/rpc/users"`, `courses2 "example.com/app/app/rpc/courses"`, `coursespb "example.com/app/protogen/courses"`}, `
+type Server struct {
+	userspb.UnimplementedUserServiceServer
+	coursespb.UnimplementedCourseServiceServer
+	store  *db.Store
+	logger *logrus.Logger
+}
+
+func (s *Server) Run() error {
+	listener, _ := net.Listen("tcp", ":9001")
+	grpcServer := grpc.NewServer()
+	userService := users.NewUserService(s.store, s.logger)
+	courseService := courses2.NewCourseService(s.store, s.logger)
+
+	userspb.RegisterUserServiceServer(grpcServer, userService)
+	coursespb.RegisterCourseServiceServer(grpcServer, courseService)
+	courses.Audit(grpcServer)
+
+	return grpcServer.Serve(listener)
+}
+`),
+			result: serverEditResult{Added: []string{"coursespb.RegisterCourseServiceServer"}},
+		},
+		{
+			name: "rewire a constructor whose parameters changed",
+			src: testServer([]string{`courses "example.com/app/app/rpc/courses"`, `coursespb "example.com/app/protogen/courses"`}, `
+type Server struct {
+	coursespb.UnimplementedCourseServiceServer
+	store  *db.Store
+	logger *logrus.Logger
+}
+
+func (s *Server) Run() error {
+	grpcServer := grpc.NewServer()
+	courseService := courses.NewCourseService()
+	coursespb.RegisterCourseServiceServer(grpcServer, courseService)
+	return nil
+}
+`),
+			registrations: []ServiceRegistration{courses},
+			want: testServer([]string{`courses "example.com/app/app/rpc/courses"`, `coursespb "example.com/app/protogen/courses"`}, `
+type Server struct {
+	coursespb.UnimplementedCourseServiceServer
+	store  *db.Store
+	logger *logrus.Logger
+}
+
+func (s *Server) Run() error {
+	grpcServer := grpc.NewServer()
+	courseService := courses.NewCourseService(s.store, s.logger)
+	coursespb.RegisterCourseServiceServer(grpcServer, courseService)
+	return nil
+}
+`),
+			result: serverEditResult{Rewired: []string{"courses.NewCourseService"}},
+		},
+		{
+			name: "stale registration is reported",
+			src: testServer([]string{`userspb "example.com/app/protogen/users"`, `"example.com/app/app/rpc/users"`}, `
+type Server struct {
+	userspb.UnimplementedUserServiceServer
+	store *db.Store
+}
+
+func (s *Server) Run() error {
+	grpcServer := grpc.NewServer()
+	userService := users.NewUserService(s.store)
+	userspb.RegisterUserServiceServer(grpcServer, userService)
+	return nil
+}
+`),
+			result: serverEditResult{Stale: []string{"userspb.RegisterUserServiceServer"}},
+		},
+		{
+			name:  "stale registration is pruned",
+			prune: true,
+			src: testServer([]string{`userspb "example.com/app/protogen/users"`, `"example.com/app/app/rpc/users"`}, `
+type Server struct {
+	userspb.UnimplementedUserServiceServer
+	store *db.Store
+}
+
+func (s *Server) Run() error {
+	grpcServer := grpc.NewServer()
+	userService := users.NewUserService(s.store)
+	userspb.RegisterUserServiceServer(grpcServer, userService)
+	return nil
+}
+`),
+			want: testServer(nil, `
+type Server struct {
+	store *db.Store
+}
+
+func (s *Server) Run() error {
+	grpcServer := grpc.NewServer()
+	return nil
+}
+`),
+			result: serverEditResult{Pruned: []string{"userspb.RegisterUserServiceServer"}},
+		},
+		{
+			name: "parameter without a server field",
+			src: testServer(nil, `
+type Server struct {
+	store *db.Store
+}
+
+func (s *Server) Run() error {
+	grpcServer := grpc.NewServer()
+	return nil
+}
+`),
+			registrations: []ServiceRegistration{courses},
+			wantErr:       "parameter logger *logrus.Logger of courses.NewCourseService matches 0 Server fields",
+		},
+		{
+			name:    "no grpc server",
+			src:     "package rpc\n\ntype Server struct{}\n\nfunc (s *Server) Run() error {\n\treturn nil\n}\n",
+			wantErr: "no grpc.NewServer() call found in Server.Run",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prune := func(string) bool { return tt.prune }
+			got, result, err := updateServerFile("server.go", []byte(tt.src), defaultManifest("example.com/app"), tt.registrations, prune)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("updateServerFile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("updateServerFile() error = %v", err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.src
+			}
+			if string(got) != want {
+				t.Errorf("updateServerFile() =\n%s\nwant\n%s", got, want)
+			}
+			if !reflect.DeepEqual(*result, tt.result) {
+				t.Errorf("result = %+v, want %+v", *result, tt.result)
+			}
+		})
+	}
+}
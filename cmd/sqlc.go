@@ -3,6 +3,7 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
 
 	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
@@ -28,20 +29,35 @@ var sqlcGenerateCmd = &cobra.Command{
 }
 
 func runSQLcGenerate() error {
-	cmd := exec.Command("sqlc", "generate")
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("sqlc generate failed: %w\n%s", err, stderr.String())
-	}
-	err := exec.Command("go", "mod", "tidy").Run()
+	manifest, err := loadManifest()
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+	if !manifest.Features.SQLC {
+		return fmt.Errorf("the sqlc feature is disabled in %s", manifestFileName)
+	}
+	for _, dir := range []string{manifest.Layout.Schema, manifest.Layout.Queries} {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return fmt.Errorf("sqlc input directory not found at %s", dir)
+		}
 	}
-	pkg.InfoLog(stdout.String())
-	return nil
+
+	preferProjectTools()
+	return runExternal("sqlc generate", func() error {
+		cmd := exec.Command("sqlc", "generate")
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("sqlc generate failed: %w\n%s", err, stderr.String())
+		}
+		if err := exec.Command("go", "mod", "tidy").Run(); err != nil {
+			return err
+		}
+		pkg.InfoLog(stdout.String())
+		return nil
+	})
 }
 
 func init() {
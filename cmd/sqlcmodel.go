@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sqlcConfigFiles are the config file names sqlc looks for, in order
+var sqlcConfigFiles = []string{"sqlc.yaml", "sqlc.yml"}
+
+// sqlcConfig is the part of sqlc.yaml that decides the generated Go types
+type sqlcConfig struct {
+	SQL []struct {
+		Gen struct {
+			Go struct {
+				Package    string `yaml:"package"`
+				Out        string `yaml:"out"`
+				SQLPackage string `yaml:"sql_package"`
+			} `yaml:"go"`
+		} `yaml:"gen"`
+	} `yaml:"sql"`
+}
+
+// sqlcModel predicts the names sqlc generates for a schema
+type sqlcModel struct {
+	Schema *SQLSchema
+	// Package is the name of the generated package, which qualifies the
+	// model and enum types
+	Package string
+	// Driver is the sql_package of sqlc.yaml, database/sql or pgx/v5
+	Driver string
+	// EnumPackage is the proto package of the enums generated from the
+	// schema
+	EnumPackage string
+}
+
+// loadSQLCModel reads the schema and the sqlc settings of the project
+func loadSQLCModel(manifest *Manifest) (*sqlcModel, error) {
+	schema, err := loadSQLSchema(manifest.Layout.Schema)
+	if err != nil {
+		return nil, err
+	}
+	model := &sqlcModel{
+		Schema:      schema,
+		Package:     manifest.Database.Package,
+		Driver:      "database/sql",
+		EnumPackage: manifest.ProtoPackage(enumsModule),
+	}
+
+	for _, name := range sqlcConfigFiles {
+		content, err := readFile(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		var config sqlcConfig
+		if err := yaml.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		if len(config.SQL) > 0 {
+			if sqlPackage := config.SQL[0].Gen.Go.SQLPackage; sqlPackage != "" {
+				model.Driver = sqlPackage
+			}
+		}
+		break
+	}
+	return model, nil
+}
+
+// IsPgx reports whether sqlc generates pgx types
+func (m *sqlcModel) IsPgx() bool {
+	return strings.HasPrefix(m.Driver, "pgx/")
+}
+
+// StructName returns the name sqlc gives the model of a table: the
+// singular of the table name in CamelCase, with id spelled ID
+func (m *sqlcModel) StructName(name string) string {
+	return sqlcName(singularize(name))
+}
+
+// EnumName returns the name sqlc gives the type of an enum
+func (m *sqlcModel) EnumName(name string) string {
+	return sqlcName(name)
+}
+
+// FieldName returns the Go name of a column in models and params
+func (m *sqlcModel) FieldName(column string) string {
+	return sqlcName(column)
+}
+
+// sqlcName converts a snake case name to CamelCase the way sqlc does. Like
+// sqlc it keeps the case of quoted names such as "CreatedAt" and treats
+// any other character than a letter or digit as an underscore.
+func sqlcName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "id" {
+			b.WriteString("ID")
+		} else if first, size := utf8.DecodeRuneInString(part); part != "" {
+			b.WriteRune(unicode.ToUpper(first))
+			b.WriteString(part[size:])
+		}
+	}
+	return b.String()
+}
+
+// ProtoType returns the proto field type of a column
+func (m *sqlcModel) ProtoType(column *SQLColumn) string {
+	protoType := "string"
+	switch column.Type {
+	case "int2", "int4", "serial2", "serial4":
+		protoType = "int32"
+	case "int8", "serial8":
+		protoType = "int64"
+	case "bool":
+		protoType = "bool"
+	case "float4":
+		protoType = "float"
+	case "float8":
+		protoType = "double"
+	case "timestamp", "timestamptz", "date":
+		protoType = "google.protobuf.Timestamp"
+	case "bytea":
+		protoType = "bytes"
+	case "json", "jsonb":
+		// a Value holds objects, arrays and scalars alike
+		protoType = "google.protobuf.Value"
+	case "interval":
+		// database/sql reads intervals as strings
+		if m.Driver == "pgx/v5" {
+			protoType = "google.protobuf.Duration"
+		}
+	}
+	if enum := m.Schema.Enum(column.Type); enum != nil {
+		protoType = m.EnumPackage + "." + m.EnumName(enum.Name)
+	}
+	if column.Array {
+		return "repeated " + protoType
+	}
+	return protoType
+}
+
+// HasDefault reports whether inserts may leave the column out
+func (c *SQLColumn) HasDefault() bool {
+	switch c.Type {
+	case "serial2", "serial4", "serial8":
+		return true
+	}
+	return c.Default != "" || c.Generated
+}
+
+// goTypeImports are the import paths of the packages used by conversions
+var goTypeImports = map[string]string{
+	"uuid":      "github.com/google/uuid",
+	"sql":       "database/sql",
+	"json":      "encoding/json",
+	"pqtype":    "github.com/sqlc-dev/pqtype",
+	"pgtype":    "github.com/jackc/pgx/v5/pgtype",
+	"timestamp": "google.golang.org/protobuf/types/known/timestamppb",
+	"strconv":   "strconv",
+}
+
+// fieldConversion converts a model field to a proto field and back. The
+// expressions are format strings taking the source value as %[1]s.
+type fieldConversion struct {
+	// ToProto is the proto value of the model field
+	ToProto string
+	// Valid guards ToProto for nullable model fields
+	Valid string
+	// ToModel is the model value of the proto field, a (value, error) pair
+	// when Fallible is set
+	ToModel  string
+	Fallible bool
+	// Status is set when the conversion fails with a gRPC status error,
+	// which is returned as is
+	Status bool
+	// Present and Wrap build a nullable model field: when the proto value
+	// is Present, the converted value is wrapped, otherwise the field keeps
+	// its NULL zero value
+	Present string
+	Wrap    string
+	Imports []string
+	// Helpers are the functions of convertHelpers the expressions call
+	Helpers []string
+}
+
+// knownConversions maps "<go type> <proto type>" to its conversion
+var knownConversions = map[string]*fieldConversion{
+	"string string":            {ToProto: "%[1]s", ToModel: "%[1]s"},
+	"int32 int32":              {ToProto: "%[1]s", ToModel: "%[1]s"},
+	"int64 int64":              {ToProto: "%[1]s", ToModel: "%[1]s"},
+	"bool bool":                {ToProto: "%[1]s", ToModel: "%[1]s"},
+	"float32 float":            {ToProto: "%[1]s", ToModel: "%[1]s"},
+	"float64 double":           {ToProto: "%[1]s", ToModel: "%[1]s"},
+	"[]byte bytes":             {ToProto: "%[1]s", ToModel: "%[1]s"},
+	"int16 int32":              {ToProto: "int32(%[1]s)", ToModel: "int16(%[1]s)"},
+	"[]byte string":            {ToProto: "string(%[1]s)", ToModel: "[]byte(%[1]s)"},
+	"[]string repeated string": {ToProto: "%[1]s", ToModel: "%[1]s"},
+
+	// database/sql
+	"uuid.UUID string": {ToProto: "%[1]s.String()", ToModel: "uuid.Parse(%[1]s)", Fallible: true, Imports: []string{"uuid"}},
+	"uuid.NullUUID string": {
+		ToProto: "%[1]s.UUID.String()", Valid: "%[1]s.Valid",
+		Present: `%[1]s != ""`, ToModel: "uuid.Parse(%[1]s)", Fallible: true, Wrap: "uuid.NullUUID{UUID: %[1]s, Valid: true}",
+		Imports: []string{"uuid"},
+	},
+	"sql.NullString string":  {ToProto: "%[1]s.String", ToModel: `sql.NullString{String: %[1]s, Valid: %[1]s != ""}`, Imports: []string{"sql"}},
+	"sql.NullInt16 int32":    {ToProto: "int32(%[1]s.Int16)", ToModel: "sql.NullInt16{Int16: int16(%[1]s), Valid: true}", Imports: []string{"sql"}},
+	"sql.NullInt32 int32":    {ToProto: "%[1]s.Int32", ToModel: "sql.NullInt32{Int32: %[1]s, Valid: true}", Imports: []string{"sql"}},
+	"sql.NullInt64 int64":    {ToProto: "%[1]s.Int64", ToModel: "sql.NullInt64{Int64: %[1]s, Valid: true}", Imports: []string{"sql"}},
+	"sql.NullBool bool":      {ToProto: "%[1]s.Bool", ToModel: "sql.NullBool{Bool: %[1]s, Valid: true}", Imports: []string{"sql"}},
+	"sql.NullFloat64 float":  {ToProto: "float32(%[1]s.Float64)", ToModel: "sql.NullFloat64{Float64: float64(%[1]s), Valid: true}", Imports: []string{"sql"}},
+	"sql.NullFloat64 double": {ToProto: "%[1]s.Float64", ToModel: "sql.NullFloat64{Float64: %[1]s, Valid: true}", Imports: []string{"sql"}},
+	"time.Time google.protobuf.Timestamp": {
+		ToProto: "timestamppb.New(%[1]s)", ToModel: "%[1]s.AsTime()", Imports: []string{"timestamp"},
+	},
+	"sql.NullTime google.protobuf.Timestamp": {
+		ToProto: "timestamppb.New(%[1]s.Time)", Valid: "%[1]s.Valid",
+		Present: "%[1]s != nil", ToModel: "%[1]s.AsTime()", Wrap: "sql.NullTime{Time: %[1]s, Valid: true}",
+		Imports: []string{"sql", "timestamp"},
+	},
+	"json.RawMessage string": {ToProto: "string(%[1]s)", ToModel: "json.RawMessage(%[1]s)", Imports: []string{"json"}},
+	"pqtype.NullRawMessage string": {
+		ToProto: "string(%[1]s.RawMessage)",
+		Present: `%[1]s != ""`, ToModel: "json.RawMessage(%[1]s)", Wrap: "pqtype.NullRawMessage{RawMessage: %[1]s, Valid: true}",
+		Imports: []string{"json", "pqtype"},
+	},
+
+	// pgx/v5
+	"pgtype.UUID string": {
+		ToProto: "uuid.UUID(%[1]s.Bytes).String()", Valid: "%[1]s.Valid",
+		Present: `%[1]s != ""`, ToModel: "uuid.Parse(%[1]s)", Fallible: true, Wrap: "pgtype.UUID{Bytes: %[1]s, Valid: true}",
+		Imports: []string{"uuid", "pgtype"},
+	},
+	"pgtype.Text string":   {ToProto: "%[1]s.String", ToModel: `pgtype.Text{String: %[1]s, Valid: %[1]s != ""}`, Imports: []string{"pgtype"}},
+	"pgtype.Int2 int32":    {ToProto: "int32(%[1]s.Int16)", ToModel: "pgtype.Int2{Int16: int16(%[1]s), Valid: true}", Imports: []string{"pgtype"}},
+	"pgtype.Int4 int32":    {ToProto: "%[1]s.Int32", ToModel: "pgtype.Int4{Int32: %[1]s, Valid: true}", Imports: []string{"pgtype"}},
+	"pgtype.Int8 int64":    {ToProto: "%[1]s.Int64", ToModel: "pgtype.Int8{Int64: %[1]s, Valid: true}", Imports: []string{"pgtype"}},
+	"pgtype.Bool bool":     {ToProto: "%[1]s.Bool", ToModel: "pgtype.Bool{Bool: %[1]s, Valid: true}", Imports: []string{"pgtype"}},
+	"pgtype.Float4 float":  {ToProto: "%[1]s.Float32", ToModel: "pgtype.Float4{Float32: %[1]s, Valid: true}", Imports: []string{"pgtype"}},
+	"pgtype.Float8 double": {ToProto: "%[1]s.Float64", ToModel: "pgtype.Float8{Float64: %[1]s, Valid: true}", Imports: []string{"pgtype"}},
+	"pgtype.Timestamp google.protobuf.Timestamp": {
+		ToProto: "timestamppb.New(%[1]s.Time)", Valid: "%[1]s.Valid",
+		Present: "%[1]s != nil", ToModel: "%[1]s.AsTime()", Wrap: "pgtype.Timestamp{Time: %[1]s, Valid: true}",
+		Imports: []string{"pgtype", "timestamp"},
+	},
+	"pgtype.Timestamptz google.protobuf.Timestamp": {
+		ToProto: "timestamppb.New(%[1]s.Time)", Valid: "%[1]s.Valid",
+		Present: "%[1]s != nil", ToModel: "%[1]s.AsTime()", Wrap: "pgtype.Timestamptz{Time: %[1]s, Valid: true}",
+		Imports: []string{"pgtype", "timestamp"},
+	},
+	"pgtype.Date google.protobuf.Timestamp": {
+		ToProto: "timestamppb.New(%[1]s.Time)", Valid: "%[1]s.Valid",
+		Present: "%[1]s != nil", ToModel: "%[1]s.AsTime()", Wrap: "pgtype.Date{Time: %[1]s, Valid: true}",
+		Imports: []string{"pgtype", "timestamp"},
+	},
+	"pgtype.Numeric string": {
+		ToProto: "numericToString(%[1]s)", ToModel: "stringToNumeric(%[1]s)", Fallible: true,
+		Imports: []string{"pgtype"}, Helpers: []string{"numericToString", "stringToNumeric"},
+	},
+	"pgtype.Numeric double": {
+		ToProto: "numericToFloat64(%[1]s)", ToModel: "float64ToNumeric(%[1]s)", Fallible: true,
+		Imports: []string{"pgtype", "strconv"}, Helpers: []string{"numericToFloat64", "float64ToNumeric"},
+	},
+}
+
+// convertorConversions are the conversions calling the convertor library.
+// They are preferred over knownConversions when the convertor package
+// declares the functions.
+var convertorConversions = map[string]*fieldConversion{
+	"pgtype.UUID string":                           convertorConversion("ConvertUUIDToString", "ConvertStringToUUID", true),
+	"uuid.NullUUID string":                         convertorConversion("ConvertNullUUIDToString", "ConvertStringToNullUUID", true),
+	"pgtype.Text string":                           convertorConversion("ConvertTextToString", "ConvertStringToText", false),
+	"pgtype.Text google.protobuf.StringValue":      convertorConversion("ConvertTextToStringValue", "ConvertStringValueToText", false),
+	"pgtype.Text optional string":                  convertorConversion("ConvertTextToStringPtr", "ConvertStringPtrToText", false),
+	"pgtype.Int4 int32":                            convertorConversion("ConvertInt4ToInt32", "ConvertInt32ToInt4", false),
+	"pgtype.Int4 google.protobuf.Int32Value":       convertorConversion("ConvertInt4ToInt32Value", "ConvertInt32ValueToInt4", false),
+	"pgtype.Int4 optional int32":                   convertorConversion("ConvertInt4ToInt32Ptr", "ConvertInt32PtrToInt4", false),
+	"pgtype.Int8 int64":                            convertorConversion("ConvertInt8ToInt64", "ConvertInt64ToInt8", false),
+	"pgtype.Int8 google.protobuf.Int64Value":       convertorConversion("ConvertInt8ToInt64Value", "ConvertInt64ValueToInt8", false),
+	"pgtype.Int8 optional int64":                   convertorConversion("ConvertInt8ToInt64Ptr", "ConvertInt64PtrToInt8", false),
+	"pgtype.Bool bool":                             convertorConversion("ConvertPgBoolToBool", "ConvertBoolToPgBool", false),
+	"pgtype.Bool google.protobuf.BoolValue":        convertorConversion("ConvertPgBoolToBoolValue", "ConvertBoolValueToPgBool", false),
+	"pgtype.Bool optional bool":                    convertorConversion("ConvertPgBoolToBoolPtr", "ConvertBoolPtrToPgBool", false),
+	"pgtype.Float8 double":                         convertorConversion("ConvertFloat8ToFloat64", "ConvertFloat64ToFloat8", false),
+	"pgtype.Float8 google.protobuf.DoubleValue":    convertorConversion("ConvertFloat8ToDoubleValue", "ConvertDoubleValueToFloat8", false),
+	"pgtype.Float8 optional double":                convertorConversion("ConvertFloat8ToFloat64Ptr", "ConvertFloat64PtrToFloat8", false),
+	"pgtype.Numeric string":                        convertorConversion("ConvertNumericToString", "ConvertStringToNumeric", true),
+	"pgtype.Numeric double":                        convertorConversion("ConvertNumericToFloat64", "ConvertFloat64ToNumeric", true),
+	"pgtype.Numeric google.protobuf.StringValue":   convertorConversion("ConvertNumericToStringValue", "ConvertStringValueToNumeric", true),
+	"pgtype.Numeric google.protobuf.DoubleValue":   convertorConversion("ConvertNumericToDoubleValue", "ConvertDoubleValueToNumeric", true),
+	"pgtype.Date google.protobuf.Timestamp":        convertorConversion("ConvertDateToTimestamp", "ConvertTimestampToDate", false),
+	"pgtype.Timestamptz google.protobuf.Timestamp": convertorConversion("ConvertTimestamp", "ConvertTimestampToTimestamptz", false),
+	"pgtype.Timestamp google.protobuf.Timestamp":   convertorConversion("ConvertPgTimestampToTimestamp", "ConvertTimestampToPgTimestamp", false),
+	"pgtype.Interval google.protobuf.Duration":     convertorConversion("ConvertIntervalToDuration", "ConvertDurationToInterval", false),
+	"[]byte google.protobuf.Value":                 convertorConversion("ConvertJSONToValue", "ConvertValueToJSON", true),
+	"json.RawMessage google.protobuf.Value":        convertorConversion("ConvertJSONToValue", "ConvertValueToJSON", true),
+	"[]byte google.protobuf.Struct":                convertorConversion("ConvertJSONToStruct", "ConvertStructToJSON", true),
+	"json.RawMessage google.protobuf.Struct":       convertorConversion("ConvertJSONToStruct", "ConvertStructToJSON", true),
+}
+
+// convertorConversion calls a pair of convertor functions
+func convertorConversion(toProto, toModel string, fallible bool) *fieldConversion {
+	return &fieldConversion{
+		ToProto:  "convertor." + toProto + "(%[1]s)",
+		ToModel:  "convertor." + toModel + "(%[1]s)",
+		Fallible: fallible,
+		Imports:  []string{"convertor"},
+	}
+}
+
+// convertHelpers are the functions written to a converter for conversions
+// that do not fit in an expression
+var convertHelpers = map[string]string{
+	"numericToString": `// numericToString formats a numeric as decimal text, NULL as ""
+func numericToString(n pgtype.Numeric) string {
+	value, err := n.Value()
+	if text, ok := value.(string); ok && err == nil {
+		return text
+	}
+	return ""
+}`,
+	"stringToNumeric": `// stringToNumeric parses decimal text, "" as NULL
+func stringToNumeric(s string) (pgtype.Numeric, error) {
+	var n pgtype.Numeric
+	if s == "" {
+		return n, nil
+	}
+	if err := n.Scan(s); err != nil {
+		return n, err
+	}
+	return n, nil
+}`,
+	"numericToFloat64": `// numericToFloat64 returns a numeric as a float, NULL as 0
+func numericToFloat64(n pgtype.Numeric) float64 {
+	f, err := n.Float64Value()
+	if err != nil {
+		return 0
+	}
+	return f.Float64
+}`,
+	"float64ToNumeric": `// float64ToNumeric converts a float to a numeric
+func float64ToNumeric(f float64) (pgtype.Numeric, error) {
+	var n pgtype.Numeric
+	if err := n.Scan(strconv.FormatFloat(f, 'f', -1, 64)); err != nil {
+		return n, err
+	}
+	return n, nil
+}`,
+}
+
+// toProtoCode returns the assignment of a model value to a proto field:
+// an inline expression for a composite literal, or a statement for values
+// that are only set when valid
+func (c *fieldConversion) toProtoCode(dst, src string) (expr, stmt string) {
+	value := fmt.Sprintf(c.ToProto, src)
+	if c.Valid == "" {
+		return value, ""
+	}
+	return "", fmt.Sprintf("if %s {\n%s = %s\n}", fmt.Sprintf(c.Valid, src), dst, value)
+}
+
+// toModelCode returns the assignment of a proto value to a model field.
+// Fallible conversions assign through a variable err declared by the
+// caller and run onError when they fail.
+func (c *fieldConversion) toModelCode(dst, src, onError string) (expr, stmt string) {
+	value := fmt.Sprintf(c.ToModel, src)
+	switch {
+	case c.Wrap == "" && !c.Fallible:
+		return value, ""
+	case c.Wrap == "":
+		return "", fmt.Sprintf("if %s, err = %s; err != nil {\n%s\n}", dst, value, onError)
+	case c.Fallible:
+		return "", fmt.Sprintf("if %s {\nvalue, err := %s\nif err != nil {\n%s\n}\n%s = %s\n}",
+			fmt.Sprintf(c.Present, src), value, onError, dst, fmt.Sprintf(c.Wrap, "value"))
+	}
+	return "", fmt.Sprintf("if %s {\n%s = %s\n}", fmt.Sprintf(c.Present, src), dst, fmt.Sprintf(c.Wrap, value))
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// stateFileName records checksums of generated files
+	stateFileName = ".grpcframe/state.json"
+	stateVersion  = 1
+)
+
+// GeneratedState tracks the checksum of every file written by the
+// generators, so regeneration can tell untouched output apart from files
+// that were edited by hand
+type GeneratedState struct {
+	Version int                      `json:"version"`
+	Files   map[string]GeneratedFile `json:"files"`
+	// Modules records how modules were generated, so regeneration can
+	// rebuild them the same way
+	Modules map[string]GeneratedModule `json:"modules,omitempty"`
+}
+
+// GeneratedFile is the state of a single generated file
+type GeneratedFile struct {
+	Checksum string `json:"checksum"`
+	Template string `json:"template,omitempty"`
+}
+
+// GeneratedModule is the generation input of a module. Table is the
+// schema table of modules created by crud.
+type GeneratedModule struct {
+	Table string `json:"table,omitempty"`
+}
+
+// fileStatus describes a file on disk relative to its recorded checksum
+type fileStatus int
+
+const (
+	fileMissing fileStatus = iota
+	fileUnchanged
+	fileEdited
+)
+
+// writeResult reports what writeGenerated did with a file
+type writeResult int
+
+const (
+	writeCreated writeResult = iota
+	writeUpdated
+	writeUnchanged
+	writeSkipped
+)
+
+// loadGeneratedState reads the state file, returning an empty state when
+// the project has none yet
+func loadGeneratedState() (*GeneratedState, error) {
+	state := &GeneratedState{Version: stateVersion, Files: map[string]GeneratedFile{}, Modules: map[string]GeneratedModule{}}
+
+	content, err := readFile(stateFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", stateFileName, err)
+	}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", stateFileName, err)
+	}
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported %s version %d (expected %d)", stateFileName, state.Version, stateVersion)
+	}
+	if state.Files == nil {
+		state.Files = map[string]GeneratedFile{}
+	}
+	if state.Modules == nil {
+		state.Modules = map[string]GeneratedModule{}
+	}
+	return state, nil
+}
+
+// Save writes the state file
+func (s *GeneratedState) Save() error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	if err := makeDir(filepath.Dir(stateFileName)); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return writeFile(stateFileName, string(content)+"\n")
+}
+
+// Status compares a file on disk with its recorded checksum. Files that
+// exist but were never recorded count as edited.
+func (s *GeneratedState) Status(path string) (fileStatus, error) {
+	content, err := readFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fileMissing, nil
+	}
+	if err != nil {
+		return fileMissing, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	recorded, ok := s.Files[stateKey(path)]
+	if !ok || recorded.Checksum != checksum(content) {
+		return fileEdited, nil
+	}
+	return fileUnchanged, nil
+}
+
+// Record stores the checksum of generated content
+func (s *GeneratedState) Record(path, content, template string) {
+	s.Files[stateKey(path)] = GeneratedFile{Checksum: checksum([]byte(content)), Template: template}
+}
+
+// Forget drops a file from the state
+func (s *GeneratedState) Forget(path string) {
+	delete(s.Files, stateKey(path))
+}
+
+// ForgetDir drops every file below dir from the state
+func (s *GeneratedState) ForgetDir(dir string) {
+	prefix := stateKey(dir) + "/"
+	for key := range s.Files {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.Files, key)
+		}
+	}
+}
+
+// Move carries the state of a file over to its new path. Content that was
+// generated and rewritten by the move is recorded again, edited files stay
+// edited.
+func (s *GeneratedState) Move(from, to string, before, after []byte) {
+	recorded, ok := s.Files[stateKey(from)]
+	if !ok {
+		return
+	}
+	delete(s.Files, stateKey(from))
+	if recorded.Checksum == checksum(before) {
+		recorded.Checksum = checksum(after)
+	}
+	s.Files[stateKey(to)] = recorded
+}
+
+// writeGenerated writes generated content to path unless the file was
+// edited since it was generated. Existing files whose content already
+// matches are adopted into the state.
+func (s *GeneratedState) writeGenerated(path, content, template string) (writeResult, error) {
+	status, err := s.Status(path)
+	if err != nil {
+		return writeSkipped, err
+	}
+
+	if status != fileMissing {
+		existing, err := readFile(path)
+		if err != nil {
+			return writeSkipped, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if string(existing) == content {
+			s.Record(path, content, template)
+			return writeUnchanged, nil
+		}
+		if status == fileEdited {
+			return writeSkipped, nil
+		}
+	}
+
+	if err := writeFile(path, content); err != nil {
+		return writeSkipped, err
+	}
+	s.Record(path, content, template)
+	if status == fileMissing {
+		return writeCreated, nil
+	}
+	return writeUpdated, nil
+}
+
+func stateKey(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
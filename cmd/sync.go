@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncPrune bool
+	syncForce bool
+)
+
+var moduleSyncCmd = &cobra.Command{
+	Use:   "sync [module-name]",
+	Short: "Bring module handlers in line with the proto files",
+	Long: "Creates handlers for rpcs that have none and refreshes generated files that were not edited. " +
+		"Files edited since generation are never touched. Handlers whose rpc no longer exists are reported, " +
+		"and removed with --prune. Without a module name every module is synced",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runModuleSync(args); err != nil {
+			pkg.Red.Printf("Failed to sync modules: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// syncReport collects what a sync did to a module
+type syncReport struct {
+	Created   []string
+	Updated   []string
+	Kept      []string
+	Stale     []string
+	Pruned    []string
+	Unchanged int
+}
+
+func runModuleSync(args []string) error {
+	manifest, err := loadManifest()
+	if err != nil {
+		return fmt.Errorf("failed to load project manifest: %w", err)
+	}
+
+	modules := args
+	if len(modules) == 0 {
+		modules, err = syncableModules(manifest)
+		if err != nil {
+			return err
+		}
+		if len(modules) == 0 {
+			pkg.WarningLog("No modules found in", manifest.Layout.RPC)
+			return nil
+		}
+	}
+
+	return withRollback(append(protogenOutputs(manifest), manifest.Layout.Convert, manifest.Layout.Errs), func() error {
+		pkg.InfoLog("Generating protobuf files...")
+		if err := runProtogen(manifest); err != nil {
+			return fmt.Errorf("protogen failed: %w", err)
+		}
+
+		for _, moduleName := range modules {
+			pkg.Section("Syncing module " + moduleName)
+			report, err := syncModule(moduleName)
+			if err != nil {
+				return fmt.Errorf("module %s: %w", moduleName, err)
+			}
+			printSyncReport(report)
+		}
+		return nil
+	})
+}
+
+// syncableModules returns the module directories that have a matching proto directory
+func syncableModules(manifest *Manifest) ([]string, error) {
+	entries, err := os.ReadDir(manifest.Layout.RPC)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rpc directory: %w", err)
+	}
+
+	var modules []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(manifest.Layout.Proto, entry.Name())); err == nil {
+			modules = append(modules, entry.Name())
+		}
+	}
+	return modules, nil
+}
+
+func syncModule(moduleName string) (*syncReport, error) {
+	config, err := newModuleConfig(moduleName, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := validateProtoDirectory(config.ProtoPath); err != nil {
+		return nil, fmt.Errorf("proto validation failed: %w", err)
+	}
+	if err := discoverProtoFiles(config); err != nil {
+		return nil, fmt.Errorf("failed to discover proto files: %w", err)
+	}
+	if err := extractServiceMethods(config); err != nil {
+		return nil, fmt.Errorf("failed to extract service methods: %w", err)
+	}
+	if err := createModuleDirectory(config.AppPath); err != nil {
+		return nil, err
+	}
+
+	declared, err := declaredMethods(config.AppPath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &syncReport{}
+	for _, service := range config.Services {
+		content, err := generateServiceContent(config, service)
+		if err != nil {
+			return nil, err
+		}
+		if err := report.write(config, filepath.Join(config.AppPath, service.FileName), content, "module/service.go.tmpl"); err != nil {
+			return nil, err
+		}
+	}
+
+	expected := map[string]bool{}
+	for _, method := range config.ServiceMethods {
+		key := method.ServiceStruct + "." + method.Name
+		expected[key] = true
+
+		handlerPath := filepath.Join(config.AppPath, method.FileName)
+		if file, ok := declared[key]; ok && file != handlerPath {
+			// Implemented by hand in another file
+			report.Unchanged++
+			continue
+		}
+		content, err := generateHandlerContent(config, method)
+		if err != nil {
+			return nil, err
+		}
+		if err := report.write(config, handlerPath, content, "module/handler.go.tmpl"); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Model != nil {
+		content, err := generateConverterContent(config)
+		if err != nil {
+			return nil, err
+		}
+		if err := report.write(config, filepath.Join(config.AppPath, "converter.go"), content, "module/converter.go.tmpl"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := report.collectStale(config, declared, expected); err != nil {
+		return nil, err
+	}
+
+	if err := config.State.Save(); err != nil {
+		return nil, fmt.Errorf("failed to save generator state: %w", err)
+	}
+	return report, nil
+}
+
+// write writes a generated file through the state and records the outcome
+func (r *syncReport) write(config *ModuleConfig, path, content, template string) error {
+	result, err := config.State.writeGenerated(path, content, template)
+	if err != nil {
+		return err
+	}
+	switch result {
+	case writeCreated:
+		r.Created = append(r.Created, path)
+	case writeUpdated:
+		r.Updated = append(r.Updated, path)
+	case writeSkipped:
+		r.Kept = append(r.Kept, path)
+	default:
+		r.Unchanged++
+	}
+	return nil
+}
+
+// collectStale finds handler files that only declare methods whose rpc no
+// longer exists, removing them when --prune is set
+func (r *syncReport) collectStale(config *ModuleConfig, declared map[string]string, expected map[string]bool) error {
+	methodsByFile := map[string][]string{}
+	for key, file := range declared {
+		methodsByFile[file] = append(methodsByFile[file], key)
+	}
+
+	files := make([]string, 0, len(methodsByFile))
+	for file := range methodsByFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		if !strings.HasPrefix(filepath.Base(file), "rpc_") {
+			continue
+		}
+		stale := true
+		for _, key := range methodsByFile[file] {
+			if expected[key] {
+				stale = false
+				break
+			}
+		}
+		if !stale {
+			continue
+		}
+
+		if !syncPrune {
+			r.Stale = append(r.Stale, file)
+			continue
+		}
+		status, err := config.State.Status(file)
+		if err != nil {
+			return err
+		}
+		if status == fileEdited && !syncForce {
+			pkg.WarningLog("Not pruning", file, "(edited since it was generated, use --force to remove it)")
+			r.Stale = append(r.Stale, file)
+			continue
+		}
+		if err := removeFile(file); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+		config.State.Forget(file)
+		r.Pruned = append(r.Pruned, file)
+	}
+	return nil
+}
+
+// declaredMethods parses the Go files of a module directory and returns the
+// file declaring each method, keyed by "Receiver.Method"
+func declaredMethods(dir string) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	methods := map[string]string{}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		parsed, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		for _, decl := range parsed.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 {
+				continue
+			}
+			receiver := fn.Recv.List[0].Type
+			if star, ok := receiver.(*ast.StarExpr); ok {
+				receiver = star.X
+			}
+			if ident, ok := receiver.(*ast.Ident); ok {
+				methods[ident.Name+"."+fn.Name.Name] = file
+			}
+		}
+	}
+	return methods, nil
+}
+
+func printSyncReport(report *syncReport) {
+	for _, path := range report.Created {
+		logChange("Created", "Would create", path)
+	}
+	for _, path := range report.Updated {
+		logChange("Updated", "Would update", path)
+	}
+	for _, path := range report.Kept {
+		pkg.WarningLog("Kept", path, "(edited since it was generated)")
+	}
+	for _, path := range report.Pruned {
+		logChange("Removed", "Would remove", "stale handler", path)
+	}
+	for _, path := range report.Stale {
+		pkg.WarningLog("Stale handler", path, "(its rpc no longer exists)")
+	}
+	if len(report.Stale) > 0 && !syncPrune {
+		pkg.InfoLog("Run with --prune to remove stale handlers")
+	}
+	pkg.InfoLog(fmt.Sprintf("%d created, %d updated, %d kept, %d unchanged, %d stale, %d pruned",
+		len(report.Created), len(report.Updated), len(report.Kept), report.Unchanged, len(report.Stale), len(report.Pruned)))
+}
+
+func init() {
+	moduleSyncCmd.Flags().BoolVar(&syncPrune, "prune", false, "Remove handlers whose rpc no longer exists")
+	moduleSyncCmd.Flags().BoolVar(&syncForce, "force", false, "With --prune, also remove stale handlers that were edited")
+	moduleCmd.AddCommand(moduleSyncCmd)
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Scaffolding template commands",
+	Long: "Commands for inspecting and overriding the scaffolding templates. Templates are looked up in " +
+		projectTemplateDir + ", then in $" + templateDirEnv + " (or the user config directory), then in the binary",
+}
+
+var templatesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the scaffolding templates",
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := listTemplates()
+		if err != nil {
+			pkg.Red.Printf("Failed to list templates: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range names {
+			_, origin, err := loadTemplateSource(name)
+			if err != nil {
+				pkg.Red.Printf("Failed to resolve template %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			fmt.Printf("%-32s %s\n", name, origin)
+		}
+	},
+}
+
+var templatesExportCmd = &cobra.Command{
+	Use:   "export [dir]",
+	Short: "Copy the built-in templates into an override directory",
+	Long:  "Copies the built-in templates into dir (default " + projectTemplateDir + ") so they can be customized",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := projectTemplateDir
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if err := exportTemplates(dir); err != nil {
+			pkg.Red.Printf("Failed to export templates: %v\n", err)
+			os.Exit(1)
+		}
+		logChange("Templates exported to", "Would export templates to", dir)
+	},
+}
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+const (
+	// projectTemplateDir holds project-local template overrides
+	projectTemplateDir = ".grpcframe/templates"
+	// templateDirEnv points to a user-level template override directory
+	templateDirEnv = "GRPCFRAME_TEMPLATES"
+)
+
+// templateFuncs are available to every scaffolding template
+var templateFuncs = template.FuncMap{
+	"lower":  strings.ToLower,
+	"upper":  strings.ToUpper,
+	"pascal": toPascalCase,
+	"snake":  camelToSnake,
+	"importPath": func(manifest *Manifest, dir string) string {
+		return manifest.ImportPath(dir)
+	},
+	"joinPath": filepath.Join,
+	"comment":  goComment,
+}
+
+// goComment turns free text into a Go line comment
+func goComment(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("// "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// templateOverrideDirs returns the override directories in lookup order:
+// the project-local directory first, then the user-level one
+func templateOverrideDirs() []string {
+	dirs := []string{projectTemplateDir}
+	if dir := os.Getenv(templateDirEnv); dir != "" {
+		dirs = append(dirs, dir)
+	} else if configDir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(configDir, "grpcframe", "templates"))
+	}
+	return dirs
+}
+
+// loadTemplateSource returns the source of the named template, preferring
+// override directories over the templates embedded in the binary
+func loadTemplateSource(name string) (string, string, error) {
+	for _, dir := range templateOverrideDirs() {
+		overridePath := filepath.Join(dir, filepath.FromSlash(name))
+		content, err := os.ReadFile(overridePath)
+		if err == nil {
+			return string(content), overridePath, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", fmt.Errorf("failed to read template override %s: %w", overridePath, err)
+		}
+	}
+
+	content, err := embeddedTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return "", "", fmt.Errorf("template %s not found: %w", name, err)
+	}
+	return string(content), "embedded:" + name, nil
+}
+
+// renderTemplate executes the named template with data
+func renderTemplate(name string, data any) (string, error) {
+	source, origin, err := loadTemplateSource(name)
+	if err != nil {
+		return "", err
+	}
+	pkg.DebugLog("Rendering template", origin)
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", origin, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", origin, err)
+	}
+	return buf.String(), nil
+}
+
+// renderGoTemplate executes the named template and gofmts the resulting Go source
+func renderGoTemplate(name string, data any) (string, error) {
+	content, err := renderTemplate(name, data)
+	if err != nil {
+		return "", err
+	}
+	formatted, err := format.Source([]byte(content))
+	if err != nil {
+		return "", fmt.Errorf("template %s produced invalid Go code: %w", name, err)
+	}
+	return string(formatted), nil
+}
+
+// exportTemplates copies the embedded templates into dir so they can be
+// customized. Existing files are kept.
+func exportTemplates(dir string) error {
+	return fs.WalkDir(embeddedTemplates, "templates", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := embeddedTemplates.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(path, "templates/")))
+		return createFileWithContent(target, string(content))
+	})
+}
+
+// listTemplates returns the names of all embedded templates
+func listTemplates() ([]string, error) {
+	var names []string
+	err := fs.WalkDir(embeddedTemplates, "templates", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		names = append(names, strings.TrimPrefix(path, "templates/"))
+		return nil
+	})
+	return names, err
+}
+
+func init() {
+	templatesCmd.AddCommand(templatesListCmd)
+	templatesCmd.AddCommand(templatesExportCmd)
+	rootCmd.AddCommand(templatesCmd)
+}
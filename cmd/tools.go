@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/grpcframe/pkg"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// toolsFile pins the generator versions through go.mod
+	toolsFile = "tools.go"
+	// toolsBinDir receives the binaries built by tools install
+	toolsBinDir = "bin"
+)
+
+var toolsCmd = &cobra.Command{
+	Use:   "tools",
+	Short: "Pinned code generator commands",
+	Long:  "Commands for the code generators pinned in " + toolsFile,
+}
+
+var toolsInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Build the pinned code generators into ./bin",
+	Long: "Builds every tool imported by " + toolsFile + " into ./" + toolsBinDir + " at the version recorded in go.mod. " +
+		"protogen and sqlc prefer these binaries over the ones on PATH",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := installTools(); err != nil {
+			pkg.Red.Printf("Failed to install tools: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// pinnedTools returns the packages imported by tools.go
+func pinnedTools() ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), toolsFile, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", toolsFile, err)
+	}
+
+	var tools []string
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid import in %s: %w", toolsFile, err)
+		}
+		tools = append(tools, importPath)
+	}
+	return tools, nil
+}
+
+func installTools() error {
+	if _, err := os.Stat(toolsFile); os.IsNotExist(err) {
+		return fmt.Errorf("%s not found, create it with blank imports of the generators to pin", toolsFile)
+	}
+	tools, err := pinnedTools()
+	if err != nil {
+		return err
+	}
+	if err := makeDir(toolsBinDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", toolsBinDir, err)
+	}
+
+	for i, tool := range tools {
+		binary := filepath.Join(toolsBinDir, path.Base(tool))
+		pkg.Progress(i+1, len(tools), "Building "+binary)
+
+		err := runExternal("go build -o "+binary+" "+tool, func() error {
+			output, err := exec.Command("go", "build", "-o", binary, tool).CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("go build %s failed: %w\n%s\nrun go mod tidy to record the tool versions in go.mod", tool, err, strings.TrimSpace(string(output)))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	pkg.SuccessLog(fmt.Sprintf("Installed %d tools into ./%s", len(tools), toolsBinDir))
+	return nil
+}
+
+// preferProjectTools puts ./bin first on PATH, so the binaries built by
+// tools install win over globally installed versions
+func preferProjectTools() {
+	info, err := os.Stat(toolsBinDir)
+	if err != nil || !info.IsDir() {
+		return
+	}
+	binDir, err := filepath.Abs(toolsBinDir)
+	if err != nil {
+		return
+	}
+
+	current := os.Getenv("PATH")
+	if strings.HasPrefix(current, binDir+string(os.PathListSeparator)) {
+		return
+	}
+	pkg.DebugLog("Preferring tools in", binDir)
+	os.Setenv("PATH", binDir+string(os.PathListSeparator)+current)
+}
+
+func init() {
+	toolsCmd.AddCommand(toolsInstallCmd)
+	rootCmd.AddCommand(toolsCmd)
+}